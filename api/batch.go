@@ -0,0 +1,326 @@
+package api
+
+/*
+This file provides the /validate/batch endpoint, which validates many policies in one request. The policies are
+sent in one of three forms, told apart by the Content-Type of the request:
+ - application/json: an array of named policies, e.g. [{"name": "deploy-role", "policy": {...}}],
+ - multipart/form-data: one policy per part, named after its file name, or its form field without one,
+ - application/zip: an archive whose JSON and YAML files are the policies, named after their path in it.
+
+The policies are checked concurrently, each with the policy type the configuration gives its name, and the
+response lists the result of each one, in the order of the request, with a summary. A batch whose policies could be
+read gets a 200 response whether they are valid or not; only a request that cannot be read as a batch fails.
+
+The size of the request is limited by server.maxBatchBytes, its number of policies by server.maxBatchItems, and the
+size of each policy by server.maxBodyBytes, as a request to /validate is. The policies of an archive are limited by
+server.maxBatchBytes once decompressed too, as a small archive may expand into far more than it weighs.
+*/
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/scan"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+	Summary BatchSummary  `json:"summary"`
+}
+
+type BatchResult struct {
+	Name    string `json:"name"`
+	IsValid bool   `json:"is_valid"`
+	// Error is the first error of the policy, as /validate reports it.
+	Error    string         `json:"error,omitempty"`
+	Findings []BatchFinding `json:"findings"`
+}
+
+type BatchFinding struct {
+	Rule     string        `json:"rule"`
+	Severity lint.Severity `json:"severity"`
+	Message  string        `json:"message"`
+	// Element is the path of the policy element, e.g. PolicyDocument.Statement[1].Action[0].
+	Element string `json:"element,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// BatchSummary counts the policies of a batch and their findings by severity.
+type BatchSummary struct {
+	Total    int `json:"total"`
+	Valid    int `json:"valid"`
+	Invalid  int `json:"invalid"`
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+}
+
+// batchItem is a policy of a batch, with the error that kept it from being read, such as its size.
+type batchItem struct {
+	name string
+	data []byte
+	err  error
+}
+
+// errTooManyItems is returned when a batch has more policies than server.maxBatchItems.
+var errTooManyItems = errors.New("too many policies")
+
+// errTooLargeDecompressed is returned when the policies of an archive are larger than server.maxBatchBytes.
+var errTooLargeDecompressed = errors.New("policies too large once decompressed")
+
+// NewBatchHandler returns a handler validating the policies of a batch request with the settings of cfg.
+func NewBatchHandler(cfg *config.Config) http.HandlerFunc {
+	checker := report.Checker{Config: cfg}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if cfg.Server.MaxBatchBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.Server.MaxBatchBytes)
+		}
+		defer r.Body.Close()
+
+		items, err := readBatch(r, cfg.Server)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+			return
+		case errors.Is(err, errTooManyItems):
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request Entity Too Large: more than %d policies", cfg.Server.MaxBatchItems))
+			return
+		case errors.Is(err, errTooLargeDecompressed):
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request Entity Too Large: policies larger than %d bytes once decompressed", cfg.Server.MaxBatchBytes))
+			return
+		case err != nil:
+			respondWithError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+			return
+		case len(items) == 0:
+			respondWithError(w, http.StatusBadRequest, "Bad Request: no policies in the batch")
+			return
+		}
+
+		results := checkBatch(items, checker)
+		response := BatchResponse{Results: results, Summary: BatchSummary{Total: len(results)}}
+		for _, result := range results {
+			if result.IsValid {
+				response.Summary.Valid++
+			} else {
+				response.Summary.Invalid++
+			}
+			for _, finding := range result.Findings {
+				switch finding.Severity {
+				case lint.SeverityError:
+					response.Summary.Errors++
+				case lint.SeverityWarning:
+					response.Summary.Warnings++
+				default:
+					response.Summary.Infos++
+				}
+			}
+		}
+		respondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// readBatch reads the policies of a request in the form given by its Content-Type, JSON when there is none.
+func readBatch(r *http.Request, limits config.Server) ([]batchItem, error) {
+	mediaType := "application/json"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, fmt.Errorf("invalid Content-Type %q", contentType)
+		}
+	}
+
+	switch mediaType {
+	case "application/json":
+		return readJSONBatch(r.Body, limits)
+	case "multipart/form-data":
+		return readMultipartBatch(r, limits)
+	case "application/zip", "application/x-zip-compressed":
+		return readZipBatch(r.Body, limits)
+	}
+	return nil, fmt.Errorf("unsupported Content-Type %q", mediaType)
+}
+
+func readJSONBatch(body io.Reader, limits config.Server) ([]batchItem, error) {
+	var entries []struct {
+		Name   string          `json:"name"`
+		Policy json.RawMessage `json:"policy"`
+	}
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entries); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		return nil, errors.New("Error decoding JSON")
+	}
+	if limits.MaxBatchItems > 0 && len(entries) > limits.MaxBatchItems {
+		return nil, errTooManyItems
+	}
+
+	var items []batchItem
+	for i, entry := range entries {
+		item := batchItem{name: entry.Name, data: entry.Policy}
+		if item.name == "" {
+			item.name = fmt.Sprintf("policy #%d", i+1)
+		}
+		if len(entry.Policy) == 0 {
+			item.err = errors.New("no policy")
+		} else if limits.MaxBodyBytes > 0 && int64(len(entry.Policy)) > limits.MaxBodyBytes {
+			item.err = fmt.Errorf("policy larger than %d bytes", limits.MaxBodyBytes)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func readMultipartBatch(r *http.Request, limits config.Server) ([]batchItem, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	var items []batchItem
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if limits.MaxBatchItems > 0 && len(items) == limits.MaxBatchItems {
+			return nil, errTooManyItems
+		}
+		name := part.FileName()
+		if name == "" {
+			name = part.FormName()
+		}
+		item := readItem(name, part, limits.MaxBodyBytes)
+		var tooLarge *http.MaxBytesError
+		if errors.As(item.err, &tooLarge) {
+			return nil, item.err
+		}
+		items = append(items, item)
+	}
+}
+
+// readZipBatch reads the policies of an archive. The archive is read whole, as its directory is at its end.
+// The policies read are limited by limits.MaxBatchBytes altogether, once decompressed.
+func readZipBatch(body io.Reader, limits config.Server) ([]batchItem, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("Error reading zip archive")
+	}
+
+	var items []batchItem
+	remaining := limits.MaxBatchBytes
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !scan.IsPolicyFile(file.Name) || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+		if limits.MaxBatchItems > 0 && len(items) == limits.MaxBatchItems {
+			return nil, errTooManyItems
+		}
+		content, err := file.Open()
+		if err != nil {
+			items = append(items, batchItem{name: file.Name, err: err})
+			continue
+		}
+		var reader io.Reader = content
+		if limits.MaxBatchBytes > 0 {
+			reader = io.LimitReader(content, remaining+1)
+		}
+		item := readItem(file.Name, reader, limits.MaxBodyBytes)
+		content.Close()
+		if limits.MaxBatchBytes > 0 {
+			if remaining -= int64(len(item.data)); remaining < 0 {
+				return nil, errTooLargeDecompressed
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// readItem reads a policy of at most maxBytes bytes, any size when 0. The size of compressed files is only known
+// once they are read, so it is not trusted.
+func readItem(name string, r io.Reader, maxBytes int64) batchItem {
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return batchItem{name: name, err: err}
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return batchItem{name: name, err: fmt.Errorf("policy larger than %d bytes", maxBytes)}
+	}
+	return batchItem{name: name, data: data}
+}
+
+// checkBatch checks the policies on a pool of workers, and returns their results in the order of items.
+func checkBatch(items []batchItem, checker report.Checker) []BatchResult {
+	results := make([]BatchResult, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = checkItem(items[index], checker)
+			}
+		}()
+	}
+	for index := range items {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func checkItem(item batchItem, checker report.Checker) BatchResult {
+	result := BatchResult{Name: item.name, IsValid: true, Findings: []BatchFinding{}}
+	if item.err != nil {
+		result.IsValid, result.Error = false, item.err.Error()
+		return result
+	}
+	for _, finding := range checker.Check(item.name, item.data).Findings {
+		if finding.Severity == lint.SeverityError && result.IsValid {
+			result.IsValid, result.Error = false, finding.Message
+		}
+		result.Findings = append(result.Findings, BatchFinding{
+			Rule:     finding.Rule,
+			Severity: finding.Severity,
+			Message:  finding.Message,
+			Element:  finding.Path,
+			Line:     finding.Line,
+			Column:   finding.Column,
+		})
+	}
+	return result
+}
@@ -2,7 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
 	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io/ioutil"
 	"log"
 	"net/http"
 )
@@ -12,33 +17,64 @@ type PolicyResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// NewServer returns the routes of the API, checking policies with the settings of cfg.
+func NewServer(cfg *config.Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/validate", NewValidateHandler(cfg))
+	mux.HandleFunc("/validate/batch", NewBatchHandler(cfg))
+	return mux
+}
+
+// ValidateIAMPolicyHandler validates the policy of the request with the default settings.
 func ValidateIAMPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	NewValidateHandler(config.Default())(w, r)
+}
 
-	defer r.Body.Close()
+// NewValidateHandler returns a handler validating the policy of the request, and reporting the first error
+// in it, with the rules, severities, allowed accounts and request size limit of cfg.
+func NewValidateHandler(cfg *config.Config) http.HandlerFunc {
+	checker := report.Checker{Config: cfg, PolicyType: report.TypeIdentity}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	var policy validator.IAMPolicy
-	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Bad Request: Error decoding JSON")
-		return
-	}
+		if cfg.Server.MaxBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.Server.MaxBodyBytes)
+		}
+		defer r.Body.Close()
 
-	if err := validator.ValidateIAMPolicy(policy); err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
+		body, err := ioutil.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Bad Request: Error reading body")
+			return
+		}
+		if _, err := validator.DecodePolicy(body); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Bad Request: Error decoding JSON")
+			return
+		}
 
-	respondWithJSON(w, http.StatusOK, PolicyResponse{IsValid: true})
+		for _, finding := range checker.Check("request", body).Findings {
+			if finding.Severity == lint.SeverityError {
+				respondWithError(w, http.StatusBadRequest, finding.Message)
+				return
+			}
+		}
+		respondWithJSON(w, http.StatusOK, PolicyResponse{IsValid: true})
+	}
 }
 
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, PolicyResponse{IsValid: false, Error: message})
 }
 
-func respondWithJSON(w http.ResponseWriter, code int, payload PolicyResponse) {
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
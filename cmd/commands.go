@@ -0,0 +1,1491 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/account"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/baseline"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cfn"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cloudtrail"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/diff"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/effective"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/format"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/gitdiff"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/glob"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lsp"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/minimize"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/policytest"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/scan"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/terraform"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/usage"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// commands describes the non-interactive commands, in the order they are listed by help.
+var commands = []struct{ name, description string }{
+	{"validate", "validate policy files and report their findings"},
+	{"lint", "report redundant, shadowed and duplicated statements"},
+	{"fmt", "format policies canonically"},
+	{"fix", "apply the fixes of the lint findings"},
+	{"simulate", "evaluate a request against policies"},
+	{"test", "run declarative policy test suites"},
+	{"role", "validate whole IAM roles"},
+	{"cfn", "validate the policies of CloudFormation templates"},
+	{"terraform", "validate the policies of Terraform plans"},
+	{"minimize", "collapse the actions of a policy into wildcards"},
+	{"generate", "generate a least-privilege policy from CloudTrail logs"},
+	{"unused", "report the permissions unused in CloudTrail logs"},
+	{"effective", "compute the effective permissions of a role"},
+	{"account", "audit an account from its authorization details export"},
+	{"baseline", "record the current findings so that validate --baseline only reports new ones"},
+	{"hook", "install a git pre-commit hook validating the staged policies"},
+	{"lsp", "run the language server for editors over stdio"},
+	{"serve", "start the validation web server"},
+}
+
+// printUsage lists the commands. Run without arguments on a terminal, the binary starts the interactive menu instead.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: iam-json-verifier <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, command := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", command.name, command.description)
+	}
+	fmt.Fprintln(w, "\nRun iam-json-verifier <command> -h for the flags of a command.")
+	fmt.Fprintln(w, "Exit codes: 0 no findings, 1 findings, 2 usage or IO error.")
+}
+
+// runCommand runs a non-interactive command and returns the process exit code.
+func runCommand(name string, args []string) int {
+	switch name {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	case "validate":
+		return validateCommand(args)
+	case "test":
+		return testCommand(args)
+	case "role":
+		return roleCommand(args)
+	case "cfn":
+		return cfnCommand(args)
+	case "terraform":
+		return terraformCommand(args)
+	case "lint":
+		return lintCommand(args)
+	case "fmt":
+		return fmtCommand(args)
+	case "fix":
+		return fixCommand(args)
+	case "minimize":
+		return minimizeCommand(args)
+	case "generate":
+		return generateCommand(args)
+	case "unused":
+		return unusedCommand(args)
+	case "effective":
+		return effectiveCommand(args)
+	case "account":
+		return accountCommand(args)
+	case "simulate":
+		return simulateCommand(args)
+	case "baseline":
+		return baselineCommand(args)
+	case "hook":
+		return hookCommand(args)
+	case "lsp":
+		return lspCommand(args)
+	case "serve":
+		return serveCommand(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return 2
+	}
+}
+
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	scanning := addScanFlags(flags)
+	format := flags.String("format", "", "output format: "+strings.Join(report.Formats, ", ")+" (default from the configuration, text)")
+	output := flags.String("output", "", "write the report to this file instead of stdout")
+	baselinePath := flags.String("baseline", "", "only report the findings that are not in this baseline file")
+	changedSince := flags.String("changed-since", "", "only check the policy files and templates changed since this git ref, e.g. origin/main")
+	changedLines := flags.Bool("changed-lines", false, "with --changed-since, only report the findings on changed lines")
+	staged := flags.Bool("staged", false, "only check the staged policy files and templates, as they are in the git index")
+	watch := flags.Bool("watch", false, "keep running and re-validate the files that change")
+	interval := flags.Duration("interval", time.Second, "how often --watch looks for changes")
+	failOn := flags.String("fail-on", string(lint.SeverityInfo), "lowest severity of the findings that make the command exit with code 1: error, warning or info")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: iam-json-verifier validate [--config <file>] [--format %s] [--type %s] [--include <glob>]... [--exclude <glob>]... [--baseline <file>] [--changed-since <ref> [--changed-lines] | --staged] [--fail-on error|warning|info] [--output <file>] [--watch] <file|directory|->...\n",
+			strings.Join(report.Formats, "|"), policyTypeNames("|"))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths := flags.Args()
+	fromGit := *changedSince != "" || *staged
+	if len(paths) == 0 && fromGit {
+		paths = []string{"."}
+	}
+	threshold := lint.Severity(*failOn)
+	validThreshold := threshold == lint.SeverityError || threshold == lint.SeverityWarning || threshold == lint.SeverityInfo
+	if len(paths) == 0 || (*format != "" && !isReportFormat(*format)) || !scanning.valid() || (*changedLines && *changedSince == "") || !validThreshold {
+		flags.Usage()
+		return 2
+	}
+	options, cfg, err := scanning.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return 2
+	}
+	if *format == "" {
+		*format = cfg.Output.Format
+	}
+	var known *baseline.Baseline
+	if *baselinePath != "" {
+		if known, err = baseline.Load(*baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %s\n", err)
+			return 2
+		}
+		options.Skip = append(options.Skip, *baselinePath)
+	}
+
+	start := time.Now()
+	if *watch || fromGit {
+		for _, path := range paths {
+			if path == "-" {
+				fmt.Fprintln(os.Stderr, "--watch, --changed-since and --staged cannot read stdin")
+				return 2
+			}
+		}
+	}
+	if *changedSince != "" && *staged {
+		fmt.Fprintln(os.Stderr, "--changed-since cannot be used with --staged")
+		return 2
+	}
+	if *watch {
+		if fromGit {
+			fmt.Fprintln(os.Stderr, "--watch cannot be used with --changed-since or --staged")
+			return 2
+		}
+		if *format != report.FormatText || *output != "" {
+			fmt.Fprintln(os.Stderr, "--watch only writes the text report to the terminal")
+			return 2
+		}
+		watchFiles(scan.NewWatcher(paths, options), known, *interval)
+		return 0
+	}
+	var files []report.File
+	switch {
+	case *changedSince != "":
+		changes, err := gitdiff.Since(*changedSince)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing the changes since %s: %s\n", *changedSince, err)
+			return 2
+		}
+		if changed := changedFiles(changes.Files, paths, options); len(changed) > 0 {
+			files = scan.Scan(changed, options).Files
+		}
+		if *changedLines {
+			files = onChangedLines(files, changes)
+		}
+	case *staged:
+		names, err := gitdiff.Staged()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing the staged files: %s\n", err)
+			return 2
+		}
+		files = checkStaged(changedFiles(names, paths, options), options.Checker)
+	default:
+		files = checkPaths(paths, options)
+	}
+	var compared baseline.Result
+	if known != nil {
+		compared = known.Filter(files)
+		files = compared.Files
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %s\n", *output, err)
+			return 2
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := report.Write(out, *format, files); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %s\n", err)
+		return 2
+	}
+	// The summary goes with the text report, and to stderr when the report is meant for a program.
+	summaryOut := io.Writer(os.Stderr)
+	if *format == report.FormatText {
+		summaryOut = out
+	}
+	if known != nil {
+		printBaselineResult(summaryOut, *baselinePath, compared)
+	}
+	fmt.Fprintf(summaryOut, "\n%s in %s\n", report.Summarize(files), time.Since(start).Round(time.Millisecond))
+
+	exitCode := 0
+	for _, file := range files {
+		switch {
+		case file.Err != nil:
+			exitCode = 2
+		case file.FailsOn(threshold) && exitCode == 0:
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// scanFlags are the flags choosing and checking the files of validate and baseline create.
+type scanFlags struct {
+	configPath       *string
+	policyType       *string
+	include, exclude pathList
+	workers          *int
+}
+
+func addScanFlags(flags *flag.FlagSet) *scanFlags {
+	s := &scanFlags{
+		configPath: flags.String("config", "", "configuration file, instead of the "+config.FileName+" found from the working directory"),
+		policyType: flags.String("type", "", "policy type of every file: "+policyTypeNames(", ")+" (default from the configuration, identity)"),
+	}
+	flags.Var(&s.include, "include", "comma-separated globs of the files to check in directories (repeatable)")
+	flags.Var(&s.exclude, "exclude", "comma-separated globs of the files and directories to skip (repeatable)")
+	s.workers = flags.Int("workers", runtime.NumCPU(), "number of files checked at once")
+	return s
+}
+
+func (s *scanFlags) valid() bool {
+	return *s.policyType == "" || isPolicyType(*s.policyType)
+}
+
+// options loads the configuration and returns the options of the scan.
+func (s *scanFlags) options() (scan.Options, *config.Config, error) {
+	cfg, err := loadConfig(*s.configPath)
+	if err != nil {
+		return scan.Options{}, nil, err
+	}
+	return scan.Options{
+		Include: s.include.flatten(),
+		Exclude: s.exclude.flatten(),
+		Workers: *s.workers,
+		Checker: report.Checker{Config: cfg, PolicyType: report.PolicyType(*s.policyType), Templates: true},
+	}, cfg, nil
+}
+
+// checkPaths checks the files under paths, and the policies of stdin for "-", in the order of the arguments.
+func checkPaths(args []string, options scan.Options) []report.File {
+	var files []report.File
+	var paths []string
+	// Paths are scanned together up to stdin, so that the files keep the order of the arguments.
+	scanPaths := func() {
+		if len(paths) > 0 {
+			files = append(files, scan.Scan(paths, options).Files...)
+			paths = nil
+		}
+	}
+	for _, path := range args {
+		if path == "-" {
+			scanPaths()
+			files = append(files, options.Checker.CheckReader("<stdin>", os.Stdin)...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	scanPaths()
+	return files
+}
+
+// hookMarker identifies the pre-commit hooks written by hook install, which it may overwrite.
+const hookMarker = "# Installed by iam-json-verifier hook install."
+
+func hookCommand(args []string) int {
+	if len(args) == 0 || args[0] != "install" {
+		fmt.Fprintln(os.Stderr, "Usage: iam-json-verifier hook install [--force]")
+		return 2
+	}
+	flags := flag.NewFlagSet("hook install", flag.ContinueOnError)
+	force := flags.Bool("force", false, "replace a pre-commit hook that was not installed by this command")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier hook install [--force]")
+		fmt.Fprintln(flags.Output(), "Installs a git pre-commit hook running validate --staged --fail-on error, which blocks commits of policies with errors.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	dir, err := gitdiff.HooksDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding the git hooks: %s\n", err)
+		return 2
+	}
+	path := filepath.Join(dir, "pre-commit")
+	if existing, err := ioutil.ReadFile(path); err == nil && !strings.Contains(string(existing), hookMarker) && !*force {
+		fmt.Fprintf(os.Stderr, "%s already exists, run with --force to replace it\n", path)
+		return 2
+	}
+	// The hook runs this binary by its absolute path, so that it does not depend on the PATH of git clients.
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding the path of the binary: %s\n", err)
+		return 2
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s\nexec %s validate --staged --fail-on error\n", hookMarker, shellQuote(executable))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %s\n", dir, err)
+		return 2
+	}
+	if err := ioutil.WriteFile(path, []byte(script), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", path, err)
+		return 2
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(path, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error making %s executable: %s\n", path, err)
+		return 2
+	}
+	fmt.Printf("Pre-commit hook installed in %s ✅\n", path)
+	return 0
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// changedFiles returns the changed policy files under paths, filtered by the globs of options as a scan of paths would.
+func changedFiles(files []string, paths []string, options scan.Options) []string {
+	var changed []string
+	for _, file := range files {
+		name := filepath.Base(file)
+		if !scan.IsPolicyFile(name) || name == config.FileName || name == baseline.DefaultPath || options.Skipped(file) {
+			continue
+		}
+		for _, path := range paths {
+			if changedUnder(file, path, options) {
+				changed = append(changed, file)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+func changedUnder(file, path string, options scan.Options) bool {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absoluteFile, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	relative, err := filepath.Rel(absolutePath, absoluteFile)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return false
+	}
+	// Files given explicitly are always checked.
+	if relative == "." {
+		return true
+	}
+	relative = filepath.ToSlash(relative)
+	// An excluded directory excludes the files under it.
+	for i := range relative {
+		if relative[i] == '/' && glob.MatchAny(options.Exclude, relative[:i]) {
+			return false
+		}
+	}
+	if glob.MatchAny(options.Exclude, relative) {
+		return false
+	}
+	return len(options.Include) == 0 || glob.MatchAny(options.Include, relative)
+}
+
+// checkStaged checks the content of the files in the git index, which is what a commit would record.
+func checkStaged(paths []string, checker report.Checker) []report.File {
+	var files []report.File
+	for _, path := range paths {
+		data, err := gitdiff.StagedContent(path)
+		if err != nil {
+			files = append(files, report.File{Path: path, Err: err})
+			continue
+		}
+		files = append(files, checker.Check(path, data))
+	}
+	return files
+}
+
+// onChangedLines keeps the findings on the changed lines of the files, and those that have no line.
+// A finding spanning several lines, such as a template resource, is kept when one of them changed.
+func onChangedLines(files []report.File, changes *gitdiff.Changes) []report.File {
+	var kept []report.File
+	for _, file := range files {
+		findings := file.Findings
+		file.Findings = nil
+		for _, finding := range findings {
+			end := finding.EndLine
+			if end < finding.Line {
+				end = finding.Line
+			}
+			if finding.Line == 0 || changes.Changed(file.Path, finding.Line, end) {
+				file.Findings = append(file.Findings, finding)
+			}
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+func printBaselineResult(w io.Writer, path string, result baseline.Result) {
+	fmt.Fprintf(w, "\n%d findings of %s left out\n", result.Suppressed, path)
+	if len(result.Stale) > 0 {
+		fmt.Fprintf(w, "%d baseline entries no longer occur and can be removed by creating the baseline again:\n", len(result.Stale))
+		for _, entry := range result.Stale {
+			fmt.Fprintf(w, "  %s: %s [%s]\n", entry.File, entry.Message, entry.Rule)
+		}
+	}
+}
+
+func baselineCommand(args []string) int {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprintln(os.Stderr, "Usage: iam-json-verifier baseline create [--output <file>] [validate flags] <file|directory|->...")
+		return 2
+	}
+	flags := flag.NewFlagSet("baseline create", flag.ContinueOnError)
+	scanning := addScanFlags(flags)
+	output := flags.String("output", baseline.DefaultPath, "baseline file to write")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier baseline create [--output <file>] [--config <file>] [--type <type>] [--include <glob>]... [--exclude <glob>]... <file|directory|->...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || !scanning.valid() {
+		flags.Usage()
+		return 2
+	}
+	options, _, err := scanning.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return 2
+	}
+	options.Skip = append(options.Skip, *output)
+
+	files := checkPaths(flags.Args(), options)
+	exitCode := 0
+	for _, file := range files {
+		if file.Err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", file.Path, file.Err)
+			exitCode = 2
+		}
+	}
+	created := baseline.Create(files)
+	if err := created.Save(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", *output, err)
+		return 2
+	}
+	fmt.Printf("%d findings of %d files recorded in %s ✅\n", len(created.Findings), len(files), *output)
+	return exitCode
+}
+
+// watchFiles redraws a compact view of the results whenever files change, until interrupted.
+func watchFiles(watcher *scan.Watcher, known *baseline.Baseline, interval time.Duration) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if changed := watcher.Poll(); len(changed) > 0 {
+			// Clear the terminal and move the cursor home before redrawing.
+			fmt.Print("\033[H\033[2J")
+			files := watcher.Files()
+			if known != nil {
+				files = known.Filter(files).Files
+			}
+			for _, file := range files {
+				switch {
+				case file.Err != nil:
+					fmt.Printf("❌ %s: %s\n", file.Path, file.Err)
+				case file.Failed():
+					fmt.Printf("❌ %s\n", file.Path)
+				case len(file.Findings) > 0:
+					fmt.Printf("⚠️  %s\n", file.Path)
+				}
+				for _, finding := range file.Findings {
+					fmt.Printf("   %-7s %s\n", finding.Severity, finding)
+				}
+			}
+			fmt.Printf("\n%s\n", report.Summarize(files))
+			fmt.Printf("%s: %d changed, watching for changes (Ctrl+C to stop)\n", time.Now().Format("15:04:05"), len(changed))
+		}
+
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier test <suite.yaml>...")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		ok, err := runPolicyTests(path)
+		if err != nil {
+			fmt.Printf("Error running %s: %s ❌\n", path, err)
+			return 2
+		}
+		failed = failed || !ok
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func runPolicyTests(path string) (bool, error) {
+	suite, err := policytest.LoadSuite(path)
+	if err != nil {
+		return false, err
+	}
+	report, err := suite.Run()
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("\n--- Running %s:\n", path)
+	for i, result := range report.Results {
+		name := result.Case.Name
+		if name == "" {
+			name = fmt.Sprintf("case %d", i+1)
+		}
+		if result.Passed {
+			fmt.Printf("PASS %s ✅\n", name)
+			continue
+		}
+		fmt.Printf("FAIL %s: expected %s, got %s ❌\n", name, result.Case.Expect, result.Result.Decision)
+		for _, err := range result.Result.Errors {
+			fmt.Printf("     %s\n", err)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(report.Results)-report.Failed(), report.Failed())
+	return report.Failed() == 0, nil
+}
+
+func roleCommand(args []string) int {
+	flags := flag.NewFlagSet("role", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier role <role.json>...")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		fmt.Printf("\n--- Validating role %s:\n", path)
+		if valid, err := validator.ValidateRoleJson(path); err != nil || !valid {
+			fmt.Printf("Validation failed for %s: %s ❌\n", path, err)
+			exitCode = 1
+		} else {
+			fmt.Println("Role validation passed ✅")
+		}
+	}
+	return exitCode
+}
+
+func cfnCommand(args []string) int {
+	flags := flag.NewFlagSet("cfn", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier cfn <template.yaml|template.json>...")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		fmt.Printf("\n--- Validating template %s:\n", path)
+		template, err := cfn.LoadTemplate(path)
+		if err != nil {
+			fmt.Printf("Invalid template: %s ❌\n", err)
+			exitCode = 2
+			continue
+		}
+
+		results := template.Validate()
+		for _, result := range results {
+			if result.Err != nil {
+				fmt.Printf("%s ❌\n", result)
+				if exitCode == 0 {
+					exitCode = 1
+				}
+			} else {
+				fmt.Printf("%s ✅\n", result)
+			}
+		}
+		if len(results) == 0 {
+			fmt.Println("No IAM policies found")
+		}
+	}
+	return exitCode
+}
+
+func terraformCommand(args []string) int {
+	flags := flag.NewFlagSet("terraform", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier terraform <plan.json>...")
+		fmt.Fprintln(flags.Output(), "Create the plan file with: terraform show -json plan.out > plan.json")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		fmt.Printf("\n--- Validating plan %s:\n", path)
+		plan, err := terraform.LoadPlan(path)
+		if err != nil {
+			fmt.Printf("Invalid plan: %s ❌\n", err)
+			exitCode = 2
+			continue
+		}
+
+		results := plan.Validate()
+		for _, result := range results {
+			switch {
+			case result.Err != nil:
+				fmt.Printf("%s ❌\n", result)
+				if exitCode == 0 {
+					exitCode = 1
+				}
+			case result.Skipped != "":
+				fmt.Printf("%s\n", result)
+			default:
+				fmt.Printf("%s ✅\n", result)
+			}
+		}
+		if len(results) == 0 {
+			fmt.Println("No IAM policies found")
+		}
+	}
+	return exitCode
+}
+
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configPath := flags.String("config", "", "configuration file, instead of the "+config.FileName+" found from the working directory")
+	format := flags.String("format", report.FormatText, "output format: "+strings.Join(report.Formats, ", "))
+	output := flags.String("output", "", "write the report to this file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: iam-json-verifier lint [--config <file>] [--format %s] [--output <file>] <policy.json>...\n",
+			strings.Join(report.Formats, "|"))
+		fmt.Fprintln(flags.Output(), "Lint rules apply to identity policies only; validate --type checks the other policy types.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || !isReportFormat(*format) {
+		flags.Usage()
+		return 2
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return 2
+	}
+	if *format != report.FormatText || *output != "" {
+		return lintReport(flags.Args(), cfg, *format, *output)
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		fmt.Printf("\n--- Linting %s:\n", path)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error reading %s: %s ❌\n", path, err)
+			exitCode = 2
+			continue
+		}
+		doc, err := lint.NewDocument(data)
+		if err != nil {
+			fmt.Printf("Invalid format of policy: %s ❌\n", err)
+			exitCode = 2
+			continue
+		}
+
+		var findings []lint.Finding
+		for _, finding := range lint.LintDocument(doc) {
+			if cfg.RuleEnabled(finding.Rule) {
+				finding.Severity = cfg.Severity(finding.Rule, finding.Severity)
+				findings = append(findings, finding)
+			}
+		}
+		for _, finding := range findings {
+			hint := ""
+			if lint.Fixable(doc, finding) {
+				hint = " (fixable)"
+			}
+			fmt.Printf("%-7s %s%s\n", finding.Severity, finding, hint)
+		}
+		if len(findings) == 0 {
+			fmt.Println("No findings ✅")
+		} else if exitCode == 0 {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// lintReport writes the lint findings of the files as a report in the given format, to output or stdout.
+func lintReport(paths []string, cfg *config.Config, format, output string) int {
+	checker := report.Checker{Config: cfg}
+	var files []report.File
+	exitCode := 0
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			files = append(files, report.File{Path: path, Err: err})
+			exitCode = 2
+			continue
+		}
+		if _, err := lint.NewDocument(data); err != nil {
+			exitCode = 2
+		}
+		file := checker.Lint(path, data)
+		if len(file.Findings) > 0 && exitCode == 0 {
+			exitCode = 1
+		}
+		files = append(files, file)
+	}
+
+	out := os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %s\n", output, err)
+			return 2
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := report.Write(out, format, files); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %s\n", err)
+		return 2
+	}
+	return exitCode
+}
+
+func fixCommand(args []string) int {
+	flags := flag.NewFlagSet("fix", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the fixes as a unified diff instead of applying them")
+	configPath := flags.String("config", "", "configuration file, instead of the "+config.FileName+" found from the working directory")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier fix [--config <file>] [--dry-run] <policy.json>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", path, err)
+			exitCode = 2
+			continue
+		}
+		fixed, findings, err := lint.FixSource(data, cfg.RuleEnabled)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fixing %s: %s\n", path, err)
+			exitCode = 2
+			continue
+		}
+		if len(findings) == 0 {
+			continue
+		}
+
+		if *dryRun {
+			name := diffPath(path)
+			fmt.Print(diff.Unified("a/"+name, "b/"+name, data, fixed))
+			continue
+		}
+		if err := ioutil.WriteFile(path, fixed, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", path, err)
+			exitCode = 2
+			continue
+		}
+		for _, finding := range findings {
+			fmt.Printf("%s: fixed %s\n", path, finding)
+		}
+	}
+	return exitCode
+}
+
+// diffPath returns the name of a file in the headers of a diff, relative to the working directory so that
+// git apply and patch -p1 can apply it.
+func diffPath(path string) string {
+	if filepath.IsAbs(path) {
+		if dir, err := os.Getwd(); err == nil {
+			if relative, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(relative, "..") {
+				path = relative
+			}
+		}
+	}
+	return strings.TrimLeft(filepath.ToSlash(filepath.Clean(path)), "/")
+}
+
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list files that are not formatted instead of rewriting them")
+	lists := flags.String("lists", string(format.Compact), "list style: compact (one-element lists as strings) or expanded (strings as lists)")
+	indent := flags.Int("indent", 2, "number of spaces per indentation level")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier fmt [--check] [--lists compact|expanded] [--indent n] <policy.json>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	options := format.Options{ListStyle: format.ListStyle(*lists), Indent: strings.Repeat(" ", *indent)}
+	if flags.NArg() == 0 || (options.ListStyle != format.Compact && options.ListStyle != format.Expanded) || *indent < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", path, err)
+			exitCode = 2
+			continue
+		}
+
+		formatted, err := format.Format(data, options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting %s: %s\n", path, err)
+			exitCode = 2
+			continue
+		}
+		if string(formatted) == string(data) {
+			continue
+		}
+
+		if *check {
+			fmt.Println(path)
+			if exitCode == 0 {
+				exitCode = 1
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(path, formatted, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", path, err)
+			exitCode = 2
+		}
+	}
+	return exitCode
+}
+
+func minimizeCommand(args []string) int {
+	flags := flag.NewFlagSet("minimize", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the minimized policy back to the file instead of printing it")
+	wildcards := flags.Bool("wildcards", false, "replace action lists with wildcards, which also grant the actions AWS adds later")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier minimize [-w] [--wildcards] <policy.json>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	policy, err := validator.LoadPolicyFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid IAM policy %s: %s\n", path, err)
+		return 2
+	}
+
+	minimized := minimize.Minimize(policy, minimize.Options{Wildcards: *wildcards})
+	if err := minimize.Verify(policy, minimized); err != nil {
+		fmt.Fprintf(os.Stderr, "Minimized policy is not equivalent to %s: %s\n", path, err)
+		return 1
+	}
+
+	format.Normalize(&minimized, format.DefaultOptions)
+	formatted, err := format.Marshal(minimized, format.DefaultOptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding minimized policy: %s\n", err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d → %d statements, %d → %d characters\n", path,
+		len(policy.PolicyDocument.Statement), len(minimized.PolicyDocument.Statement),
+		minimize.Size(policy), minimize.Size(minimized))
+
+	if !*write {
+		fmt.Print(string(formatted))
+		return 0
+	}
+	if err := ioutil.WriteFile(path, formatted, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", path, err)
+		return 2
+	}
+	return 0
+}
+
+func generateCommand(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	role := flags.String("role", "", "ARN of the role whose activity is turned into a policy (required)")
+	since := flags.String("since", "", "only use events at or after this time (RFC 3339)")
+	until := flags.String("until", "", "only use events at or before this time (RFC 3339)")
+	name := flags.String("name", "", "PolicyName of the generated policy (default: <role name>-least-privilege)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier generate --role <arn> [--since t] [--until t] [--name n] <cloudtrail logs>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *role == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	filter, err := timeWindow(*role, *since, *until)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *name == "" {
+		*name = (*role)[strings.LastIndex(*role, "/")+1:] + "-least-privilege"
+	}
+
+	events, err := cloudtrail.ReadEvents(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading CloudTrail logs: %s\n", err)
+		return 2
+	}
+	activity := cloudtrail.Observe(events, filter)
+	for _, observed := range activity.SortedActions() {
+		if !observed.Known {
+			fmt.Fprintf(os.Stderr, "Warning: %s is not in the action catalog, check it is the action authorizing the event\n", observed.Action)
+		}
+	}
+
+	policy, err := cloudtrail.Generate(activity, *name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating policy: %s\n", err)
+		return 1
+	}
+	formatted, err := format.Marshal(policy, format.DefaultOptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding policy: %s\n", err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d events matched, %d actions\n", activity.Events, len(events), len(activity.Actions))
+	fmt.Print(string(formatted))
+	return 0
+}
+
+// timeWindow builds the CloudTrail filter of a role from RFC 3339 bounds, either of which may be empty.
+func timeWindow(role, since, until string) (cloudtrail.Filter, error) {
+	filter := cloudtrail.Filter{RoleARN: role}
+	for _, bound := range []struct {
+		value  string
+		target *time.Time
+	}{{since, &filter.Since}, {until, &filter.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return cloudtrail.Filter{}, fmt.Errorf("Invalid time %q: %s", bound.value, err)
+		}
+		*bound.target = t
+	}
+	return filter, nil
+}
+
+func unusedCommand(args []string) int {
+	flags := flag.NewFlagSet("unused", flag.ContinueOnError)
+	policyPath := flags.String("policy", "", "policy file to check (required)")
+	role := flags.String("role", "", "ARN of the role to look for in the CloudTrail logs")
+	advisor := flags.String("access-advisor", "", "Access Advisor export to use instead of CloudTrail logs")
+	since := flags.String("since", "", "start of the window, earlier usage does not count (RFC 3339)")
+	until := flags.String("until", "", "end of the window for CloudTrail logs (RFC 3339)")
+	output := flags.String("o", "", "write the suggested policy to this file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier unused --policy <policy.json> [--since t] (--role <arn> [--until t] <cloudtrail logs>... | --access-advisor <export.json>)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	fromCloudTrail := *role != "" && flags.NArg() > 0
+	fromAdvisor := *advisor != "" && *role == "" && flags.NArg() == 0
+	if *policyPath == "" || fromCloudTrail == fromAdvisor {
+		flags.Usage()
+		return 2
+	}
+
+	policy, err := validator.LoadPolicyFile(*policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", *policyPath, err)
+		return 2
+	}
+	filter, err := timeWindow(*role, *since, *until)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var used usage.Usage
+	if fromAdvisor {
+		used, err = usage.ReadAccessAdvisor(*advisor)
+	} else {
+		var events []cloudtrail.Event
+		if events, err = cloudtrail.ReadEvents(flags.Args()); err == nil {
+			used = usage.FromActivity(cloudtrail.Observe(events, filter))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading usage: %s\n", err)
+		return 2
+	}
+
+	report := usage.Analyze(policy, used, filter.Since)
+	printUsageReport(*policyPath, report)
+	if len(report.UnusedGrants()) == 0 {
+		return 0
+	}
+
+	trimmed, err := usage.Trim(policy, report)
+	if err != nil {
+		fmt.Printf("No policy to suggest: %s ❌\n", err)
+		return 1
+	}
+	formatted, err := format.Marshal(trimmed, format.DefaultOptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding policy: %s\n", err)
+		return 2
+	}
+	if *output != "" {
+		if err := ioutil.WriteFile(*output, formatted, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", *output, err)
+			return 2
+		}
+		fmt.Printf("Suggested policy written to %s\n", *output)
+	} else {
+		fmt.Printf("\nSuggested policy:\n%s", formatted)
+	}
+	return 1
+}
+
+func printUsageReport(path string, report usage.Report) {
+	window := ""
+	if !report.Since.IsZero() {
+		window = " since " + report.Since.Format(time.RFC3339)
+	}
+	fmt.Printf("\n--- Unused permissions of %s%s:\n", path, window)
+
+	if services := report.UnusedServices(); len(services) > 0 {
+		fmt.Println("Unused services:")
+		for _, service := range services {
+			fmt.Printf("  %s (%d allowed actions)\n", service.Prefix, service.Granted)
+		}
+	}
+	unused := report.UnusedGrants()
+	if len(unused) > 0 {
+		fmt.Println("Unused actions:")
+		for _, grant := range unused {
+			lastUsed := "never used"
+			if !grant.LastUsed.IsZero() {
+				lastUsed = "last used " + grant.LastUsed.Format(time.RFC3339)
+			}
+			if grant.ServiceLevel {
+				lastUsed += " (usage of the service)"
+			}
+			fmt.Printf("  %-40s %s\n", grant.Action, lastUsed)
+		}
+	} else {
+		fmt.Println("Every allowed action was used ✅")
+	}
+	fmt.Printf("%d of %d allowed actions used\n", len(report.Grants)-len(unused), len(report.Grants))
+}
+
+// pathList is a flag that can be repeated, each occurrence holding one or more comma-separated paths.
+type pathList [][]string
+
+func (p *pathList) String() string {
+	return fmt.Sprint(*p)
+}
+
+func (p *pathList) Set(value string) error {
+	*p = append(*p, strings.Split(value, ","))
+	return nil
+}
+
+// flatten returns the paths of every occurrence of the flag.
+func (p pathList) flatten() []string {
+	var paths []string
+	for _, values := range p {
+		paths = append(paths, values...)
+	}
+	return paths
+}
+
+func accountCommand(args []string) int {
+	flags := flag.NewFlagSet("account", flag.ContinueOnError)
+	var scps pathList
+	flags.Var(&scps, "scp", "comma-separated SCPs attached at one level of the organization (repeat for each level)")
+	permissions := flags.Bool("permissions", false, "list the effective permissions of every user and role")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier account [--scp <scp.json>[,<scp.json>]]... [--permissions] <export.json>...")
+		fmt.Fprintln(flags.Output(), "Create the export with: aws iam get-account-authorization-details > export.json")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	export, err := account.LoadExport(flags.Args()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading export: %s\n", err)
+		return 2
+	}
+	var levels [][]validator.IAMPolicy
+	for _, paths := range scps {
+		var level []validator.IAMPolicy
+		for _, path := range paths {
+			policy, err := validator.DecodePolicyFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", path, err)
+				return 2
+			}
+			level = append(level, policy)
+		}
+		levels = append(levels, level)
+	}
+
+	report := account.Audit(export, levels)
+	fmt.Printf("\n--- Auditing account %s: %d users, %d groups, %d roles, %d managed policies\n",
+		report.AccountID, report.Users, report.Groups, report.Roles, report.Policies)
+	for _, finding := range report.Findings {
+		fmt.Printf("%-7s %s\n", finding.Severity, finding)
+	}
+	if len(report.Findings) == 0 {
+		fmt.Println("No findings ✅")
+	}
+
+	fmt.Println("\n--- Effective permissions:")
+	for _, principal := range report.Principals {
+		fmt.Printf("  %s: %d effective permissions, %d sensitive\n",
+			principal.Arn, len(principal.Effective.Permissions), len(principal.Effective.Sensitive))
+		if *permissions {
+			for _, permission := range principal.Effective.Permissions {
+				fmt.Printf("      %s\n", permission)
+			}
+		}
+	}
+	for _, note := range report.Notes {
+		fmt.Printf("Note: %s\n", note)
+	}
+	fmt.Printf("%d findings, %d errors\n", len(report.Findings), report.Errors())
+	if len(report.Findings) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func effectiveCommand(args []string) int {
+	flags := flag.NewFlagSet("effective", flag.ContinueOnError)
+	var identity, scps pathList
+	flags.Var(&identity, "policy", "inline or managed policy of the role (repeatable)")
+	boundary := flags.String("boundary", "", "permission boundary of the role")
+	flags.Var(&scps, "scp", "comma-separated SCPs attached at one level of the organization (repeat for each level)")
+	sensitiveOnly := flags.Bool("sensitive", false, "only list the sensitive grants")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier effective --policy <policy.json>... [--boundary <policy.json>] [--scp <scp.json>[,<scp.json>]]...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if len(identity) == 0 || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var set effective.PolicySet
+	for _, paths := range identity {
+		for _, path := range paths {
+			policy, err := validator.DecodePolicyFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", path, err)
+				return 2
+			}
+			if err := validator.ValidateIAMPolicy(policy); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", path, err)
+			}
+			set.Identity = append(set.Identity, policy)
+		}
+	}
+	if *boundary != "" {
+		policy, err := validator.DecodePolicyFile(*boundary)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", *boundary, err)
+			return 2
+		}
+		set.Boundary = &policy
+	}
+	for _, paths := range scps {
+		var level []validator.IAMPolicy
+		for _, path := range paths {
+			policy, err := validator.DecodePolicyFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", path, err)
+				return 2
+			}
+			level = append(level, policy)
+		}
+		set.SCPs = append(set.SCPs, level)
+	}
+
+	result := effective.Compute(set)
+	if !*sensitiveOnly {
+		fmt.Println("\n--- Effective permissions:")
+		for _, permission := range result.Permissions {
+			fmt.Printf("  %s\n", permission)
+		}
+	}
+	if len(result.Sensitive) > 0 {
+		fmt.Println("\n--- Sensitive grants:")
+		for _, grant := range result.Sensitive {
+			fmt.Printf("  %s: %s ❌\n", grant.Permission, grant.Reason)
+			for _, source := range grant.Sources {
+				fmt.Printf("      granted by %s statement %d %s\n", source.PolicyName, source.Index+1, source.Sid)
+			}
+		}
+	}
+	for _, note := range result.Notes {
+		fmt.Printf("Note: %s\n", note)
+	}
+	fmt.Printf("%d effective permissions, %d sensitive\n", len(result.Permissions), len(result.Sensitive))
+	if len(result.Sensitive) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func simulateCommand(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	var policies pathList
+	var context contextValues
+	flags.Var(&policies, "policy", "comma-separated policies to evaluate the request against (repeatable)")
+	action := flags.String("action", "", "action of the request, e.g. s3:GetObject")
+	resource := flags.String("resource", "*", "resource ARN of the request")
+	principal := flags.String("principal", "", "principal ARN of the request, matched against Principal in resource-based policies")
+	flags.Var(&context, "context", "condition key of the request as key=value (repeatable)")
+	expect := flags.String("expect", "", "expected decision: allow, deny, explicitDeny or implicitDeny")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier simulate --policy <policy.json>... --action <action> [--resource <arn>] [--context key=value]... [--expect allow|deny]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if len(policies) == 0 || *action == "" || flags.NArg() > 0 || (*expect != "" && !policytest.IsExpectation(*expect)) {
+		flags.Usage()
+		return 2
+	}
+
+	var loaded []validator.IAMPolicy
+	for _, paths := range policies {
+		for _, path := range paths {
+			policy, err := validator.LoadPolicyFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading %s: %s\n", path, err)
+				return 2
+			}
+			loaded = append(loaded, policy)
+		}
+	}
+
+	request := simulator.Request{Principal: *principal, Action: *action, Resource: *resource, Context: context.values}
+	result := simulator.Evaluate(loaded, request)
+	fmt.Printf("\n--- Simulating %s on %s:\n", *action, *resource)
+	fmt.Printf("Decision: %s\n", result.Decision)
+	for _, statement := range result.Matched {
+		fmt.Printf("  matched %s statement %d %s\n", statement.PolicyName, statement.Index+1, statement.Sid)
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("  %s ❌\n", err)
+	}
+
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	if *expect != "" {
+		if !policytest.Expects(*expect, result.Decision) {
+			fmt.Printf("Expected %s ❌\n", *expect)
+			return 1
+		}
+		fmt.Printf("Expected %s ✅\n", *expect)
+	}
+	return 0
+}
+
+// contextValues is a repeatable key=value flag, the values of a key accumulating.
+type contextValues struct {
+	values map[string][]string
+}
+
+func (c *contextValues) String() string {
+	return fmt.Sprint(c.values)
+}
+
+func (c *contextValues) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	if c.values == nil {
+		c.values = map[string][]string{}
+	}
+	c.values[key] = append(c.values[key], v)
+	return nil
+}
+
+func lspCommand(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	configPath := flags.String("config", "", "configuration file of every document, instead of the "+config.FileName+" found for each of them")
+	// Editors commonly pass --stdio; it is the only transport.
+	flags.Bool("stdio", true, "talk to the editor over stdin and stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier lsp [--config <file>]")
+		fmt.Fprintln(flags.Output(), "Runs a Language Server Protocol server over stdin and stdout, started by an editor.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	server := lsp.NewServer(os.Stdin, os.Stdout)
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+			return 2
+		}
+		server.Config = cfg
+	}
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Language server stopped: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func serveCommand(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "", "address to listen on (default from the configuration, :8080)")
+	configPath := flags.String("config", "", "configuration file, instead of the "+config.FileName+" found from the working directory")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iam-json-verifier serve [--config <file>] [--addr <host:port>]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return 2
+	}
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+
+	if err := serve(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start server: %v\n", err)
+		return 2
+	}
+	return 0
+}
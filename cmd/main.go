@@ -2,16 +2,31 @@ package main
 
 import (
 	"fmt"
+	"github.com/chzyer/readline"
 	"github.com/kcbojanowski/aws-iam-policy-verifier/api"
-	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
 	"github.com/manifoldco/promptui"
-	"io/fs"
 	"net/http"
-	"path/filepath"
+	"os"
 	"strings"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	// The menu needs someone to answer it: in scripts and CI, print the usage instead of waiting on stdin.
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Discover()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %s ❌\n", err)
+		return
+	}
 	mode, err := selectMode()
 	if err != nil {
 		fmt.Printf("Prompt failed %v\n", err)
@@ -19,66 +34,109 @@ func main() {
 	}
 
 	switch mode {
-	case "Test with internal data":
-		validateInternalData("./tests/test_data")
 	case "Input your own JSON file":
-		validateUserFile()
+		validateUserFile(cfg)
+	case "Run policy tests":
+		runUserPolicyTests()
 	case "Run Server":
-		runServer()
+		runServer(cfg)
 	}
 }
 
 func selectMode() (string, error) {
 	prompt := promptui.Select{
 		Label: "Select Mode",
-		Items: []string{"Test with internal data", "Input your own JSON file", "Run Server"},
+		Items: []string{"Input your own JSON file", "Run policy tests", "Run Server"},
 	}
 	_, result, err := prompt.Run()
 	return result, err
 }
 
-func validateInternalData(testdataPath string) {
-	err := filepath.Walk(testdataPath, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			fmt.Println("Error accessing path:", path, "error:", err)
-			return err
+// isPolicyType reports whether policyType is one of the policy types of validate.
+func isPolicyType(policyType string) bool {
+	for _, known := range report.PolicyTypes {
+		if policyType == string(known) {
+			return true
 		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
-			fmt.Printf("\n--- Testing %s:\n", info.Name())
-			validateFile(path)
+	}
+	return false
+}
+
+func policyTypeNames(separator string) string {
+	var names []string
+	for _, policyType := range report.PolicyTypes {
+		names = append(names, string(policyType))
+	}
+	return strings.Join(names, separator)
+}
+
+// isReportFormat reports whether format is one of the output formats of validate.
+func isReportFormat(format string) bool {
+	for _, known := range report.Formats {
+		if format == known {
+			return true
 		}
-		return nil
-	})
-	if err != nil {
-		fmt.Println("Error walking through test data directory:", err)
 	}
+	return false
 }
 
-func validateUserFile() {
+func validateUserFile(cfg *config.Config) {
 	prompt := promptui.Prompt{
-		Label: "Enter path to the JSON file",
+		Label: "Enter path to the JSON or YAML file",
 	}
 	filePath, err := prompt.Run()
 	if err != nil {
 		fmt.Printf("Prompt failed %v\n", err)
 		return
 	}
-	validateFile(filePath)
+	validateFile(cfg, filePath)
+}
+
+func runUserPolicyTests() {
+	prompt := promptui.Prompt{
+		Label:   "Enter path to the policy test file",
+		Default: "./tests/policy_tests/example_test.yaml",
+	}
+	suitePath, err := prompt.Run()
+	if err != nil {
+		fmt.Printf("Prompt failed %v\n", err)
+		return
+	}
+	if _, err := runPolicyTests(suitePath); err != nil {
+		fmt.Printf("Error running %s: %s ❌\n", suitePath, err)
+	}
 }
 
-func validateFile(filePath string) {
-	valid, err := validator.ValidatePolicyJson(filePath)
-	if err != nil || !valid {
-		fmt.Printf("Validation failed for %s: %s\n", filePath, err)
-	} else {
-		fmt.Printf("Validation successful for %s\n", filePath)
+func validateFile(cfg *config.Config, filePath string) {
+	file := report.Checker{Config: cfg, Templates: true}.CheckFile(filePath)
+	if err := report.WriteText(os.Stdout, []report.File{file}); err != nil {
+		fmt.Printf("Error writing report: %s\n", err)
 	}
 }
 
-func runServer() {
-	fmt.Println("Starting server on http://localhost:8080...")
-	http.HandleFunc("/validate", api.ValidateIAMPolicyHandler)
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+func runServer(cfg *config.Config) {
+	if err := serve(cfg); err != nil {
 		fmt.Printf("Failed to start server: %v\n", err)
 	}
 }
+
+func serve(cfg *config.Config) error {
+	fmt.Printf("Starting server on %s...\n", serverURL(cfg.Server.Addr))
+	return http.ListenAndServe(cfg.Server.Addr, api.NewServer(cfg))
+}
+
+// loadConfig loads the configuration file at path, or the one found from the working directory when path is empty.
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		return config.Discover()
+	}
+	return config.Load(path)
+}
+
+// serverURL turns a listen address such as :8080 into a URL to open.
+func serverURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
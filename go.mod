@@ -2,9 +2,10 @@ module github.com/kcbojanowski/aws-iam-policy-verifier
 
 go 1.22.2
 
-require github.com/manifoldco/promptui v0.9.0
-
 require (
-	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
-	golang.org/x/sys v0.0.0-20181122145206-62eef0e2fa9b // indirect
+	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e
+	github.com/manifoldco/promptui v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.0.0-20181122145206-62eef0e2fa9b // indirect
@@ -0,0 +1,303 @@
+package account
+
+/*
+This file audits an account from its export and produces a single report:
+ - every inline policy and every version of the customer managed policies is validated and linted,
+   older versions too since they can be made the default again at any time,
+ - roles are validated like `role` does, from their trust policy, path, boundary and attached policies,
+ - the effective permissions of every user and role are computed from their inline policies, the policies of their
+   groups, their attached managed policies, their permission boundary and the given SCPs, and the sensitive grants
+   that survive are reported.
+AWS managed policies are not checked, the account cannot change them, but they count in the effective permissions.
+Service-linked roles are skipped the same way: AWS defines both their trust and their permissions.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/effective"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"strings"
+)
+
+const (
+	// RuleValidation is the rule of the findings reported by the validator.
+	RuleValidation = "validation"
+	// RuleSensitiveGrant is the rule of the sensitive effective permissions.
+	RuleSensitiveGrant = "sensitiveGrant"
+)
+
+const serviceLinkedRolePath = "/aws-service-role/"
+
+type Finding struct {
+	// Entity is the ARN of the user, group, role or managed policy the finding is about.
+	Entity string
+	// Location is where the finding is in the entity, e.g. RolePolicyList[0] (reports) or version v2.
+	Location string
+	Rule     string
+	Severity lint.Severity
+	Message  string
+}
+
+type Principal struct {
+	// Kind is "user" or "role".
+	Kind      string
+	Name      string
+	Arn       string
+	Effective effective.Result
+	// Missing lists the managed policies attached to the principal but absent from the export, left out of Effective.
+	Missing []string
+}
+
+type Report struct {
+	AccountID  string
+	Users      int
+	Groups     int
+	Roles      int
+	Policies   int
+	Findings   []Finding
+	Principals []Principal
+	Notes      []string
+}
+
+type auditor struct {
+	export *Export
+	report *Report
+	// managed holds the decoded default version of the managed policies, by ARN.
+	managed map[string]validator.IAMPolicy
+	scps    [][]validator.IAMPolicy
+}
+
+// Audit checks every entity of the export and computes the effective permissions of its users and roles,
+// restricted by the SCPs of each level of the organization above the account.
+func Audit(export *Export, scps [][]validator.IAMPolicy) Report {
+	report := Report{
+		AccountID: export.AccountID(),
+		Users:     len(export.Users),
+		Groups:    len(export.Groups),
+		Roles:     len(export.Roles),
+		Policies:  len(export.Policies),
+	}
+	if export.IsTruncated {
+		report.Notes = append(report.Notes, "the export is truncated, entities of the next pages are missing")
+	}
+	a := &auditor{export: export, report: &report, managed: map[string]validator.IAMPolicy{}, scps: scps}
+
+	for _, policy := range export.Policies {
+		a.auditManagedPolicy(policy)
+	}
+	for _, group := range export.Groups {
+		for i, inline := range group.GroupPolicyList {
+			a.checkPolicy(group.Arn, inlineLocation("GroupPolicyList", i, inline), inline.PolicyName, inline.PolicyDocument)
+		}
+	}
+	for _, user := range export.Users {
+		a.auditUser(user)
+	}
+	for _, role := range export.Roles {
+		a.auditRole(role)
+	}
+	return report
+}
+
+func (a *auditor) auditManagedPolicy(policy ManagedPolicy) {
+	if policy.AWSManaged() {
+		if version, ok := policy.DefaultVersion(); ok {
+			if decoded, err := decodePolicy(policy.PolicyName, version.Document); err == nil {
+				a.managed[policy.Arn] = decoded.Policy
+			}
+		}
+		return
+	}
+
+	for _, version := range policy.PolicyVersionList {
+		location := "version " + version.VersionId
+		if version.IsDefaultVersion {
+			location += " (default)"
+		}
+		decoded, ok, _ := a.checkPolicy(policy.Arn, location, policy.PolicyName, version.Document)
+		if ok && (version.IsDefaultVersion || version.VersionId == policy.DefaultVersionId) {
+			a.managed[policy.Arn] = decoded
+		}
+	}
+}
+
+func (a *auditor) auditUser(user User) {
+	var identity []validator.IAMPolicy
+	for i, inline := range user.UserPolicyList {
+		location := inlineLocation("UserPolicyList", i, inline)
+		if policy, ok, _ := a.checkPolicy(user.Arn, location, inline.PolicyName, inline.PolicyDocument); ok {
+			identity = append(identity, policy)
+		}
+	}
+
+	principal := Principal{Kind: "user", Name: user.UserName, Arn: user.Arn}
+	identity = append(identity, a.attached(user.AttachedManagedPolicies, &principal)...)
+	for _, name := range user.GroupList {
+		group, ok := a.export.Group(name)
+		if !ok {
+			principal.Missing = append(principal.Missing, "group "+name)
+			continue
+		}
+		for _, inline := range group.GroupPolicyList {
+			if decoded, err := decodePolicy(inline.PolicyName, inline.PolicyDocument); err == nil {
+				identity = append(identity, decoded.Policy)
+			}
+		}
+		identity = append(identity, a.attached(group.AttachedManagedPolicies, &principal)...)
+	}
+	a.addPrincipal(principal, identity, user.PermissionsBoundary)
+}
+
+func (a *auditor) auditRole(role Role) {
+	if strings.HasPrefix(role.Path, serviceLinkedRolePath) {
+		a.report.Notes = append(a.report.Notes, fmt.Sprintf("%s is a service-linked role, it is not audited", role.Arn))
+		return
+	}
+
+	var identity, valid []validator.IAMPolicy
+	for i, inline := range role.RolePolicyList {
+		location := inlineLocation("RolePolicyList", i, inline)
+		policy, decoded, ok := a.checkPolicy(role.Arn, location, inline.PolicyName, inline.PolicyDocument)
+		if decoded {
+			identity = append(identity, policy)
+		}
+		if ok {
+			valid = append(valid, policy)
+		}
+	}
+
+	properties := validator.Role{RoleName: role.RoleName, Path: role.Path, Policies: valid}
+	for _, attached := range role.AttachedManagedPolicies {
+		properties.ManagedPolicyArns = append(properties.ManagedPolicyArns, attached.PolicyArn)
+	}
+	if role.PermissionsBoundary != nil {
+		properties.PermissionsBoundary = role.PermissionsBoundary.PermissionsBoundaryArn
+	}
+	document, err := validator.DecodePolicyDocument(role.AssumeRolePolicyDocument)
+	if len(role.AssumeRolePolicyDocument) > 0 && err != nil {
+		a.addFinding(role.Arn, "AssumeRolePolicyDocument", RuleValidation, lint.SeverityError, err.Error())
+	} else {
+		if len(role.AssumeRolePolicyDocument) > 0 {
+			properties.AssumeRolePolicyDocument = &document
+		}
+		// Inline policies failing validation are left out, they are already reported on their own.
+		if err := validator.ValidateRole(properties); err != nil {
+			a.addFinding(role.Arn, "role", RuleValidation, lint.SeverityError, err.Error())
+		}
+	}
+
+	principal := Principal{Kind: "role", Name: role.RoleName, Arn: role.Arn}
+	identity = append(identity, a.attached(role.AttachedManagedPolicies, &principal)...)
+	a.addPrincipal(principal, identity, role.PermissionsBoundary)
+}
+
+// attached returns the default versions of attached managed policies, and records those missing from the export.
+func (a *auditor) attached(policies []AttachedPolicy, principal *Principal) []validator.IAMPolicy {
+	var identity []validator.IAMPolicy
+	for _, attached := range policies {
+		policy, ok := a.managed[attached.PolicyArn]
+		if !ok {
+			principal.Missing = append(principal.Missing, attached.PolicyArn)
+			continue
+		}
+		identity = append(identity, policy)
+	}
+	return identity
+}
+
+func (a *auditor) addPrincipal(principal Principal, identity []validator.IAMPolicy, boundary *Boundary) {
+	set := effective.PolicySet{Identity: identity, SCPs: a.scps}
+	if boundary != nil && boundary.PermissionsBoundaryArn != "" {
+		if policy, ok := a.managed[boundary.PermissionsBoundaryArn]; ok {
+			set.Boundary = &policy
+		} else {
+			// The permissions are computed without the boundary: an audit had better overestimate them.
+			principal.Missing = append(principal.Missing, boundary.PermissionsBoundaryArn)
+		}
+	}
+
+	principal.Effective = effective.Compute(set)
+	for _, grant := range principal.Effective.Sensitive {
+		var sources []string
+		for _, source := range grant.Sources {
+			sources = append(sources, fmt.Sprintf("%s statement %d", source.PolicyName, source.Index+1))
+		}
+		a.addFinding(principal.Arn, "effective permissions", RuleSensitiveGrant, lint.SeverityWarning,
+			fmt.Sprintf("%s: %s, granted by %s", grant.Permission, grant.Reason, strings.Join(sources, ", ")))
+	}
+	if len(principal.Missing) > 0 {
+		a.report.Notes = append(a.report.Notes, fmt.Sprintf("%s: %s missing from the export, left out of the effective permissions",
+			principal.Arn, strings.Join(principal.Missing, ", ")))
+	}
+	a.report.Principals = append(a.report.Principals, principal)
+}
+
+// checkPolicy validates and lints a policy. It returns the policy when it could be decoded, even if it is invalid,
+// since an invalid policy may still grant permissions, and whether it passed validation.
+func (a *auditor) checkPolicy(entity, location, name string, document Document) (policy validator.IAMPolicy, decoded, valid bool) {
+	doc, err := decodePolicy(name, document)
+	if err != nil {
+		a.addFinding(entity, location, RuleValidation, lint.SeverityError, err.Error())
+		return validator.IAMPolicy{}, false, false
+	}
+
+	valid = true
+	if err := validator.ValidateIAMPolicy(doc.Policy); err != nil {
+		a.addFinding(entity, location, RuleValidation, lint.SeverityError, err.Error())
+		valid = false
+	}
+	for _, finding := range lint.LintDocument(doc) {
+		a.addFinding(entity, location+" "+finding.Path, finding.Rule, finding.Severity, finding.Message)
+	}
+	return doc.Policy, true, valid
+}
+
+func (a *auditor) addFinding(entity, location, rule string, severity lint.Severity, message string) {
+	a.report.Findings = append(a.report.Findings, Finding{
+		Entity:   entity,
+		Location: location,
+		Rule:     rule,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+// decodePolicy wraps a document into a named policy and parses it, so that the lint rules working on the
+// syntax tree also apply to it.
+func decodePolicy(name string, document Document) (*lint.Document, error) {
+	if len(document) == 0 {
+		return nil, fmt.Errorf("policy document is missing")
+	}
+	source, err := json.MarshalIndent(map[string]interface{}{
+		"PolicyName":     name,
+		"PolicyDocument": json.RawMessage(document),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := validator.DecodePolicy(source); err != nil {
+		return nil, err
+	}
+	return lint.NewDocument(source)
+}
+
+func inlineLocation(list string, index int, policy InlinePolicy) string {
+	return fmt.Sprintf("%s[%d] (%s)", list, index, policy.PolicyName)
+}
+
+// Errors returns the number of findings of error severity.
+func (r Report) Errors() int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == lint.SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s [%s]", f.Entity, f.Location, f.Message, f.Rule)
+}
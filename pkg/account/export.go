@@ -0,0 +1,207 @@
+package account
+
+/*
+This file reads the output of `aws iam get-account-authorization-details`, which describes every user, group, role
+and managed policy of an account, with all the versions of the managed policies.
+The IAM API returns policy documents URL-encoded (RFC 3986) inside JSON strings; the AWS CLI decodes them into
+objects. Both forms are accepted. The CLI paginates on its own, but exports made with --no-paginate or with
+the API directly can be split into several pages, which are merged in the order they are given.
+
+More information about the export can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/APIReference/API_GetAccountAuthorizationDetails.html
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+type Export struct {
+	Paths    []string
+	Users    []User          `json:"UserDetailList"`
+	Groups   []Group         `json:"GroupDetailList"`
+	Roles    []Role          `json:"RoleDetailList"`
+	Policies []ManagedPolicy `json:"Policies"`
+	// IsTruncated is true when the last page read announces another page.
+	IsTruncated bool `json:"IsTruncated"`
+}
+
+type User struct {
+	UserName                string           `json:"UserName"`
+	Arn                     string           `json:"Arn"`
+	Path                    string           `json:"Path"`
+	GroupList               []string         `json:"GroupList"`
+	UserPolicyList          []InlinePolicy   `json:"UserPolicyList"`
+	AttachedManagedPolicies []AttachedPolicy `json:"AttachedManagedPolicies"`
+	PermissionsBoundary     *Boundary        `json:"PermissionsBoundary"`
+}
+
+type Group struct {
+	GroupName               string           `json:"GroupName"`
+	Arn                     string           `json:"Arn"`
+	Path                    string           `json:"Path"`
+	GroupPolicyList         []InlinePolicy   `json:"GroupPolicyList"`
+	AttachedManagedPolicies []AttachedPolicy `json:"AttachedManagedPolicies"`
+}
+
+type Role struct {
+	RoleName                 string           `json:"RoleName"`
+	Arn                      string           `json:"Arn"`
+	Path                     string           `json:"Path"`
+	AssumeRolePolicyDocument Document         `json:"AssumeRolePolicyDocument"`
+	RolePolicyList           []InlinePolicy   `json:"RolePolicyList"`
+	AttachedManagedPolicies  []AttachedPolicy `json:"AttachedManagedPolicies"`
+	PermissionsBoundary      *Boundary        `json:"PermissionsBoundary"`
+}
+
+type ManagedPolicy struct {
+	PolicyName        string          `json:"PolicyName"`
+	Arn               string          `json:"Arn"`
+	Path              string          `json:"Path"`
+	DefaultVersionId  string          `json:"DefaultVersionId"`
+	AttachmentCount   int             `json:"AttachmentCount"`
+	PolicyVersionList []PolicyVersion `json:"PolicyVersionList"`
+}
+
+type PolicyVersion struct {
+	Document         Document `json:"Document"`
+	VersionId        string   `json:"VersionId"`
+	IsDefaultVersion bool     `json:"IsDefaultVersion"`
+}
+
+type InlinePolicy struct {
+	PolicyName     string   `json:"PolicyName"`
+	PolicyDocument Document `json:"PolicyDocument"`
+}
+
+type AttachedPolicy struct {
+	PolicyName string `json:"PolicyName"`
+	PolicyArn  string `json:"PolicyArn"`
+}
+
+type Boundary struct {
+	PermissionsBoundaryType string `json:"PermissionsBoundaryType"`
+	PermissionsBoundaryArn  string `json:"PermissionsBoundaryArn"`
+}
+
+// Document holds a policy document as JSON, decoded from its URL-encoded form when needed.
+// It is decoded into the validator's models when audited, so that an invalid document is reported as a finding.
+type Document []byte
+
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		*d = append(Document{}, data...)
+		return nil
+	}
+	decoded, err := url.PathUnescape(text)
+	if err != nil {
+		return fmt.Errorf("policy document is not URL-encoded JSON: %v", err)
+	}
+	*d = Document(decoded)
+	return nil
+}
+
+func LoadExport(paths ...string) (*Export, error) {
+	export := &Export{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		page, err := ParseExport(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		export.Paths = append(export.Paths, path)
+		export.Users = append(export.Users, page.Users...)
+		export.Groups = append(export.Groups, page.Groups...)
+		export.Roles = append(export.Roles, page.Roles...)
+		export.Policies = append(export.Policies, page.Policies...)
+		export.IsTruncated = page.IsTruncated
+	}
+	return export, nil
+}
+
+func ParseExport(data []byte) (*Export, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	found := false
+	for _, key := range []string{"UserDetailList", "GroupDetailList", "RoleDetailList", "Policies"} {
+		_, ok := probe[key]
+		found = found || ok
+	}
+	if !found {
+		return nil, fmt.Errorf("not a get-account-authorization-details export: no UserDetailList, GroupDetailList, RoleDetailList or Policies")
+	}
+
+	export := &Export{}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// AccountID returns the ID of the account the export describes, read from the ARNs of its entities.
+func (e *Export) AccountID() string {
+	var arns []string
+	for _, user := range e.Users {
+		arns = append(arns, user.Arn)
+	}
+	for _, group := range e.Groups {
+		arns = append(arns, group.Arn)
+	}
+	for _, role := range e.Roles {
+		arns = append(arns, role.Arn)
+	}
+	for _, policy := range e.Policies {
+		arns = append(arns, policy.Arn)
+	}
+	for _, arn := range arns {
+		if parts := strings.SplitN(arn, ":", 6); len(parts) == 6 && parts[4] != "" && parts[4] != "aws" {
+			return parts[4]
+		}
+	}
+	return ""
+}
+
+// Policy returns the managed policy with the given ARN.
+func (e *Export) Policy(arn string) (ManagedPolicy, bool) {
+	for _, policy := range e.Policies {
+		if policy.Arn == arn {
+			return policy, true
+		}
+	}
+	return ManagedPolicy{}, false
+}
+
+// Group returns the group with the given name.
+func (e *Export) Group(name string) (Group, bool) {
+	for _, group := range e.Groups {
+		if group.GroupName == name {
+			return group, true
+		}
+	}
+	return Group{}, false
+}
+
+// DefaultVersion returns the version of the policy in effect.
+func (p ManagedPolicy) DefaultVersion() (PolicyVersion, bool) {
+	for _, version := range p.PolicyVersionList {
+		if version.IsDefaultVersion || version.VersionId == p.DefaultVersionId {
+			return version, true
+		}
+	}
+	return PolicyVersion{}, false
+}
+
+// AWSManaged reports whether the policy is managed by AWS rather than by the account.
+func (p ManagedPolicy) AWSManaged() bool {
+	return strings.Contains(p.Arn, ":iam::aws:policy/")
+}
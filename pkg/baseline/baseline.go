@@ -0,0 +1,152 @@
+package baseline
+
+/*
+This file records the findings of a run in a baseline file, so that later runs only fail on new findings.
+This lets a repository adopt new rules without fixing every existing finding first.
+
+Findings are identified by their fingerprint (see report.Fingerprint), computed from the rule, the file, the policy
+element and the message, but not the line or the index of the statement, so that a baselined finding stays
+baselined when lines or statements move.
+A baseline can hold the same fingerprint several times, e.g. for a duplicated statement, and each entry suppresses
+one finding. Entries that match no finding any more are stale: the finding was fixed and the entry can be removed
+by creating the baseline again.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultPath is the file baseline create writes when no output is given.
+const DefaultPath = ".iam-verifier-baseline.json"
+
+// version is increased when fingerprints are computed differently, which makes the entries of older baselines stale.
+const version = 2
+
+type Baseline struct {
+	Version  int     `json:"version"`
+	Findings []Entry `json:"findings"`
+}
+
+// Entry describes a baselined finding. Only the fingerprint is matched, the other fields help reviewing the file.
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	File        string `json:"file"`
+	Rule        string `json:"rule"`
+	Message     string `json:"message"`
+}
+
+// Result is the outcome of comparing findings with a baseline.
+type Result struct {
+	// Files holds the files with their new findings only.
+	Files []report.File
+	// Suppressed counts the findings found in the baseline.
+	Suppressed int
+	// Stale lists the entries of the files checked matching no finding.
+	Stale []Entry
+}
+
+// Create records the findings of the files. Files that could not be read have no findings to record.
+func Create(files []report.File) *Baseline {
+	baseline := &Baseline{Version: version, Findings: []Entry{}}
+	for _, file := range files {
+		for _, finding := range file.Findings {
+			baseline.Findings = append(baseline.Findings, Entry{
+				Fingerprint: report.Fingerprint(file.Path, finding),
+				File:        entryFile(file.Path),
+				Rule:        finding.Rule,
+				Message:     finding.Message,
+			})
+		}
+	}
+	sort.SliceStable(baseline.Findings, func(i, j int) bool {
+		a, b := baseline.Findings[i], baseline.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return baseline
+}
+
+func Load(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if baseline.Version != version {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d, expected %d: run baseline create again", path, baseline.Version, version)
+	}
+	return &baseline, nil
+}
+
+func (b *Baseline) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(b)
+}
+
+func (b *Baseline) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Filter removes the findings of the baseline from the files, and lists the entries of these files that no longer
+// occur. Entries of other files are not stale, since their files were not checked.
+func (b *Baseline) Filter(files []report.File) Result {
+	checked := map[string]bool{}
+	for _, file := range files {
+		checked[entryFile(file.Path)] = true
+	}
+	remaining := map[string]int{}
+	for _, entry := range b.Findings {
+		remaining[entry.Fingerprint]++
+	}
+
+	result := Result{}
+	for _, file := range files {
+		filtered := file
+		filtered.Findings = nil
+		for _, finding := range file.Findings {
+			fingerprint := report.Fingerprint(file.Path, finding)
+			if remaining[fingerprint] > 0 {
+				remaining[fingerprint]--
+				result.Suppressed++
+				continue
+			}
+			filtered.Findings = append(filtered.Findings, finding)
+		}
+		result.Files = append(result.Files, filtered)
+	}
+
+	// The last entries of a fingerprint are the stale ones, there is no telling which of equal entries was fixed.
+	for i := len(b.Findings) - 1; i >= 0; i-- {
+		entry := b.Findings[i]
+		if remaining[entry.Fingerprint] > 0 && checked[entry.File] {
+			remaining[entry.Fingerprint]--
+			result.Stale = append([]Entry{entry}, result.Stale...)
+		}
+	}
+	return result
+}
+
+func entryFile(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
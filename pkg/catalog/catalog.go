@@ -0,0 +1,172 @@
+package catalog
+
+/*
+This file provides the catalog of IAM actions known to the verifier, grouped by service and access level, and of the
+condition keys policies can use: the global aws: keys and the keys of each service.
+The catalog is a snapshot bundled with the binary (catalog.json), covering a set of commonly used services.
+Services that are not in the catalog are unknown: their wildcards cannot be expanded, and nothing is assumed about them.
+
+More information about the actions of each service can be found here:
+ - https://docs.aws.amazon.com/service-authorization/latest/reference/reference_policies_actions-resources-contextkeys.html
+*/
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed catalog.json
+var catalogJSON []byte
+
+var eventVersionSuffix = regexp.MustCompile(`\d{8}(v\d+)?$`)
+
+type Service struct {
+	Prefix      string
+	Name        string
+	EventSource string
+	// Actions maps each action name of the service to its access level, e.g. Read or Permissions management.
+	Actions map[string]string
+	// Events maps CloudTrail event names to the action authorizing them, when they are not named the same.
+	Events map[string]string
+	// ConditionKeys lists the condition keys of the service, e.g. s3:prefix. Keys ending in a ${Variable}
+	// stand for a family of keys, e.g. s3:ExistingObjectTag/${TagKey}.
+	ConditionKeys []string
+}
+
+var services = map[string]*Service{}
+
+var globalConditionKeys []string
+
+func init() {
+	var data struct {
+		GlobalConditionKeys []string `json:"globalConditionKeys"`
+		Services            map[string]struct {
+			Name          string              `json:"name"`
+			EventSource   string              `json:"eventSource"`
+			Actions       map[string][]string `json:"actions"`
+			Events        map[string]string   `json:"events"`
+			ConditionKeys []string            `json:"conditionKeys"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(catalogJSON, &data); err != nil {
+		panic(fmt.Sprintf("catalog: invalid catalog.json: %v", err))
+	}
+
+	for prefix, s := range data.Services {
+		service := &Service{Prefix: prefix, Name: s.Name, EventSource: s.EventSource, Actions: map[string]string{},
+			Events: s.Events, ConditionKeys: s.ConditionKeys}
+		for level, actions := range s.Actions {
+			for _, action := range actions {
+				service.Actions[action] = level
+			}
+		}
+		services[prefix] = service
+	}
+	globalConditionKeys = data.GlobalConditionKeys
+}
+
+// Lookup returns the service with the given prefix, e.g. "s3".
+func Lookup(prefix string) (*Service, bool) {
+	service, ok := services[strings.ToLower(prefix)]
+	return service, ok
+}
+
+// Services returns all services of the catalog ordered by prefix.
+func Services() []*Service {
+	list := make([]*Service, 0, len(services))
+	for _, service := range services {
+		list = append(list, service)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Prefix < list[j].Prefix })
+	return list
+}
+
+// ActionNames returns the full names of the service's actions, e.g. "s3:GetObject", in alphabetical order.
+func (s *Service) ActionNames() []string {
+	names := make([]string, 0, len(s.Actions))
+	for action := range s.Actions {
+		names = append(names, s.Prefix+":"+action)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConditionKeys returns the global condition keys, then the condition keys of each service ordered by prefix.
+func ConditionKeys() []string {
+	keys := append([]string{}, globalConditionKeys...)
+	for _, service := range Services() {
+		keys = append(keys, service.ConditionKeys...)
+	}
+	return keys
+}
+
+// Action returns the documented name and access level of an action such as "s3:getobject".
+func Action(action string) (name, accessLevel string, ok bool) {
+	prefix, actionName, found := strings.Cut(action, ":")
+	service, known := Lookup(prefix)
+	if !found || !known {
+		return "", "", false
+	}
+	for candidate, level := range service.Actions {
+		if strings.EqualFold(candidate, actionName) {
+			return service.Prefix + ":" + candidate, level, true
+		}
+	}
+	return "", "", false
+}
+
+// ActionForEvent maps a CloudTrail event, e.g. s3.amazonaws.com ListObjectsV2, to the action authorizing it.
+// Services missing from the catalog are mapped by convention, from the event source prefix and the event name,
+// in which case ok is false.
+func ActionForEvent(eventSource, eventName string) (action string, ok bool) {
+	// Some services version their event names, e.g. Lambda's UpdateFunctionCode20150331v2.
+	eventName = eventVersionSuffix.ReplaceAllString(eventName, "")
+
+	for _, service := range services {
+		if service.EventSource != eventSource {
+			continue
+		}
+		if mapped, found := service.Events[eventName]; found {
+			eventName = mapped
+		}
+		if name, _, found := Action(service.Prefix + ":" + eventName); found {
+			return name, true
+		}
+		return service.Prefix + ":" + eventName, false
+	}
+	return strings.TrimSuffix(eventSource, ".amazonaws.com") + ":" + eventName, false
+}
+
+// Expand returns the full names of the catalog actions matched by an action pattern such as "s3:Get*".
+// The result is only complete when ok is true, i.e. when every service the pattern can match is in the catalog.
+func Expand(pattern string) (actions []string, ok bool) {
+	prefix, _, found := strings.Cut(pattern, ":")
+	if !found {
+		return nil, false
+	}
+
+	ok = true
+	if strings.ContainsAny(prefix, "*?") {
+		// A wildcard in the service prefix may match services the catalog does not know.
+		ok = false
+	} else if _, known := Lookup(prefix); !known {
+		return nil, false
+	}
+
+	for _, service := range Services() {
+		if !simulator.MatchAction(prefix, service.Prefix) {
+			continue
+		}
+		for _, action := range service.ActionNames() {
+			if simulator.MatchAction(pattern, action) {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return actions, ok
+}
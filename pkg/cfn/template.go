@@ -0,0 +1,295 @@
+package cfn
+
+/*
+This file parses CloudFormation templates, in JSON or YAML, into plain values that can be decoded into the
+validator's models. Both formats are read with the YAML parser, JSON being a subset of YAML, which also gives
+the line of every resource.
+
+Intrinsic functions are resolved into plain values:
+ - the short-form tags (!Ref, !Sub, !GetAtt, ...) are first converted to their long form ({"Ref": ...}),
+ - pseudo parameters, such as AWS::Partition or AWS::AccountId, are replaced by example values,
+ - !Sub and !Join are resolved into strings in which every other reference becomes the Placeholder value,
+ - !If is replaced by its first branch, or its second one when the first is AWS::NoValue,
+ - any other intrinsic, such as !GetAtt or !ImportValue, becomes the Placeholder value.
+So an ARN built from intrinsics is validated as an opaque value instead of being reported as an error.
+
+More information about intrinsic functions can be found here:
+ - https://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/intrinsic-function-reference.html
+*/
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Placeholder stands for the value of an intrinsic function that cannot be resolved without deploying the stack.
+const Placeholder = "placeholder"
+
+var pseudoParameters = map[string]string{
+	"AWS::AccountId":        "123456789012",
+	"AWS::NotificationARNs": Placeholder,
+	"AWS::Partition":        "aws",
+	"AWS::Region":           "us-east-1",
+	"AWS::StackId":          "arn:aws:cloudformation:us-east-1:123456789012:stack/placeholder/placeholder",
+	"AWS::StackName":        Placeholder,
+	"AWS::URLSuffix":        "amazonaws.com",
+}
+
+var subReference = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+type Template struct {
+	Path string
+	// Resources are listed in the order of the template.
+	Resources []Resource
+}
+
+type Resource struct {
+	LogicalID string
+	Type      string
+	Line      int
+	Column    int
+	// EndLine is the last line of the definition of the resource.
+	EndLine    int
+	Properties map[string]interface{}
+}
+
+// noValue marks the values of !Ref AWS::NoValue, which are removed from the enclosing object or list.
+type noValue struct{}
+
+func LoadTemplate(path string) (*Template, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	template, err := ParseTemplate(data)
+	if err != nil {
+		return nil, err
+	}
+	template.Path = path
+	return template, nil
+}
+
+// ParseTemplate parses a JSON or YAML template.
+func ParseTemplate(data []byte) (*Template, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("template must be an object")
+	}
+
+	resources := mappingValue(root.Content[0], "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("template has no Resources")
+	}
+
+	template := &Template{}
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		key, node := resources.Content[i], resources.Content[i+1]
+		value, err := convert(node)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %v", key.Value, err)
+		}
+		definition, ok := resolve(value).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource %s must be an object", key.Value)
+		}
+
+		resource := Resource{LogicalID: key.Value, Line: key.Line, Column: key.Column, EndLine: lastLine(node)}
+		resource.Type, _ = definition["Type"].(string)
+		resource.Properties, _ = definition["Properties"].(map[string]interface{})
+		template.Resources = append(template.Resources, resource)
+	}
+	return template, nil
+}
+
+// lastLine returns the line of the last value of a node, which is its last line but for closing brackets.
+func lastLine(node *yaml.Node) int {
+	if len(node.Content) > 0 {
+		return lastLine(node.Content[len(node.Content)-1])
+	}
+	return node.Line + strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n")
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// convert turns a YAML node into plain values, short-form intrinsic tags into their long form.
+func convert(node *yaml.Node) (interface{}, error) {
+	if node.Kind == yaml.AliasNode {
+		return convert(node.Alias)
+	}
+
+	var value interface{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		object := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if _, exists := object[key]; exists {
+				return nil, fmt.Errorf("line %d: duplicate key %q", node.Content[i].Line, key)
+			}
+			item, err := convert(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			object[key] = item
+		}
+		value = object
+	case yaml.SequenceNode:
+		list := []interface{}{}
+		for _, child := range node.Content {
+			item, err := convert(child)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		value = list
+	default:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+			if err := node.Decode(&value); err != nil {
+				return nil, err
+			}
+		default:
+			// Strings, but also dates such as the policy Version, which must stay strings.
+			value = node.Value
+		}
+	}
+
+	if !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return value, nil
+	}
+	name := strings.TrimPrefix(node.Tag, "!")
+	switch name {
+	case "Ref", "Condition":
+		return map[string]interface{}{name: value}, nil
+	case "GetAtt":
+		if attribute, ok := value.(string); ok {
+			value = toInterfaces(strings.SplitN(attribute, ".", 2))
+		}
+	}
+	return map[string]interface{}{"Fn::" + name: value}, nil
+}
+
+// resolve replaces the intrinsic functions of a value with plain values.
+func resolve(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for name, argument := range v {
+				if name == "Ref" || strings.HasPrefix(name, "Fn::") {
+					return resolveIntrinsic(name, argument)
+				}
+			}
+		}
+		object := map[string]interface{}{}
+		for key, item := range v {
+			if resolved := resolve(item); resolved != (noValue{}) {
+				object[key] = resolved
+			}
+		}
+		return object
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range v {
+			if resolved := resolve(item); resolved != (noValue{}) {
+				list = append(list, resolved)
+			}
+		}
+		return list
+	}
+	return value
+}
+
+func resolveIntrinsic(name string, argument interface{}) interface{} {
+	switch name {
+	case "Ref":
+		reference, _ := argument.(string)
+		if reference == "AWS::NoValue" {
+			return noValue{}
+		}
+		if value, ok := pseudoParameters[reference]; ok {
+			return value
+		}
+	case "Fn::Sub":
+		return resolveSub(argument)
+	case "Fn::Join":
+		if arguments, ok := argument.([]interface{}); ok && len(arguments) == 2 {
+			delimiter, _ := arguments[0].(string)
+			if items, ok := resolve(arguments[1]).([]interface{}); ok {
+				parts := make([]string, len(items))
+				for i, item := range items {
+					parts[i], ok = item.(string)
+					if !ok {
+						parts[i] = Placeholder
+					}
+				}
+				return strings.Join(parts, delimiter)
+			}
+		}
+	case "Fn::If":
+		if arguments, ok := argument.([]interface{}); ok && len(arguments) == 3 {
+			if branch := resolve(arguments[1]); branch != (noValue{}) {
+				return branch
+			}
+			return resolve(arguments[2])
+		}
+	case "Fn::Split":
+		return []interface{}{Placeholder}
+	}
+	return Placeholder
+}
+
+// resolveSub resolves the references of a !Sub string, given either as a string or as [string, variables].
+func resolveSub(argument interface{}) interface{} {
+	text, variables := "", map[string]interface{}{}
+	switch v := argument.(type) {
+	case string:
+		text = v
+	case []interface{}:
+		if len(v) != 2 {
+			return Placeholder
+		}
+		text, _ = v[0].(string)
+		if resolved, ok := resolve(v[1]).(map[string]interface{}); ok {
+			variables = resolved
+		}
+	default:
+		return Placeholder
+	}
+
+	return subReference.ReplaceAllStringFunc(text, func(reference string) string {
+		name := reference[2 : len(reference)-1]
+		if strings.HasPrefix(name, "!") {
+			// ${!Literal} is written as ${Literal}.
+			return "${" + name[1:] + "}"
+		}
+		if value, ok := variables[name].(string); ok {
+			return value
+		}
+		if value, ok := pseudoParameters[name]; ok {
+			return value
+		}
+		return Placeholder
+	})
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
@@ -0,0 +1,258 @@
+package cfn
+
+/*
+This file validates the IAM policies embedded in the resources of a template:
+ - AWS::IAM::Role with ValidateRole, which also checks its trust policy and inline policies,
+ - AWS::IAM::Policy, AWS::IAM::ManagedPolicy and the AWS::IAM::*Policy inline policies with ValidateIAMPolicy,
+ - the inline policies of AWS::IAM::User and AWS::IAM::Group,
+ - resource-based policies, such as AWS::S3::BucketPolicy or the KeyPolicy of AWS::KMS::Key,
+   with ValidateResourcePolicy. Resources whose policy property is optional, such as a key or a repository,
+   are valid without one.
+Other resources are ignored.
+
+Values holding a Placeholder are only known once the stack is deployed. They are validated as opaque values: a copy
+of the properties gets distinct stand-ins for them that pass the format checks, so that they count towards the limits
+of a role without being reported as malformed or duplicated.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"strings"
+)
+
+// resourcePolicies maps resource types to the property holding their resource-based policy.
+var resourcePolicies = map[string]string{
+	"AWS::S3::BucketPolicy":               "PolicyDocument",
+	"AWS::SQS::QueuePolicy":               "PolicyDocument",
+	"AWS::SNS::TopicPolicy":               "PolicyDocument",
+	"AWS::KMS::Key":                       "KeyPolicy",
+	"AWS::SecretsManager::ResourcePolicy": "ResourcePolicy",
+	"AWS::ECR::Repository":                "RepositoryPolicyText",
+	"AWS::ApiGateway::RestApi":            "Policy",
+	"AWS::Backup::BackupVault":            "AccessPolicy",
+	"AWS::EFS::FileSystem":                "FileSystemPolicy",
+	"AWS::OpenSearchService::Domain":      "AccessPolicies",
+}
+
+// optionalPolicies lists the resource types that are valid without their policy property.
+var optionalPolicies = map[string]bool{
+	"AWS::KMS::Key":                  true,
+	"AWS::ECR::Repository":           true,
+	"AWS::ApiGateway::RestApi":       true,
+	"AWS::Backup::BackupVault":       true,
+	"AWS::EFS::FileSystem":           true,
+	"AWS::OpenSearchService::Domain": true,
+}
+
+// identityPolicyNames maps standalone identity policy types to the property holding their name.
+var identityPolicyNames = map[string]string{
+	"AWS::IAM::Policy":        "PolicyName",
+	"AWS::IAM::ManagedPolicy": "ManagedPolicyName",
+	"AWS::IAM::RolePolicy":    "PolicyName",
+	"AWS::IAM::UserPolicy":    "PolicyName",
+	"AWS::IAM::GroupPolicy":   "PolicyName",
+}
+
+type Result struct {
+	LogicalID string
+	Type      string
+	Line      int
+	Column    int
+	EndLine   int
+	// Err is nil when every policy of the resource is valid.
+	Err error
+}
+
+func (r Result) String() string {
+	if r.Err == nil {
+		return fmt.Sprintf("%s (%s)", r.LogicalID, r.Type)
+	}
+	return fmt.Sprintf("%s (%s) line %d: %s", r.LogicalID, r.Type, r.Line, r.Err)
+}
+
+// Validate validates the policies of every supported resource of the template, in template order.
+func (t *Template) Validate() []Result {
+	var results []Result
+	for _, resource := range t.Resources {
+		if Supported(resource.Type) {
+			err := validateResource(resource)
+			results = append(results, Result{LogicalID: resource.LogicalID, Type: resource.Type,
+				Line: resource.Line, Column: resource.Column, EndLine: resource.EndLine, Err: err})
+		}
+	}
+	return results
+}
+
+// Supported reports whether the policies of a resource type are validated.
+func Supported(resourceType string) bool {
+	_, identity := identityPolicyNames[resourceType]
+	_, resource := resourcePolicies[resourceType]
+	return identity || resource || resourceType == "AWS::IAM::Role" ||
+		resourceType == "AWS::IAM::User" || resourceType == "AWS::IAM::Group"
+}
+
+func validateResource(resource Resource) error {
+	properties, _ := opaque(resource.Properties).(map[string]interface{})
+
+	switch resource.Type {
+	case "AWS::IAM::Role":
+		return validateRole(properties)
+	case "AWS::IAM::User", "AWS::IAM::Group":
+		policies, _ := properties["Policies"].([]interface{})
+		for i, policy := range policies {
+			object, _ := policy.(map[string]interface{})
+			name, _ := object["PolicyName"].(string)
+			if err := validateIdentityPolicy(name, object["PolicyDocument"]); err != nil {
+				return fmt.Errorf("Policies[%d]: %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	if nameProperty, ok := identityPolicyNames[resource.Type]; ok {
+		name, _ := properties[nameProperty].(string)
+		if name == "" {
+			// Managed policies are named by CloudFormation when ManagedPolicyName is omitted.
+			name = resource.LogicalID
+		}
+		if err := validateIdentityPolicy(name, properties["PolicyDocument"]); err != nil {
+			return fmt.Errorf("PolicyDocument: %w", err)
+		}
+		return nil
+	}
+
+	property := resourcePolicies[resource.Type]
+	if properties[property] == nil && optionalPolicies[resource.Type] {
+		// Keys get the default key policy, and the other resources no policy at all.
+		return nil
+	}
+	document, err := decodeDocument(properties[property])
+	if err == nil && resource.Type == "AWS::KMS::Key" {
+		_, err = validator.ValidateKeyPolicy(document)
+	} else if err == nil {
+		_, err = validator.ValidateResourcePolicy(document)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", property, err)
+	}
+	return nil
+}
+
+// validateRole validates the properties of a role, which it may change: they are a copy made by opaque.
+func validateRole(properties map[string]interface{}) error {
+	// Values only known once deployed cannot be checked against ARN patterns or numeric bounds, but still count.
+	if arns, ok := properties["ManagedPolicyArns"].([]interface{}); ok {
+		for i, arn := range arns {
+			if value, ok := arn.(string); ok && strings.Contains(value, Placeholder) {
+				arns[i] = fmt.Sprintf("arn:aws:iam::aws:policy/%s-%d", Placeholder, i)
+			}
+		}
+	}
+	if policies, ok := properties["Policies"].([]interface{}); ok {
+		for i, policy := range policies {
+			object, _ := policy.(map[string]interface{})
+			if name, ok := object["PolicyName"].(string); ok && strings.Contains(name, Placeholder) {
+				object["PolicyName"] = fmt.Sprintf("%s-%d", Placeholder, i)
+			}
+		}
+	}
+	if boundary, ok := properties["PermissionsBoundary"].(string); ok && strings.Contains(boundary, Placeholder) {
+		delete(properties, "PermissionsBoundary")
+	}
+	if duration, ok := properties["MaxSessionDuration"].(string); ok && strings.Contains(duration, Placeholder) {
+		delete(properties, "MaxSessionDuration")
+	}
+	if document, ok := properties["AssumeRolePolicyDocument"].(string); ok {
+		properties["AssumeRolePolicyDocument"] = parseDocumentString(document)
+	}
+
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return err
+	}
+	role, err := validator.DecodeRole(data)
+	if err != nil {
+		return err
+	}
+	return validator.ValidateRole(role)
+}
+
+// opaque returns a copy of a value in which every action holding a Placeholder but no service prefix, such as
+// !Ref Action, gets the Placeholder service, so that it passes the action format check.
+func opaque(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if key == "Action" || key == "NotAction" {
+				item = opaqueActions(item)
+			}
+			object[key] = opaque(item)
+		}
+		return object
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = opaque(item)
+		}
+		return list
+	}
+	return value
+}
+
+func opaqueActions(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, Placeholder) && !strings.Contains(v, ":") {
+			return Placeholder + ":" + v
+		}
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = opaqueActions(item)
+		}
+		return list
+	}
+	return value
+}
+
+func validateIdentityPolicy(name string, document interface{}) error {
+	if text, ok := document.(string); ok {
+		document = parseDocumentString(text)
+	}
+	data, err := json.Marshal(map[string]interface{}{"PolicyName": name, "PolicyDocument": document})
+	if err != nil {
+		return err
+	}
+	policy, err := validator.DecodePolicy(data)
+	if err != nil {
+		return err
+	}
+	return validator.ValidateIAMPolicy(policy)
+}
+
+func decodeDocument(value interface{}) (validator.PolicyDocument, error) {
+	if value == nil {
+		return validator.PolicyDocument{}, fmt.Errorf("policy document is missing")
+	}
+	if text, ok := value.(string); ok {
+		value = parseDocumentString(text)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return validator.PolicyDocument{}, err
+	}
+	return validator.DecodePolicyDocument(data)
+}
+
+// parseDocumentString parses a policy document written as a JSON string, which some properties accept.
+// A string that is not JSON is returned as it is, so that decoding it reports the error.
+func parseDocumentString(text string) interface{} {
+	var document interface{}
+	if err := json.Unmarshal([]byte(text), &document); err != nil {
+		return text
+	}
+	return document
+}
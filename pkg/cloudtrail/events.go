@@ -0,0 +1,144 @@
+package cloudtrail
+
+/*
+This file reads CloudTrail log files from disk, as they are delivered to S3 and exported from it:
+gzipped (or plain) JSON documents holding a "Records" array of events.
+Directories are walked recursively and every .json or .json.gz file in them is read.
+
+More information about the CloudTrail record format can be found here:
+ - https://docs.aws.amazon.com/awscloudtrail/latest/userguide/cloudtrail-event-reference-record-contents.html
+*/
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Event struct {
+	EventTime          time.Time              `json:"eventTime"`
+	EventSource        string                 `json:"eventSource"`
+	EventName          string                 `json:"eventName"`
+	AWSRegion          string                 `json:"awsRegion"`
+	ErrorCode          string                 `json:"errorCode,omitempty"`
+	RecipientAccountID string                 `json:"recipientAccountId"`
+	UserIdentity       UserIdentity           `json:"userIdentity"`
+	RequestParameters  map[string]interface{} `json:"requestParameters,omitempty"`
+	Resources          []Resource             `json:"resources,omitempty"`
+}
+
+type UserIdentity struct {
+	Type           string `json:"type"`
+	ARN            string `json:"arn"`
+	AccountID      string `json:"accountId"`
+	SessionContext struct {
+		SessionIssuer struct {
+			Type string `json:"type"`
+			ARN  string `json:"arn"`
+		} `json:"sessionIssuer"`
+	} `json:"sessionContext"`
+}
+
+type Resource struct {
+	ARN       string `json:"ARN"`
+	AccountID string `json:"accountId"`
+	Type      string `json:"type"`
+}
+
+// Filter selects the events made by a role within a time window. Zero values disable the corresponding check.
+type Filter struct {
+	RoleARN string
+	Since   time.Time
+	Until   time.Time
+}
+
+var assumedRoleARN = regexp.MustCompile(`^arn:([\w-]+):sts::(\d{12}):assumed-role/([\w+=,.@-]+)/`)
+
+// ReadEvents reads the events of every CloudTrail log file found under the given paths.
+func ReadEvents(paths []string) ([]Event, error) {
+	var events []Event
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !(strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".json.gz")) {
+				return nil
+			}
+			fileEvents, err := readFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			events = append(events, fileEvents...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func readFile(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var log struct {
+		Records []Event `json:"Records"`
+	}
+	if err := json.NewDecoder(reader).Decode(&log); err != nil {
+		return nil, err
+	}
+	return log.Records, nil
+}
+
+func (f Filter) Match(event Event) bool {
+	if !f.Since.IsZero() && event.EventTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && event.EventTime.After(f.Until) {
+		return false
+	}
+	return f.RoleARN == "" || event.madeBy(f.RoleARN)
+}
+
+// madeBy reports whether the event was made by the role, directly or through a session of the role.
+func (e Event) madeBy(roleARN string) bool {
+	identity := e.UserIdentity
+	if identity.ARN == roleARN || identity.SessionContext.SessionIssuer.ARN == roleARN {
+		return true
+	}
+
+	// Without a session issuer, compare the assumed-role ARN with the role name, ignoring the role path.
+	match := assumedRoleARN.FindStringSubmatch(identity.ARN)
+	if match == nil {
+		return false
+	}
+	return strings.HasPrefix(roleARN, fmt.Sprintf("arn:%s:iam::%s:role/", match[1], match[2])) &&
+		strings.HasSuffix(roleARN, "/"+match[3])
+}
+
+// denied reports whether the call was refused for lack of permissions, so that it does not prove the permission is needed.
+func (e Event) denied() bool {
+	return strings.Contains(e.ErrorCode, "AccessDenied") || strings.Contains(e.ErrorCode, "Unauthorized")
+}
@@ -0,0 +1,286 @@
+package cloudtrail
+
+/*
+This file builds a least-privilege policy from the activity recorded in CloudTrail.
+Each event is mapped to the IAM action authorizing it using the catalog, and to the resources it was made on:
+the resources listed in the event, or ARNs derived from its request parameters for common services,
+or, when neither is available, every resource of the service in the account and region of the event.
+Calls refused with an AccessDenied error are ignored, as they do not prove the permission is needed.
+
+Actions used on the same resources are grouped in one statement, and the result must pass ValidateIAMPolicy.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxObjectsPerBucket is the number of S3 object ARNs of a bucket listed as they are before being replaced by a prefix.
+const maxObjectsPerBucket = 5
+
+type ObservedAction struct {
+	Action string
+	// Known is false when the catalog does not know the action, which was then named after the event.
+	Known     bool
+	Resources map[string]bool
+	Calls     int
+	LastUsed  time.Time
+}
+
+type Activity struct {
+	Events  int
+	Actions map[string]*ObservedAction
+}
+
+// Observe collects the actions and resources used in the events matching the filter.
+func Observe(events []Event, filter Filter) Activity {
+	activity := Activity{Actions: map[string]*ObservedAction{}}
+	for _, event := range events {
+		if !filter.Match(event) || event.denied() {
+			continue
+		}
+		activity.Events++
+
+		action, known := catalog.ActionForEvent(event.EventSource, event.EventName)
+		observed, ok := activity.Actions[action]
+		if !ok {
+			observed = &ObservedAction{Action: action, Known: known, Resources: map[string]bool{}}
+			activity.Actions[action] = observed
+		}
+		observed.Calls++
+		if event.EventTime.After(observed.LastUsed) {
+			observed.LastUsed = event.EventTime
+		}
+		for _, resource := range eventResources(event, action) {
+			observed.Resources[resource] = true
+		}
+	}
+	return activity
+}
+
+// SortedActions returns the observed actions ordered by name.
+func (a Activity) SortedActions() []*ObservedAction {
+	actions := make([]*ObservedAction, 0, len(a.Actions))
+	for _, action := range a.Actions {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Action < actions[j].Action })
+	return actions
+}
+
+// Generate returns a policy granting exactly the observed actions on the observed resources.
+func Generate(activity Activity, policyName string) (validator.IAMPolicy, error) {
+	if len(activity.Actions) == 0 {
+		return validator.IAMPolicy{}, fmt.Errorf("no activity matches the filter")
+	}
+
+	grouped := map[string][]string{}
+	resourceSets := map[string][]string{}
+	for _, observed := range activity.SortedActions() {
+		resources := collapseObjects(observed.Resources)
+		key := strings.Join(resources, "\n")
+		grouped[key] = append(grouped[key], observed.Action)
+		resourceSets[key] = resources
+	}
+
+	keys := make([]string, 0, len(grouped))
+	for key := range grouped {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return grouped[keys[i]][0] < grouped[keys[j]][0] })
+
+	policy := validator.IAMPolicy{
+		PolicyName:     policyName,
+		PolicyDocument: validator.PolicyDocument{Version: "2012-10-17"},
+	}
+	sids := map[string]int{}
+	for _, key := range keys {
+		policy.PolicyDocument.Statement = append(policy.PolicyDocument.Statement, validator.Statement{
+			Sid:      statementSid(grouped[key], sids),
+			Effect:   "Allow",
+			Action:   toInterfaces(grouped[key]),
+			Resource: toInterfaces(resourceSets[key]),
+		})
+	}
+
+	if err := validator.ValidateIAMPolicy(policy); err != nil {
+		return validator.IAMPolicy{}, fmt.Errorf("generated policy is not valid: %v", err)
+	}
+	return policy, nil
+}
+
+// eventResources returns the ARNs of the resources an event was made on.
+func eventResources(event Event, action string) []string {
+	prefix := action[:strings.Index(action, ":")]
+
+	var resources []string
+	for _, resource := range event.Resources {
+		if resource.ARN == "" {
+			continue
+		}
+		// S3 data events list both the bucket and the object, keep the one the action applies to.
+		if prefix == "s3" && (resource.Type == "AWS::S3::Object") != isObjectAction(action) {
+			continue
+		}
+		resources = append(resources, resource.ARN)
+	}
+	if len(resources) > 0 {
+		return resources
+	}
+
+	if resource := requestResource(event, prefix, action); resource != "" {
+		return []string{resource}
+	}
+
+	region := event.AWSRegion
+	if prefix == "iam" || prefix == "s3" {
+		region = ""
+	}
+	account := event.RecipientAccountID
+	if prefix == "s3" {
+		account = ""
+	}
+	return []string{fmt.Sprintf("arn:aws:%s:%s:%s:*", prefix, region, account)}
+}
+
+// requestResource derives the ARN of the resource from the request parameters of common services.
+func requestResource(event Event, prefix, action string) string {
+	parameter := func(name string) string {
+		value, _ := event.RequestParameters[name].(string)
+		return value
+	}
+	region, account := event.AWSRegion, event.RecipientAccountID
+
+	switch prefix {
+	case "s3":
+		bucket := parameter("bucketName")
+		if bucket == "" {
+			return ""
+		}
+		if key := parameter("key"); key != "" && isObjectAction(action) {
+			return fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, key)
+		}
+		return "arn:aws:s3:::" + bucket
+	case "sqs":
+		// https://sqs.us-east-1.amazonaws.com/123456789012/queue-name
+		parts := strings.Split(parameter("queueUrl"), "/")
+		if len(parts) < 5 {
+			return ""
+		}
+		return fmt.Sprintf("arn:aws:sqs:%s:%s:%s", region, parts[3], parts[4])
+	case "sns":
+		return parameter("topicArn")
+	case "dynamodb":
+		if table := parameter("tableName"); table != "" {
+			return fmt.Sprintf("arn:aws:dynamodb:%s:%s:table/%s", region, account, table)
+		}
+	case "lambda":
+		if function := parameter("functionName"); strings.HasPrefix(function, "arn:") {
+			return function
+		} else if function != "" {
+			return fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", region, account, function)
+		}
+	case "secretsmanager":
+		if secret := parameter("secretId"); strings.HasPrefix(secret, "arn:") {
+			return secret
+		} else if secret != "" {
+			// Secret ARNs end with a random 6 characters suffix.
+			return fmt.Sprintf("arn:aws:secretsmanager:%s:%s:secret:%s-??????", region, account, secret)
+		}
+	case "logs":
+		if group := parameter("logGroupName"); group != "" {
+			return fmt.Sprintf("arn:aws:logs:%s:%s:log-group:%s:*", region, account, group)
+		}
+	}
+	return ""
+}
+
+func isObjectAction(action string) bool {
+	return strings.Contains(action, "Object") || strings.Contains(action, "MultipartUpload")
+}
+
+// collapseObjects replaces the object ARNs of buckets with many observed objects by their longest common key prefix.
+func collapseObjects(resources map[string]bool) []string {
+	objects := map[string][]string{}
+	var result []string
+	for resource := range resources {
+		bucketPath := strings.TrimPrefix(resource, "arn:aws:s3:::")
+		if bucketPath == resource || !strings.Contains(bucketPath, "/") {
+			result = append(result, resource)
+			continue
+		}
+		bucket := bucketPath[:strings.Index(bucketPath, "/")]
+		objects[bucket] = append(objects[bucket], bucketPath[len(bucket)+1:])
+	}
+
+	for bucket, keys := range objects {
+		if len(keys) <= maxObjectsPerBucket {
+			for _, key := range keys {
+				result = append(result, fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, key))
+			}
+			continue
+		}
+		prefix := commonPrefix(keys)
+		if i := strings.LastIndex(prefix, "/"); i != -1 {
+			prefix = prefix[:i+1]
+		} else {
+			prefix = ""
+		}
+		result = append(result, fmt.Sprintf("arn:aws:s3:::%s/%s*", bucket, prefix))
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// statementSid names a statement after the services of its actions, e.g. S3SqsAccess.
+func statementSid(actions []string, used map[string]int) string {
+	var sb strings.Builder
+	seen := map[string]bool{}
+	for _, action := range actions {
+		prefix := action[:strings.Index(action, ":")]
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		for i, r := range prefix {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				continue
+			}
+			if i == 0 {
+				r = unicode.ToUpper(r)
+			}
+			sb.WriteRune(r)
+		}
+	}
+	sid := sb.String() + "Access"
+
+	used[sid]++
+	if used[sid] > 1 {
+		sid = fmt.Sprintf("%s%d", sid, used[sid])
+	}
+	return sid
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
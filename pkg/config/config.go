@@ -0,0 +1,267 @@
+package config
+
+/*
+This file loads the settings of the verifier from a .iam-verifier.yaml file. The file is found by walking up from
+the working directory, the way git finds its repository, so that it can sit at the root of a repository and apply
+to all of it. The CLI, the server and the report package use the same Config; without a file, Default applies.
+
+Every setting is optional:
+
+	rules:
+	  enabled: [redundantStatement, shadowedStatement]  # lint rules to run, all when empty
+	  disabled: [wildcardResource]                      # rules never reported, validation rules included
+	  severity:
+	    legacyVersion: error
+	policyTypes:                                        # policy type of the files matching a glob, first match wins
+	  - glob: buckets/**
+	    type: resource
+	allowedAccounts: ["123456789012"]                   # accounts ARNs and principals may refer to, any when empty
+	output:
+	  format: sarif
+	server:
+	  addr: ":9090"
+	  maxBodyBytes: 1048576
+
+Globs and paths are relative to the directory holding the file.
+*/
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/glob"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const FileName = ".iam-verifier.yaml"
+
+// policyTypes and formats are those of the report package, which uses this package.
+var (
+	policyTypes = []string{"identity", "resource", "key", "trust"}
+	formats     = []string{"text", "json", "ndjson", "junit", "sarif"}
+)
+
+type Config struct {
+	// Path is the file the configuration was loaded from, empty for the defaults.
+	Path            string           `yaml:"-"`
+	Rules           Rules            `yaml:"rules"`
+	PolicyTypes     []PolicyTypeRule `yaml:"policyTypes"`
+	AllowedAccounts []string         `yaml:"allowedAccounts"`
+	Output          Output           `yaml:"output"`
+	Server          Server           `yaml:"server"`
+}
+
+type Rules struct {
+	Enabled  []string                 `yaml:"enabled"`
+	Disabled []string                 `yaml:"disabled"`
+	Severity map[string]lint.Severity `yaml:"severity"`
+}
+
+type PolicyTypeRule struct {
+	Glob string `yaml:"glob"`
+	Type string `yaml:"type"`
+}
+
+type Output struct {
+	Format string `yaml:"format"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr"`
+	// MaxBodyBytes limits the size of the requests, and of each policy of a batch, 0 for no limit.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes"`
+	// MaxBatchBytes limits the size of the batch requests, and of the policies of an archive once decompressed,
+	// 0 for no limit.
+	MaxBatchBytes int64 `yaml:"maxBatchBytes"`
+	// MaxBatchItems limits the number of policies of a batch request, 0 for no limit.
+	MaxBatchItems int `yaml:"maxBatchItems"`
+}
+
+// Default returns the settings used without a configuration file.
+func Default() *Config {
+	return &Config{
+		Output: Output{Format: "text"},
+		Server: Server{Addr: ":8080", MaxBodyBytes: 1 << 20, MaxBatchBytes: 32 << 20, MaxBatchItems: 1000},
+	}
+}
+
+// Find returns the configuration file of dir or of its closest parent, or "" when there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Discover loads the configuration file found from the working directory, or the defaults when there is none.
+func Discover() (*Config, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	path, err := Find(dir)
+	if err != nil || path == "" {
+		return Default(), err
+	}
+	return Load(path)
+}
+
+// Load reads a configuration file. Settings it leaves out keep their default value.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := Default()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	config.Path = path
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	rules := map[string]bool{}
+	for _, key := range validator.ErrorKeys() {
+		rules[key] = true
+	}
+	lintRules := map[string]bool{}
+	for _, rule := range lint.Rules() {
+		lintRules[rule.ID] = true
+	}
+
+	for _, rule := range c.Rules.Enabled {
+		if !lintRules[rule] {
+			return fmt.Errorf("rules.enabled: unknown lint rule %q", rule)
+		}
+	}
+	for _, rule := range c.Rules.Disabled {
+		if !rules[rule] {
+			return fmt.Errorf("rules.disabled: unknown rule %q", rule)
+		}
+	}
+	for rule, severity := range c.Rules.Severity {
+		if !rules[rule] {
+			return fmt.Errorf("rules.severity: unknown rule %q", rule)
+		}
+		if severity != lint.SeverityError && severity != lint.SeverityWarning && severity != lint.SeverityInfo {
+			return fmt.Errorf("rules.severity: %s: severity must be error, warning or info, got %q", rule, severity)
+		}
+	}
+	for i, rule := range c.PolicyTypes {
+		if rule.Glob == "" || !glob.Valid(rule.Glob) {
+			return fmt.Errorf("policyTypes[%d]: invalid glob %q", i, rule.Glob)
+		}
+		if !contains(policyTypes, rule.Type) {
+			return fmt.Errorf("policyTypes[%d]: type must be one of %s, got %q", i, strings.Join(policyTypes, ", "), rule.Type)
+		}
+	}
+	for _, account := range c.AllowedAccounts {
+		if validator.ARNAccount(account) != account {
+			return fmt.Errorf("allowedAccounts: %q is not a 12-digit account ID", account)
+		}
+	}
+	if !contains(formats, c.Output.Format) {
+		return fmt.Errorf("output.format must be one of %s, got %q", strings.Join(formats, ", "), c.Output.Format)
+	}
+	if c.Server.MaxBodyBytes < 0 {
+		return fmt.Errorf("server.maxBodyBytes cannot be negative")
+	}
+	if c.Server.MaxBatchBytes < 0 {
+		return fmt.Errorf("server.maxBatchBytes cannot be negative")
+	}
+	if c.Server.MaxBatchItems < 0 {
+		return fmt.Errorf("server.maxBatchItems cannot be negative")
+	}
+	return nil
+}
+
+// Dir returns the directory the paths and globs of the configuration are relative to.
+func (c *Config) Dir() string {
+	if c.Path == "" {
+		return "."
+	}
+	return filepath.Dir(c.Path)
+}
+
+// RuleEnabled reports whether the findings of a rule are reported.
+func (c *Config) RuleEnabled(rule string) bool {
+	if contains(c.Rules.Disabled, rule) {
+		return false
+	}
+	if len(c.Rules.Enabled) == 0 {
+		return true
+	}
+	// Enabled only selects lint rules, validation rules are always on unless disabled.
+	for _, lintRule := range lint.Rules() {
+		if lintRule.ID == rule {
+			return contains(c.Rules.Enabled, rule)
+		}
+	}
+	return true
+}
+
+// Severity returns the severity of the findings of a rule, severity unless it is overridden.
+func (c *Config) Severity(rule string, severity lint.Severity) lint.Severity {
+	if override, ok := c.Rules.Severity[rule]; ok {
+		return override
+	}
+	return severity
+}
+
+// PolicyType returns the policy type of the first glob matching path, or "" when none matches.
+func (c *Config) PolicyType(path string) string {
+	name := filepath.ToSlash(path)
+	if absolute, err := filepath.Abs(path); err == nil {
+		if dir, err := filepath.Abs(c.Dir()); err == nil {
+			if relative, err := filepath.Rel(dir, absolute); err == nil && !strings.HasPrefix(relative, "..") {
+				name = filepath.ToSlash(relative)
+			}
+		}
+	}
+	for _, rule := range c.PolicyTypes {
+		if glob.Match(rule.Glob, name) {
+			return rule.Type
+		}
+	}
+	return ""
+}
+
+// AccountAllowed reports whether policies may refer to an account. Any account is allowed when none is listed.
+func (c *Config) AccountAllowed(account string) bool {
+	return len(c.AllowedAccounts) == 0 || contains(c.AllowedAccounts, account)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
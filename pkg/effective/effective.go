@@ -0,0 +1,348 @@
+package effective
+
+/*
+This file computes the effective permissions of a role from all the policies that apply to it:
+its identity policies (inline and managed), its permission boundary and the service control policies (SCPs)
+of its organization. A request is allowed when:
+ - an identity policy allows it,
+ - the permission boundary, if any, allows it,
+ - at every level of the organization, one of the SCPs attached at that level allows it,
+ - and no statement of any of these policies denies it.
+
+The result lists each allowed action with the resource patterns it is allowed on. Wildcard actions are expanded
+with the catalog, patterns it cannot expand are kept as they are. Resources are intersected pattern by pattern:
+when neither of two patterns covers the other, the permission is kept and marked Partial. Conditions are not
+evaluated, permissions depending on them are marked Conditional.
+
+More information about the evaluation logic can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_evaluation-logic.html
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+	"strings"
+)
+
+type PolicySet struct {
+	// Identity holds the inline and managed policies of the role.
+	Identity []validator.IAMPolicy
+	// Boundary is the permission boundary of the role, nil when it has none.
+	Boundary *validator.IAMPolicy
+	// SCPs holds the SCPs attached at each level of the organization above the account, e.g. root, OU, account.
+	SCPs [][]validator.IAMPolicy
+}
+
+type Permission struct {
+	Action   string
+	Resource string
+	// Conditional is true when a condition of an allowing or denying statement decides at request time.
+	Conditional bool
+	// Partial is true when only some of the requests matching Action and Resource are allowed,
+	// e.g. when a deny or the boundary applies to part of the resources.
+	Partial bool
+	// Sources are the identity policy statements granting the permission.
+	Sources []simulator.StatementRef
+}
+
+type Result struct {
+	Permissions []Permission
+	// Sensitive lists the effective permissions that can change permissions or escalate privileges.
+	Sensitive []SensitiveGrant
+	// Notes explains where the result is approximated.
+	Notes []string
+}
+
+type SensitiveGrant struct {
+	Permission
+	Reason string
+}
+
+type match int
+
+const (
+	noMatch match = iota
+	partialMatch
+	fullMatch
+)
+
+// Compute returns the effective permissions of the policy set.
+func Compute(set PolicySet) Result {
+	var result Result
+	permissions := grants(set.Identity, &result.Notes)
+
+	var gates [][]validator.IAMPolicy
+	if set.Boundary != nil {
+		gates = append(gates, []validator.IAMPolicy{*set.Boundary})
+	}
+	gates = append(gates, set.SCPs...)
+	for _, gate := range gates {
+		var restricted []Permission
+		for _, permission := range permissions {
+			restricted = append(restricted, restrict(permission, gate)...)
+		}
+		permissions = restricted
+	}
+
+	all := append([]validator.IAMPolicy{}, set.Identity...)
+	for _, gate := range gates {
+		all = append(all, gate...)
+	}
+	var allowed []Permission
+	for _, permission := range permissions {
+		if permission, ok := applyDenies(permission, all); ok {
+			allowed = append(allowed, permission)
+		}
+	}
+
+	result.Permissions = merge(allowed)
+	for _, permission := range result.Permissions {
+		if reason := sensitive(permission.Action); reason != "" {
+			result.Sensitive = append(result.Sensitive, SensitiveGrant{Permission: permission, Reason: reason})
+		}
+	}
+	return result
+}
+
+// Allows reports whether the effective permissions include the action on some resource.
+func (r Result) Allows(action string) bool {
+	for _, permission := range r.Permissions {
+		if simulator.MatchAction(permission.Action, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Permission) String() string {
+	var flags []string
+	if p.Conditional {
+		flags = append(flags, "conditional")
+	}
+	if p.Partial {
+		flags = append(flags, "partial")
+	}
+	if len(flags) == 0 {
+		return fmt.Sprintf("%s on %s", p.Action, p.Resource)
+	}
+	return fmt.Sprintf("%s on %s (%s)", p.Action, p.Resource, strings.Join(flags, ", "))
+}
+
+// grants returns the permissions allowed by the identity policies, before any restriction.
+func grants(policies []validator.IAMPolicy, notes *[]string) []Permission {
+	var permissions []Permission
+	for _, policy := range policies {
+		for i, statement := range policy.PolicyDocument.Statement {
+			if statement.Effect != "Allow" {
+				continue
+			}
+			source := simulator.StatementRef{PolicyName: policy.PolicyName, Index: i, Sid: statement.Sid}
+
+			var actions []string
+			if statement.NotAction != nil {
+				actions = notActions(statement)
+				*notes = append(*notes, fmt.Sprintf("%s statement %d uses NotAction: only the actions of the catalog are listed, "+
+					"but it also allows every action of the other services", policy.PolicyName, i+1))
+			}
+			for _, pattern := range validator.StringValues(statement.Action) {
+				if expanded, ok := catalog.Expand(pattern); ok {
+					actions = append(actions, expanded...)
+				} else {
+					actions = append(actions, pattern)
+				}
+			}
+
+			resources, partial := validator.StringValues(statement.Resource), false
+			if statement.NotResource != nil {
+				resources, partial = []string{"*"}, true
+			}
+			for _, action := range actions {
+				for _, resource := range resources {
+					permissions = append(permissions, Permission{
+						Action:      action,
+						Resource:    resource,
+						Conditional: len(statement.Conditions) > 0,
+						Partial:     partial,
+						Sources:     []simulator.StatementRef{source},
+					})
+				}
+			}
+		}
+	}
+	return permissions
+}
+
+// notActions returns the catalog actions an Allow statement with NotAction applies to.
+func notActions(statement validator.Statement) []string {
+	var actions []string
+	for _, service := range catalog.Services() {
+		for _, action := range service.ActionNames() {
+			if actionMatch(statement, action) == fullMatch {
+				actions = append(actions, action)
+			}
+		}
+	}
+	return actions
+}
+
+// restrict returns the parts of the permission allowed by one of the policies of a gate,
+// i.e. the permission boundary or the SCPs of one level of the organization.
+func restrict(permission Permission, gate []validator.IAMPolicy) []Permission {
+	var restricted []Permission
+	for _, policy := range gate {
+		for _, statement := range policy.PolicyDocument.Statement {
+			if statement.Effect != "Allow" {
+				continue
+			}
+			actionCoverage := actionMatch(statement, permission.Action)
+			if actionCoverage == noMatch {
+				continue
+			}
+			for _, part := range resourceMatches(statement, permission.Resource) {
+				narrowed := permission
+				narrowed.Resource = part.resource
+				narrowed.Partial = permission.Partial || part.partial || actionCoverage == partialMatch
+				narrowed.Conditional = permission.Conditional || len(statement.Conditions) > 0
+				restricted = append(restricted, narrowed)
+			}
+		}
+	}
+	return restricted
+}
+
+// applyDenies removes the permission when a statement denies it entirely and unconditionally,
+// and marks it Partial or Conditional when a statement denies part of it or denies it under conditions.
+func applyDenies(permission Permission, policies []validator.IAMPolicy) (Permission, bool) {
+	for _, policy := range policies {
+		for _, statement := range policy.PolicyDocument.Statement {
+			if statement.Effect != "Deny" {
+				continue
+			}
+			actionCoverage := actionMatch(statement, permission.Action)
+			if actionCoverage == noMatch {
+				continue
+			}
+			parts := resourceMatches(statement, permission.Resource)
+			if len(parts) == 0 {
+				continue
+			}
+
+			denied := actionCoverage == fullMatch && len(statement.Conditions) == 0
+			covered := false
+			for _, part := range parts {
+				covered = covered || (!part.partial && part.resource == permission.Resource)
+			}
+			switch {
+			case denied && covered:
+				return Permission{}, false
+			case len(statement.Conditions) > 0:
+				permission.Conditional = true
+			default:
+				permission.Partial = true
+			}
+		}
+	}
+	return permission, true
+}
+
+// actionMatch tells whether a statement applies to all, some or none of the actions matched by an action pattern.
+func actionMatch(statement validator.Statement, action string) match {
+	action = strings.ToLower(action)
+	covered, overlapping := false, false
+	for _, pattern := range append(validator.StringValues(statement.Action), validator.StringValues(statement.NotAction)...) {
+		pattern = strings.ToLower(pattern)
+		covered = covered || simulator.PatternCovers(pattern, action)
+		overlapping = overlapping || simulator.PatternsOverlap(pattern, action)
+	}
+	return coverage(statement.NotAction != nil, covered, overlapping)
+}
+
+type resourcePart struct {
+	resource string
+	partial  bool
+}
+
+// resourceMatches returns the parts of a resource pattern a statement applies to.
+func resourceMatches(statement validator.Statement, resource string) []resourcePart {
+	if statement.NotResource != nil {
+		covered, overlapping := false, false
+		for _, pattern := range validator.StringValues(statement.NotResource) {
+			covered = covered || simulator.PatternCovers(pattern, resource)
+			overlapping = overlapping || simulator.PatternsOverlap(pattern, resource)
+		}
+		switch coverage(true, covered, overlapping) {
+		case fullMatch:
+			return []resourcePart{{resource: resource}}
+		case partialMatch:
+			return []resourcePart{{resource: resource, partial: true}}
+		}
+		return nil
+	}
+
+	var parts []resourcePart
+	for _, pattern := range validator.StringValues(statement.Resource) {
+		switch {
+		case simulator.PatternCovers(pattern, resource):
+			parts = append(parts, resourcePart{resource: resource})
+		case simulator.PatternCovers(resource, pattern):
+			parts = append(parts, resourcePart{resource: pattern})
+		case simulator.PatternsOverlap(pattern, resource):
+			parts = append(parts, resourcePart{resource: resource, partial: true})
+		}
+	}
+	return parts
+}
+
+// coverage converts how the patterns of an element compare to a value into how the element applies to it.
+func coverage(not, covered, overlapping bool) match {
+	switch {
+	case !not && covered, not && !overlapping:
+		return fullMatch
+	case !not && overlapping, not && !covered:
+		return partialMatch
+	}
+	return noMatch
+}
+
+// merge combines the permissions on the same action and resource.
+func merge(permissions []Permission) []Permission {
+	var merged []Permission
+	index := map[string]int{}
+	for _, permission := range permissions {
+		key := strings.ToLower(permission.Action) + "\n" + permission.Resource
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, permission)
+			continue
+		}
+		existing := &merged[i]
+		existing.Conditional = existing.Conditional && permission.Conditional
+		existing.Partial = existing.Partial && permission.Partial
+		for _, source := range permission.Sources {
+			if !hasSource(existing.Sources, source) {
+				existing.Sources = append(existing.Sources, source)
+			}
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Action != merged[j].Action {
+			return merged[i].Action < merged[j].Action
+		}
+		return merged[i].Resource < merged[j].Resource
+	})
+	return merged
+}
+
+func hasSource(sources []simulator.StatementRef, source simulator.StatementRef) bool {
+	for _, existing := range sources {
+		if existing == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package effective
+
+/*
+This file decides which effective permissions are sensitive, i.e. let the role change permissions,
+act as another principal or read secrets. They are:
+ - the actions of the Permissions management access level of the catalog,
+ - actions known to lead to privilege escalation or to expose credentials,
+ - wildcards over services the catalog does not know, which may include any of the above.
+
+More information about privilege escalation paths can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/best-practices.html#grant-least-privilege
+*/
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"strings"
+)
+
+var escalationActions = map[string]string{
+	"iam:passrole":                  "passes a role to a service, which then acts with the role's permissions",
+	"iam:createaccesskey":           "creates credentials for an IAM user",
+	"iam:createloginprofile":        "sets a console password for an IAM user",
+	"iam:updateloginprofile":        "changes the console password of an IAM user",
+	"iam:addusertogroup":            "grants the permissions of a group to an IAM user",
+	"sts:assumerole":                "acts as another role",
+	"lambda:createfunction":         "runs code, with the permissions of a passed role",
+	"lambda:updatefunctioncode":     "replaces the code of a function, which runs with the function's role",
+	"secretsmanager:getsecretvalue": "reads secrets",
+	"kms:decrypt":                   "decrypts data protected by KMS keys",
+}
+
+// sensitive returns why an effective action is sensitive, or an empty string when it is not.
+func sensitive(action string) string {
+	if reason, ok := escalationActions[strings.ToLower(action)]; ok {
+		return reason
+	}
+	if strings.ContainsAny(action, "*?") {
+		return "wildcard over actions the catalog does not know"
+	}
+	if _, level, ok := catalog.Action(action); ok && level == "Permissions management" {
+		return "changes permissions (Permissions management access level)"
+	}
+	return ""
+}
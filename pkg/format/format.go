@@ -0,0 +1,145 @@
+package format
+
+/*
+This file provides the canonical formatting of IAM policy files, similar to what gofmt does for Go code.
+A canonical policy:
+ - lists keys in the order AWS documents them (the field order of the IAMPolicy model),
+ - has its actions deduplicated (case-insensitively) and sorted,
+ - writes one-element lists as plain strings, or every string as a list, depending on the ListStyle,
+ - is indented consistently and ends with a newline.
+
+Formatting only decodes the policy, it does not validate it, so it is safe to run on policies that still have findings.
+Only JSON policies are formatted: the canonical form is JSON, and writing it over a YAML file would lose its format.
+Condition values are always lists, as that is the only form the model can decode back.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+	"strings"
+)
+
+type ListStyle string
+
+const (
+	// Compact writes one-element lists as plain strings.
+	Compact ListStyle = "compact"
+	// Expanded writes every string value as a list.
+	Expanded ListStyle = "expanded"
+)
+
+type Options struct {
+	ListStyle ListStyle
+	Indent    string
+}
+
+var DefaultOptions = Options{ListStyle: Compact, Indent: "  "}
+
+// ErrYAML is returned when formatting a YAML policy.
+var ErrYAML = errors.New("only JSON policies are formatted, not YAML")
+
+// Format decodes a policy and returns it in canonical form.
+func Format(data []byte, options Options) ([]byte, error) {
+	if validator.DetectFormat(data) == validator.FormatYAML {
+		return nil, ErrYAML
+	}
+	policy, err := validator.DecodePolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	Normalize(&policy, options)
+	return Marshal(policy, options)
+}
+
+// IsFormatted reports whether data is already in canonical form.
+func IsFormatted(data []byte, options Options) (bool, error) {
+	formatted, err := Format(data, options)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(data, formatted), nil
+}
+
+// Normalize rewrites the policy in place into its canonical form. It never changes what the policy allows.
+func Normalize(policy *validator.IAMPolicy, options Options) {
+	for i := range policy.PolicyDocument.Statement {
+		statement := &policy.PolicyDocument.Statement[i]
+
+		statement.Action = applyListStyle(sortActions(statement.Action), options.ListStyle)
+		statement.NotAction = applyListStyle(sortActions(statement.NotAction), options.ListStyle)
+		statement.Resource = applyListStyle(statement.Resource, options.ListStyle)
+		statement.NotResource = applyListStyle(statement.NotResource, options.ListStyle)
+
+		for _, principal := range []*validator.PrincipalBlock{statement.Principal, statement.NotPrincipal} {
+			if principal == nil {
+				continue
+			}
+			principal.AWS = applyListStyle(principal.AWS, options.ListStyle)
+			principal.Federated = applyListStyle(principal.Federated, options.ListStyle)
+			principal.Service = applyListStyle(principal.Service, options.ListStyle)
+			principal.CanonicalUser = applyListStyle(principal.CanonicalUser, options.ListStyle)
+		}
+	}
+}
+
+// Marshal encodes the policy with the configured indentation, without escaping HTML characters in ARNs or conditions.
+func Marshal(policy validator.IAMPolicy, options Options) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", options.Indent)
+	if err := encoder.Encode(policy); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortActions deduplicates and sorts a list of actions. Values that are not a list of strings are left untouched.
+func sortActions(actions interface{}) interface{} {
+	list, ok := actions.([]interface{})
+	if !ok || len(validator.StringValues(list)) != len(list) {
+		return actions
+	}
+
+	seen := make(map[string]bool, len(list))
+	var sorted []string
+	for _, action := range validator.StringValues(list) {
+		key := strings.ToLower(action)
+		if !seen[key] {
+			seen[key] = true
+			sorted = append(sorted, action)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return strings.ToLower(sorted[i]) < strings.ToLower(sorted[j]) })
+
+	result := make([]interface{}, len(sorted))
+	for i, action := range sorted {
+		result[i] = action
+	}
+	return result
+}
+
+func applyListStyle(value interface{}, style ListStyle) interface{} {
+	switch v := value.(type) {
+	case string:
+		if style == Expanded {
+			return []interface{}{v}
+		}
+	case []interface{}:
+		if str, ok := singleString(v); ok && style == Compact {
+			return str
+		}
+	}
+	return value
+}
+
+func singleString(list []interface{}) (string, bool) {
+	if len(list) != 1 {
+		return "", false
+	}
+	str, ok := list[0].(string)
+	return str, ok
+}
@@ -0,0 +1,156 @@
+package gitdiff
+
+/*
+This file lists the files changed since a git ref, and their changed lines, by running the local git CLI.
+Changes are taken from the merge base of the ref and HEAD to the working tree, like a pull request is compared
+with its target branch, so the commits of the ref that are not in HEAD are ignored. Files that are not tracked yet,
+but not ignored either, are new files and count as changed. Deleted files are left out.
+
+Paths are relative to the working directory, and files outside of it are left out, as git diff --relative does.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of lines of the new version of a file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+type Changes struct {
+	// Base is the commit the working tree is compared with.
+	Base string
+	// Files lists the changed files in lexical order.
+	Files []string
+	// lines holds the changed lines of each file, in the order of the file.
+	lines map[string][]LineRange
+}
+
+// Since returns the changes from the merge base of ref and HEAD to the working tree.
+func Since(ref string) (*Changes, error) {
+	base, err := git("merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{Base: strings.TrimSpace(string(base)), lines: map[string][]LineRange{}}
+
+	names, err := git("diff", "--name-only", "-z", "--relative", "--no-renames", "--diff-filter=d", changes.Base)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git("ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	patch, err := git("diff", "--unified=0", "--relative", "--no-renames", "--diff-filter=d", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/", changes.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Files = splitNames(names)
+	for _, name := range splitNames(untracked) {
+		changes.Files = append(changes.Files, name)
+		changes.lines[name] = []LineRange{{Start: 1, End: int(^uint(0) >> 1)}}
+	}
+	sort.Strings(changes.Files)
+	if err := changes.parsePatch(patch); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Changed reports whether a line from start to end of a file was added or modified.
+func (c *Changes) Changed(path string, start, end int) bool {
+	for _, lines := range c.lines[path] {
+		if start <= lines.End && end >= lines.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePatch records the changed lines of each file from the hunk headers of a diff without context lines,
+// such as "@@ -12,2 +12,3 @@", which changes the 3 lines from line 12 of the new file.
+func (c *Changes) parsePatch(patch []byte) error {
+	file, header := "", false
+	scanner := bufio.NewScanner(bytes.NewReader(patch))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Added lines also start with "+", so the file name is only read in the header of each file.
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file, header = "", true
+		case header && strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if strings.HasPrefix(name, `"`) {
+				unquoted, err := strconv.Unquote(name)
+				if err != nil {
+					return fmt.Errorf("unexpected file name in git diff: %s", name)
+				}
+				name = unquoted
+			}
+			file = strings.TrimPrefix(name, "b/")
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			header = false
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return fmt.Errorf("unexpected hunk header in git diff: %s", line)
+			}
+			start, count, err := parseRange(strings.TrimPrefix(fields[2], "+"))
+			if err != nil {
+				return fmt.Errorf("unexpected hunk header in git diff: %s", line)
+			}
+			// A hunk only removing lines changes no line of the new file.
+			if count > 0 {
+				c.lines[file] = append(c.lines[file], LineRange{Start: start, End: start + count - 1})
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseRange parses the "start,count" of a hunk header, where the count is 1 when omitted.
+func parseRange(text string) (int, int, error) {
+	startText, countText, found := strings.Cut(text, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil || !found {
+		return start, 1, err
+	}
+	count, err := strconv.Atoi(countText)
+	return start, count, err
+}
+
+func splitNames(output []byte) []string {
+	var names []string
+	for _, name := range strings.Split(string(output), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// git runs a git command in the working directory and returns its output, or its error message.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	command := exec.Command("git", append([]string{"-c", "core.quotePath=false"}, args...)...)
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return output, nil
+}
@@ -0,0 +1,34 @@
+package gitdiff
+
+/*
+This file reads the index of the repository, for a pre-commit hook to check what is about to be committed rather
+than the working tree, which may hold changes that are not staged.
+*/
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Staged lists the files added or modified in the index, relative to the working directory, in lexical order.
+func Staged() ([]string, error) {
+	names, err := git("diff", "--cached", "--name-only", "-z", "--relative", "--no-renames", "--diff-filter=d")
+	if err != nil {
+		return nil, err
+	}
+	return splitNames(names), nil
+}
+
+// StagedContent returns the content of a file in the index, as git show :path does.
+func StagedContent(path string) ([]byte, error) {
+	return git("show", ":./"+filepath.ToSlash(path))
+}
+
+// HooksDir returns the directory holding the hooks of the repository, which core.hooksPath may set.
+func HooksDir() (string, error) {
+	dir, err := git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(dir)), nil
+}
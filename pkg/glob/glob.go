@@ -0,0 +1,58 @@
+package glob
+
+/*
+This file matches slash-separated relative paths against globs. Globs use the syntax of path.Match,
+with ** matching any number of directories. A glob without a slash matches the base name, e.g. *.tf.json
+or node_modules, wherever the file is.
+*/
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether a slash-separated relative path matches a glob.
+func Match(glob, name string) bool {
+	glob = strings.TrimPrefix(glob, "./")
+	name = strings.TrimPrefix(name, "./")
+	if !strings.Contains(glob, "/") {
+		matched, _ := path.Match(glob, path.Base(name))
+		return matched
+	}
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+// MatchAny reports whether a slash-separated relative path matches one of the globs.
+func MatchAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if Match(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether a glob is well-formed.
+func Valid(glob string) bool {
+	_, err := path.Match(glob, "")
+	return err == nil
+}
+
+func matchSegments(globs, names []string) bool {
+	if len(globs) == 0 {
+		return len(names) == 0
+	}
+	if globs[0] == "**" {
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(globs[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(names) == 0 {
+		return false
+	}
+	matched, _ := path.Match(globs[0], names[0])
+	return matched && matchSegments(globs[1:], names[1:])
+}
@@ -0,0 +1,345 @@
+package jsonast
+
+/*
+This file provides a JSON parser that keeps the byte offsets of every value and object key.
+encoding/json decodes into the IAMPolicy model but forgets where each element came from,
+which is needed to report findings at a line and column and to edit a file without reformatting it.
+
+Objects keep all of their members in source order, including duplicated keys.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type Kind int
+
+const (
+	Object Kind = iota
+	Array
+	String
+	Number
+	Bool
+	Null
+)
+
+type Node struct {
+	Kind Kind
+	// Start and End are the byte offsets of the value in the source, End being exclusive.
+	Start, End int
+	// Value is the decoded content of a string, or the literal text of a number, boolean or null.
+	Value    string
+	Members  []*Member
+	Elements []*Node
+}
+
+type Member struct {
+	Key string
+	// KeyStart and KeyEnd are the byte offsets of the key, quotes included.
+	KeyStart, KeyEnd int
+	Value            *Node
+}
+
+type SyntaxError struct {
+	Offset int
+	msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.msg
+}
+
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func Parse(data []byte) (*Node, error) {
+	p := &parser{data: data}
+	p.skipSpace()
+	node, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.data) {
+		return nil, p.errorf("unexpected %q after top-level value", p.data[p.pos])
+	}
+	return node, nil
+}
+
+// Member returns the member named name. Like encoding/json, an exact match is preferred over a case-insensitive one.
+func (n *Node) Member(name string) *Member {
+	if n == nil || n.Kind != Object {
+		return nil
+	}
+	for _, member := range n.Members {
+		if member.Key == name {
+			return member
+		}
+	}
+	for _, member := range n.Members {
+		if strings.EqualFold(member.Key, name) {
+			return member
+		}
+	}
+	return nil
+}
+
+// Lookup resolves a path such as PolicyDocument.Statement[1].Action[0] from this node.
+// A path ending in an index of an element that is a single string, e.g. Action[0] for "Action": "s3:*", resolves to that string.
+func (n *Node) Lookup(path string) *Node {
+	node := n
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			continue
+		}
+		name, indexes := segment, []int(nil)
+		if i := strings.Index(segment, "["); i != -1 {
+			name = segment[:i]
+			for _, part := range strings.Split(strings.TrimSuffix(segment[i+1:], "]"), "][") {
+				index, err := strconv.Atoi(part)
+				if err != nil {
+					return nil
+				}
+				indexes = append(indexes, index)
+			}
+		}
+
+		if name != "" {
+			member := node.Member(name)
+			if member == nil {
+				return nil
+			}
+			node = member.Value
+		}
+		for _, index := range indexes {
+			switch {
+			case node.Kind == Array && index < len(node.Elements):
+				node = node.Elements[index]
+			case node.Kind != Array && index == 0:
+			default:
+				return nil
+			}
+		}
+	}
+	return node
+}
+
+// NodeAt returns the innermost node whose value spans the offset, or nil when the offset is outside this node.
+func (n *Node) NodeAt(offset int) *Node {
+	if n == nil || offset < n.Start || offset >= n.End {
+		return nil
+	}
+	for _, member := range n.Members {
+		if node := member.Value.NodeAt(offset); node != nil {
+			return node
+		}
+	}
+	for _, element := range n.Elements {
+		if node := element.NodeAt(offset); node != nil {
+			return node
+		}
+	}
+	return n
+}
+
+// Position converts a byte offset into a 1-based line and column. Columns count characters (Unicode code points),
+// as the YAML parser does, not bytes.
+func Position(data []byte, offset int) (line, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line, lineStart := 1, 0
+	for i := 0; i < offset; i++ {
+		if data[i] == '\n' {
+			line++
+			lineStart = i + 1
+		}
+	}
+	return line, utf8.RuneCount(data[lineStart:offset]) + 1
+}
+
+func (p *parser) parseValue() (*Node, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of JSON input")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.parseObject()
+	case c == '[':
+		return p.parseArray()
+	case c == '"':
+		start := p.pos
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: String, Start: start, End: p.pos, Value: value}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case c == 't':
+		return p.parseLiteral("true", Bool)
+	case c == 'f':
+		return p.parseLiteral("false", Bool)
+	case c == 'n':
+		return p.parseLiteral("null", Null)
+	default:
+		return nil, p.errorf("invalid character %q looking for beginning of value", c)
+	}
+}
+
+func (p *parser) parseObject() (*Node, error) {
+	node := &Node{Kind: Object, Start: p.pos}
+	p.pos++
+	p.skipSpace()
+
+	if p.peek() == '}' {
+		p.pos++
+		node.End = p.pos
+		return node, nil
+	}
+
+	for {
+		p.skipSpace()
+		if p.peek() != '"' {
+			return nil, p.errorf("invalid character %q looking for beginning of object key string", p.peek())
+		}
+		keyStart := p.pos
+		key, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		member := &Member{Key: key, KeyStart: keyStart, KeyEnd: p.pos}
+
+		p.skipSpace()
+		if p.peek() != ':' {
+			return nil, p.errorf("invalid character %q after object key", p.peek())
+		}
+		p.pos++
+		p.skipSpace()
+
+		if member.Value, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+		node.Members = append(node.Members, member)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			node.End = p.pos
+			return node, nil
+		default:
+			return nil, p.errorf("invalid character %q after object key:value pair", p.peek())
+		}
+	}
+}
+
+func (p *parser) parseArray() (*Node, error) {
+	node := &Node{Kind: Array, Start: p.pos}
+	p.pos++
+	p.skipSpace()
+
+	if p.peek() == ']' {
+		p.pos++
+		node.End = p.pos
+		return node, nil
+	}
+
+	for {
+		p.skipSpace()
+		element, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.Elements = append(node.Elements, element)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			node.End = p.pos
+			return node, nil
+		default:
+			return nil, p.errorf("invalid character %q after array element", p.peek())
+		}
+	}
+}
+
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			var value string
+			if err := json.Unmarshal(p.data[start:p.pos], &value); err != nil {
+				return "", &SyntaxError{Offset: start, msg: fmt.Sprintf("invalid string literal: %v", err)}
+			}
+			return value, nil
+		default:
+			p.pos++
+		}
+	}
+	return "", p.errorf("unexpected end of JSON input")
+}
+
+func (p *parser) parseNumber() (*Node, error) {
+	start := p.pos
+	for p.pos < len(p.data) && strings.IndexByte("+-0123456789.eE", p.data[p.pos]) != -1 {
+		p.pos++
+	}
+	literal := string(p.data[start:p.pos])
+	if !json.Valid([]byte(literal)) {
+		return nil, &SyntaxError{Offset: start, msg: fmt.Sprintf("invalid number literal %q", literal)}
+	}
+	return &Node{Kind: Number, Start: start, End: p.pos, Value: literal}, nil
+}
+
+func (p *parser) parseLiteral(literal string, kind Kind) (*Node, error) {
+	start := p.pos
+	if !bytes.HasPrefix(p.data[p.pos:], []byte(literal)) {
+		return nil, p.errorf("invalid literal, expected %q", literal)
+	}
+	p.pos += len(literal)
+	return &Node{Kind: kind, Start: start, End: p.pos, Value: literal}, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.pos >= len(p.data) {
+		return &SyntaxError{Offset: len(p.data), msg: "unexpected end of JSON input"}
+	}
+	return &SyntaxError{Offset: p.pos, msg: fmt.Sprintf(format, args...)}
+}
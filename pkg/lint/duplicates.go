@@ -0,0 +1,84 @@
+package lint
+
+/*
+This file provides the duplicateEntry rule, reporting values repeated inside the Action, NotAction,
+Resource or NotResource list of a single statement. Action names are compared case-insensitively.
+The fix removes the repeated entry from the list.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"strings"
+)
+
+func init() {
+	Register(Rule{
+		ID:       "duplicateEntry",
+		Severity: SeverityInfo,
+		Check:    policyCheck(checkDuplicateEntries),
+		Fix:      removeArrayElement,
+	})
+}
+
+func checkDuplicateEntries(policy validator.IAMPolicy) []Finding {
+	var findings []Finding
+	for i, statement := range policy.PolicyDocument.Statement {
+		elements := []struct {
+			name       string
+			value      interface{}
+			ignoreCase bool
+		}{
+			{"Action", statement.Action, true},
+			{"NotAction", statement.NotAction, true},
+			{"Resource", statement.Resource, false},
+			{"NotResource", statement.NotResource, false},
+		}
+
+		for _, element := range elements {
+			seen := map[string]int{}
+			// Indexes count every item of the list, so that the path points at the item even next to
+			// items that are not strings, which are not compared.
+			for k, item := range listItems(element.value) {
+				value, ok := item.(string)
+				if !ok {
+					continue
+				}
+				key := value
+				if element.ignoreCase {
+					key = strings.ToLower(value)
+				}
+				first, duplicated := seen[key]
+				if !duplicated {
+					seen[key] = k
+					continue
+				}
+				path := fmt.Sprintf("%s.%s", statementPath(i), element.name)
+				findings = append(findings, Finding{
+					Message: fmt.Sprintf("%s %q is listed more than once in statement %s",
+						element.name, value, statementLabel(i, statement)),
+					Path:    fmt.Sprintf("%s[%d]", path, k),
+					Related: fmt.Sprintf("%s[%d]", path, first),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// listItems returns the items of a list element, a single string being a list of one item.
+func listItems(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case string:
+		return []interface{}{v}
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	case []interface{}:
+		return v
+	}
+	return nil
+}
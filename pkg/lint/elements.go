@@ -0,0 +1,190 @@
+package lint
+
+/*
+This file provides the rules about how policy elements are written. They work on the syntax tree,
+so they also report policies that encoding/json cannot decode into the IAMPolicy model, and every one of them can be fixed:
+ - conditionsKey: the condition block is named Conditions instead of Condition,
+ - elementNameCase: an element name differs from the documented one only by case, e.g. "effect",
+ - effectCase: the Effect value differs from Allow or Deny only by case,
+ - legacyVersion: the policy uses Version 2008-10-17, which does not support policy variables.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/jsonast"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"strings"
+)
+
+const currentVersion = "2012-10-17"
+
+var (
+	policyElements    = []string{"Id", "PolicyName", "PolicyDocument"}
+	documentElements  = []string{"Version", "Statement"}
+	statementElements = []string{"Sid", "Effect", "Principal", "NotPrincipal", "Action", "NotAction",
+		"Resource", "NotResource", "Condition"}
+	principalElements = []string{"AWS", "Federated", "Service", "CanonicalUser"}
+)
+
+func init() {
+	Register(Rule{
+		ID:       "conditionsKey",
+		Severity: SeverityError,
+		Check:    checkConditionsKey,
+		Fix: func(doc *Document, finding Finding) []Edit {
+			return renameKey(lookupMember(doc.Root, finding.Path), "Condition")
+		},
+	})
+	Register(Rule{
+		ID:       "elementNameCase",
+		Severity: SeverityError,
+		Check:    checkElementNameCase,
+		Fix: func(doc *Document, finding Finding) []Edit {
+			member := lookupMember(doc.Root, finding.Path)
+			if member == nil {
+				return nil
+			}
+			return renameKey(member, documentedName(member.Key))
+		},
+	})
+	Register(Rule{
+		ID:       "effectCase",
+		Severity: SeverityError,
+		Check:    checkEffectCase,
+		Fix: func(doc *Document, finding Finding) []Edit {
+			node := doc.Root.Lookup(finding.Path)
+			if node == nil {
+				return nil
+			}
+			return replaceString(node, documentedEffect(node.Value))
+		},
+	})
+	Register(Rule{
+		ID:       "legacyVersion",
+		Severity: SeverityWarning,
+		Check:    policyCheck(checkLegacyVersion),
+		Fix: func(doc *Document, finding Finding) []Edit {
+			if doc.Root == nil {
+				return nil
+			}
+			return replaceString(doc.Root.Lookup(finding.Path), currentVersion)
+		},
+	})
+}
+
+func checkConditionsKey(doc *Document) []Finding {
+	var findings []Finding
+	for i, statement := range statementNodes(doc) {
+		for _, member := range statement.Members {
+			if strings.EqualFold(member.Key, "Conditions") {
+				findings = append(findings, Finding{
+					Message: fmt.Sprintf("Statement %d uses %q instead of \"Condition\"", i+1, member.Key),
+					Path:    fmt.Sprintf("%s.%s", statementPath(i), member.Key),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkElementNameCase(doc *Document) []Finding {
+	if doc.Root == nil {
+		return nil
+	}
+
+	var findings []Finding
+	check := func(node *jsonast.Node, path string, names []string) {
+		if node == nil {
+			return
+		}
+		for _, member := range node.Members {
+			for _, name := range names {
+				if member.Key != name && strings.EqualFold(member.Key, name) {
+					findings = append(findings, Finding{
+						Message: fmt.Sprintf("Element %q should be written %q", member.Key, name),
+						Path:    strings.TrimPrefix(path+"."+member.Key, "."),
+					})
+				}
+			}
+		}
+	}
+
+	check(doc.Root, "", policyElements)
+	check(doc.Root.Lookup("PolicyDocument"), "PolicyDocument", documentElements)
+	for i, statement := range statementNodes(doc) {
+		check(statement, statementPath(i), statementElements)
+		for _, name := range []string{"Principal", "NotPrincipal"} {
+			if member := statement.Member(name); member != nil {
+				check(member.Value, fmt.Sprintf("%s.%s", statementPath(i), member.Key), principalElements)
+			}
+		}
+	}
+	return findings
+}
+
+func checkEffectCase(doc *Document) []Finding {
+	var findings []Finding
+	for i, statement := range statementNodes(doc) {
+		member := statement.Member("Effect")
+		if member == nil || member.Value.Kind != jsonast.String {
+			continue
+		}
+		effect := member.Value.Value
+		if expected := documentedEffect(effect); expected != "" && expected != effect {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("Effect %q of statement %d should be written %q", effect, i+1, expected),
+				Path:    fmt.Sprintf("%s.%s", statementPath(i), member.Key),
+			})
+		}
+	}
+	return findings
+}
+
+func checkLegacyVersion(policy validator.IAMPolicy) []Finding {
+	if policy.PolicyDocument.Version != "2008-10-17" {
+		return nil
+	}
+	return []Finding{{
+		Message: fmt.Sprintf("Version %q should be upgraded to %q", policy.PolicyDocument.Version, currentVersion),
+		Path:    "PolicyDocument.Version",
+	}}
+}
+
+// statementNodes returns the statement objects of a document parsed from source.
+func statementNodes(doc *Document) []*jsonast.Node {
+	if doc.Root == nil {
+		return nil
+	}
+	statements := doc.Root.Lookup("PolicyDocument.Statement")
+	if statements == nil || statements.Kind != jsonast.Array {
+		return nil
+	}
+
+	var nodes []*jsonast.Node
+	for _, statement := range statements.Elements {
+		if statement.Kind == jsonast.Object {
+			nodes = append(nodes, statement)
+		}
+	}
+	return nodes
+}
+
+func documentedName(key string) string {
+	for _, names := range [][]string{policyElements, documentElements, statementElements, principalElements} {
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				return name
+			}
+		}
+	}
+	return key
+}
+
+func documentedEffect(effect string) string {
+	for _, name := range []string{"Allow", "Deny"} {
+		if strings.EqualFold(effect, name) {
+			return name
+		}
+	}
+	return ""
+}
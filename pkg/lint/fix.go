@@ -0,0 +1,158 @@
+package lint
+
+/*
+This file provides the fix engine. A rule offering a Fix returns the edits correcting one of its findings,
+as byte ranges of the original source to replace, so that everything around them keeps its original formatting.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/jsonast"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrYAML is returned when fixing a YAML policy: fixes edit the JSON source, so that everything around them keeps its
+// formatting, and are only applied to JSON policies.
+var ErrYAML = errors.New("fixes only apply to JSON policies, not YAML")
+
+// maxFixRounds bounds how many times the source is re-analyzed after applying fixes.
+const maxFixRounds = 10
+
+// Edit replaces the bytes between Start and End (exclusive) with Text.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// FixSource applies the available fixes of the rules for which enabled returns true, of every rule when it is nil,
+// to a policy source and returns the fixed source with the findings it fixed.
+// Fixes are applied in rounds, re-analyzing the result after each one, as a fix may uncover further findings,
+// e.g. the checks of the decoded policy only run once the policy decodes.
+func FixSource(source []byte, enabled func(rule string) bool) ([]byte, []Finding, error) {
+	if validator.DetectFormat(source) == validator.FormatYAML {
+		return nil, nil, ErrYAML
+	}
+	var fixed []Finding
+	for round := 0; round < maxFixRounds; round++ {
+		doc, err := NewDocument(source)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var edits []Edit
+		for _, finding := range LintDocument(doc) {
+			rule := registry[finding.Rule]
+			if rule.Fix == nil || (enabled != nil && !enabled(finding.Rule)) {
+				continue
+			}
+			findingEdits := rule.Fix(doc, finding)
+			if len(findingEdits) == 0 || overlaps(edits, findingEdits) {
+				continue
+			}
+			edits = append(edits, findingEdits...)
+			fixed = append(fixed, finding)
+		}
+
+		if len(edits) == 0 {
+			break
+		}
+		source = ApplyEdits(source, edits)
+	}
+	return source, fixed, nil
+}
+
+// Fixable reports whether the rule that reported the finding offers a fix for it.
+func Fixable(doc *Document, finding Finding) bool {
+	return len(Fixes(doc, finding)) > 0
+}
+
+// Fixes returns the edits correcting a finding of the document, or nil when its rule offers no fix for it.
+func Fixes(doc *Document, finding Finding) []Edit {
+	rule, ok := registry[finding.Rule]
+	if !ok || rule.Fix == nil || doc.Root == nil || doc.YAML {
+		return nil
+	}
+	return rule.Fix(doc, finding)
+}
+
+// ApplyEdits applies non-overlapping edits to source.
+func ApplyEdits(source []byte, edits []Edit) []byte {
+	sorted := append([]Edit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start > sorted[j].Start })
+
+	result := append([]byte(nil), source...)
+	for _, edit := range sorted {
+		result = append(result[:edit.Start], append([]byte(edit.Text), result[edit.End:]...)...)
+	}
+	return result
+}
+
+func overlaps(edits, candidates []Edit) bool {
+	for _, a := range edits {
+		for _, b := range candidates {
+			if a.Start < b.End && b.Start < a.End {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupMember returns the object member a path such as PolicyDocument.Statement[0].Effect points to.
+func lookupMember(root *jsonast.Node, path string) *jsonast.Member {
+	i := strings.LastIndex(path, ".")
+	if root == nil || i == -1 {
+		return nil
+	}
+	return root.Lookup(path[:i]).Member(path[i+1:])
+}
+
+func renameKey(member *jsonast.Member, name string) []Edit {
+	if member == nil {
+		return nil
+	}
+	return []Edit{{Start: member.KeyStart, End: member.KeyEnd, Text: quote(name)}}
+}
+
+func replaceString(node *jsonast.Node, value string) []Edit {
+	if node == nil || node.Kind != jsonast.String {
+		return nil
+	}
+	return []Edit{{Start: node.Start, End: node.End, Text: quote(value)}}
+}
+
+// removeArrayElement removes the element a finding points to, together with the separator before it. The element
+// must be a string equal to the one the finding relates to, case-insensitively in action lists, so that a stale or
+// wrong path never removes another element.
+func removeArrayElement(doc *Document, finding Finding) []Edit {
+	open := strings.LastIndex(finding.Path, "[")
+	if doc.Root == nil || open == -1 {
+		return nil
+	}
+	index, err := strconv.Atoi(strings.TrimSuffix(finding.Path[open+1:], "]"))
+	if err != nil || index == 0 {
+		return nil
+	}
+
+	array := doc.Root.Lookup(finding.Path[:open])
+	if array == nil || array.Kind != jsonast.Array || index >= len(array.Elements) {
+		return nil
+	}
+	element, related := array.Elements[index], doc.Root.Lookup(finding.Related)
+	if element.Kind != jsonast.String || related == nil || related == element || related.Kind != jsonast.String {
+		return nil
+	}
+	if element.Value != related.Value && !(strings.HasSuffix(finding.Path[:open], "Action") && strings.EqualFold(element.Value, related.Value)) {
+		return nil
+	}
+	return []Edit{{Start: array.Elements[index-1].End, End: array.Elements[index].End}}
+}
+
+func quote(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
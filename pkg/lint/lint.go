@@ -0,0 +1,144 @@
+package lint
+
+/*
+This file provides the rule registry used to analyze IAM policies beyond the format validation done by ValidateIAMPolicy.
+Each rule inspects a Document and reports findings, and may offer a Fix for them (see fix.go).
+Rules register themselves in an init function, and their ID is the key of their description in the validator error catalog.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/jsonast"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	// Path locates the offending element in the policy, e.g. PolicyDocument.Statement[1].Action[0].
+	Path string
+	// Related locates the element that causes the finding, such as the statement covering another one.
+	Related string
+}
+
+type Rule struct {
+	ID       string
+	Severity Severity
+	Check    func(doc *Document) []Finding
+	// Fix returns the edits correcting a finding reported by Check. Rules without a mechanical fix leave it nil.
+	Fix func(doc *Document, finding Finding) []Edit
+}
+
+// Document is what rules inspect: the policy source and its syntax tree, when linting a file,
+// and the decoded policy, when it could be decoded into the IAMPolicy model.
+// The source of a YAML policy is its JSON form, which fixes cannot be applied to, so they are only offered for JSON.
+type Document struct {
+	Source  []byte
+	Root    *jsonast.Node
+	Policy  validator.IAMPolicy
+	Decoded bool
+	YAML    bool
+}
+
+// NewDocument parses a JSON or YAML policy source. It fails only on syntax errors, a policy that does not
+// match the IAMPolicy model is still returned so that rules working on the syntax tree can report it.
+func NewDocument(source []byte) (*Document, error) {
+	doc := &Document{Source: source}
+	if validator.DetectFormat(source) == validator.FormatYAML {
+		// Duplicate keys are rejected by the loader, as validate does, rather than reported by their rule.
+		yamlSource, err := validator.NewSource(source)
+		if err != nil {
+			return nil, err
+		}
+		doc.Source, doc.Root, doc.YAML = yamlSource.JSON, yamlSource.Root, true
+	} else {
+		root, err := jsonast.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		doc.Root = root
+	}
+	if policy, err := validator.DecodePolicy(doc.Source); err == nil {
+		doc.Policy, doc.Decoded = policy, true
+	}
+	return doc, nil
+}
+
+var registry = map[string]Rule{}
+
+func Register(rule Rule) {
+	if _, exists := registry[rule.ID]; exists {
+		panic(fmt.Sprintf("lint: rule %q registered twice", rule.ID))
+	}
+	registry[rule.ID] = rule
+}
+
+// Rules returns all registered rules ordered by ID.
+func Rules() []Rule {
+	rules := make([]Rule, 0, len(registry))
+	for _, rule := range registry {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+func (r Rule) Description() string {
+	return validator.GetErrorMessage(r.ID)
+}
+
+// Lint runs every registered rule against the policy and returns the findings ordered by location.
+func Lint(policy validator.IAMPolicy) []Finding {
+	return LintDocument(&Document{Policy: policy, Decoded: true})
+}
+
+// LintDocument runs every registered rule against the document and returns the findings ordered by location.
+func LintDocument(doc *Document) []Finding {
+	var findings []Finding
+	for _, rule := range Rules() {
+		for _, finding := range rule.Check(doc) {
+			finding.Rule = rule.ID
+			if finding.Severity == "" {
+				finding.Severity = rule.Severity
+			}
+			findings = append(findings, finding)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Path < findings[j].Path })
+	return findings
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s]", f.Path, f.Message, f.Rule)
+}
+
+// policyCheck adapts a check of the decoded policy, so that it is skipped for documents that could not be decoded.
+func policyCheck(check func(policy validator.IAMPolicy) []Finding) func(doc *Document) []Finding {
+	return func(doc *Document) []Finding {
+		if !doc.Decoded {
+			return nil
+		}
+		return check(doc.Policy)
+	}
+}
+
+func statementPath(index int) string {
+	return fmt.Sprintf("PolicyDocument.Statement[%d]", index)
+}
+
+func statementLabel(index int, statement validator.Statement) string {
+	if statement.Sid != "" {
+		return fmt.Sprintf("%d (%s)", index+1, statement.Sid)
+	}
+	return fmt.Sprintf("%d", index+1)
+}
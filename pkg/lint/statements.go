@@ -0,0 +1,145 @@
+package lint
+
+/*
+This file provides the rules comparing statements of the same policy:
+ - redundantStatement: a statement whose every request is already matched by another statement with the same Effect,
+ - shadowedStatement: an Allow statement whose every request is explicitly denied by a Deny statement.
+
+A statement covers another one when its Action, Resource and Principal elements match everything the other one matches,
+and it is either unconditional or carries exactly the same Condition block. The comparison is conservative:
+a finding is reported only when the coverage can be proven from the wildcard patterns alone.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"reflect"
+	"strings"
+)
+
+func init() {
+	Register(Rule{ID: "redundantStatement", Severity: SeverityWarning, Check: policyCheck(checkRedundantStatements)})
+	Register(Rule{ID: "shadowedStatement", Severity: SeverityWarning, Check: policyCheck(checkShadowedStatements)})
+}
+
+func checkRedundantStatements(policy validator.IAMPolicy) []Finding {
+	var findings []Finding
+	statements := policy.PolicyDocument.Statement
+
+	for i, inner := range statements {
+		for j, outer := range statements {
+			if i == j || inner.Effect != outer.Effect || !StatementCovers(outer, inner) {
+				continue
+			}
+			// Identical statements cover each other, only the later one is reported.
+			if j > i && StatementCovers(inner, outer) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("Statement %s is fully covered by statement %s",
+					statementLabel(i, inner), statementLabel(j, outer)),
+				Path:    statementPath(i),
+				Related: statementPath(j),
+			})
+			break
+		}
+	}
+	return findings
+}
+
+func checkShadowedStatements(policy validator.IAMPolicy) []Finding {
+	var findings []Finding
+	statements := policy.PolicyDocument.Statement
+
+	for i, allow := range statements {
+		if allow.Effect != "Allow" {
+			continue
+		}
+		for j, deny := range statements {
+			if deny.Effect != "Deny" || !StatementCovers(deny, allow) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("Allow statement %s never takes effect because statement %s denies it",
+					statementLabel(i, allow), statementLabel(j, deny)),
+				Path:    statementPath(i),
+				Related: statementPath(j),
+			})
+			break
+		}
+	}
+	return findings
+}
+
+// StatementCovers reports whether outer provably applies to every request inner applies to.
+func StatementCovers(outer, inner validator.Statement) bool {
+	if !reflect.DeepEqual(outer.Principal, inner.Principal) || !reflect.DeepEqual(outer.NotPrincipal, inner.NotPrincipal) {
+		return false
+	}
+	if len(outer.Conditions) != 0 && !reflect.DeepEqual(outer.Conditions, inner.Conditions) {
+		return false
+	}
+	return elementCovers(outer.Action, outer.NotAction, inner.Action, inner.NotAction, true) &&
+		elementCovers(outer.Resource, outer.NotResource, inner.Resource, inner.NotResource, false)
+}
+
+// elementCovers compares an element and its Not-counterpart (e.g. Action and NotAction) of two statements.
+func elementCovers(outer, notOuter, inner, notInner interface{}, ignoreCase bool) bool {
+	outerPatterns, outerNegated := elementPatterns(outer, notOuter, ignoreCase)
+	innerPatterns, innerNegated := elementPatterns(inner, notInner, ignoreCase)
+
+	switch {
+	case !outerNegated && !innerNegated:
+		return allCovered(innerPatterns, outerPatterns)
+	case !outerNegated && innerNegated:
+		return allCovered([]string{"*"}, outerPatterns)
+	case outerNegated && !innerNegated:
+		for _, in := range innerPatterns {
+			for _, excluded := range outerPatterns {
+				if simulator.PatternsOverlap(in, excluded) {
+					return false
+				}
+			}
+		}
+		return true
+	default:
+		// outer excludes less than inner does
+		return allCovered(outerPatterns, innerPatterns)
+	}
+}
+
+func elementPatterns(element, notElement interface{}, ignoreCase bool) ([]string, bool) {
+	patterns, negated := []string{"*"}, false
+	switch {
+	case element != nil:
+		patterns = validator.StringValues(element)
+	case notElement != nil:
+		patterns, negated = validator.StringValues(notElement), true
+	}
+
+	if ignoreCase {
+		lowered := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			lowered[i] = strings.ToLower(pattern)
+		}
+		patterns = lowered
+	}
+	return patterns, negated
+}
+
+func allCovered(patterns, by []string) bool {
+	for _, pattern := range patterns {
+		covered := false
+		for _, outer := range by {
+			if simulator.PatternCovers(outer, pattern) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package lsp
+
+/*
+This file offers the fixes of the lint rules as code actions: a quick fix for each fixable finding on the lines
+the editor asks about, and a source action applying every fix, as the fix command does. Fixes edit the JSON
+source, so they are only offered for JSON documents, and for the rules the configuration enables.
+*/
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/jsonast"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"strings"
+)
+
+func codeActions(doc *document, params CodeActionParams, cfg *config.Config) []CodeAction {
+	actions := []CodeAction{}
+	source := []byte(doc.text)
+	lintDoc, err := lint.NewDocument(source)
+	if err != nil {
+		return actions
+	}
+
+	if wantsKind(params.Context.Only, kindQuickFix) {
+		for _, finding := range lint.LintDocument(lintDoc) {
+			edits := lint.Fixes(lintDoc, finding)
+			if len(edits) == 0 || !cfg.RuleEnabled(finding.Rule) {
+				continue
+			}
+			line := position(doc.text, findingOffset(lintDoc.Root, finding.Path)).Line
+			if line < params.Range.Start.Line || line > params.Range.End.Line {
+				continue
+			}
+			action := CodeAction{
+				Title:       "Fix: " + finding.Message,
+				Kind:        kindQuickFix,
+				IsPreferred: true,
+				Edit:        WorkspaceEdit{Changes: map[string][]TextEdit{doc.uri: textEdits(doc.text, edits)}},
+			}
+			for _, diagnostic := range params.Context.Diagnostics {
+				if diagnostic.Code == finding.Rule && diagnostic.Range.Start.Line == line {
+					action.Diagnostics = append(action.Diagnostics, diagnostic)
+				}
+			}
+			actions = append(actions, action)
+		}
+	}
+
+	if wantsKind(params.Context.Only, kindFixAll) {
+		fixed, findings, err := lint.FixSource(source, cfg.RuleEnabled)
+		if err == nil && len(findings) > 0 {
+			whole := Range{End: position(doc.text, len(doc.text))}
+			actions = append(actions, CodeAction{
+				Title: "Fix all autofixable findings",
+				Kind:  kindFixAll,
+				Edit:  WorkspaceEdit{Changes: map[string][]TextEdit{doc.uri: {{Range: whole, NewText: string(fixed)}}}},
+			})
+		}
+	}
+	return actions
+}
+
+// wantsKind reports whether the client asks for code actions of a kind, only being empty when it asks for all.
+func wantsKind(only []string, kind string) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, wanted := range only {
+		if kind == wanted || strings.HasPrefix(kind, wanted+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// findingOffset returns the offset of the element at path, or of its closest parent that exists, as findings are
+// located by validate.
+func findingOffset(root *jsonast.Node, path string) int {
+	node := root.Lookup(path)
+	for node == nil && path != "" {
+		i := strings.LastIndexAny(path, ".[")
+		if i == -1 {
+			i = 0
+		}
+		path = path[:i]
+		node = root.Lookup(path)
+	}
+	if node == nil {
+		return 0
+	}
+	return node.Start
+}
+
+func textEdits(text string, edits []lint.Edit) []TextEdit {
+	var result []TextEdit
+	for _, edit := range edits {
+		result = append(result, TextEdit{
+			Range:   Range{Start: position(text, edit.Start), End: position(text, edit.End)},
+			NewText: edit.Text,
+		})
+	}
+	return result
+}
@@ -0,0 +1,303 @@
+package lsp
+
+/*
+This file completes action names, condition operators and condition keys, and shows the documentation of actions
+on hover, from the catalog.
+
+Completion runs while the document is being typed, when it is usually not valid JSON or YAML, so the element the
+cursor is in is found by a tolerant scan of the text before it rather than by a parser: the JSON scan follows the
+brackets and the keys of the objects they open, the YAML scan follows the indentation of the lines above.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"regexp"
+	"strings"
+)
+
+// completionValue is the kind of completion items for the values of an element, such as the effects.
+const completionValue = 12
+
+// maxHoverActions bounds the actions listed on hover of a wildcard.
+const maxHoverActions = 20
+
+// completionContext describes where the cursor is.
+type completionContext struct {
+	// keys are the keys of the objects enclosing the cursor, outermost first, e.g.
+	// [PolicyDocument Statement Condition] in the key of a condition operator.
+	keys []string
+	// key is true when the cursor is in the key of an object member, false in a value.
+	key bool
+	// prefix is the text typed before the cursor, which starts at the byte offset start.
+	prefix string
+	start  int
+}
+
+func (c completionContext) last(n int) string {
+	if len(c.keys) < n {
+		return ""
+	}
+	return c.keys[len(c.keys)-n]
+}
+
+func complete(text string, p Position) CompletionList {
+	cursor := offset(text, p)
+	var context *completionContext
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		context = jsonContext(text, cursor)
+	} else {
+		context = yamlContext(text, cursor)
+	}
+
+	list := CompletionList{Items: []CompletionItem{}}
+	if context == nil {
+		return list
+	}
+	replace := Range{Start: position(text, context.start), End: p}
+	add := func(label string, kind int, detail string) {
+		if strings.HasPrefix(strings.ToLower(label), strings.ToLower(context.prefix)) {
+			list.Items = append(list.Items, CompletionItem{Label: label, Kind: kind, Detail: detail,
+				TextEdit: &TextEdit{Range: replace, NewText: label}})
+		}
+	}
+
+	switch {
+	case !context.key && (context.last(1) == "Action" || context.last(1) == "NotAction"):
+		for _, service := range catalog.Services() {
+			add(service.Prefix+":*", completionFunction, "All "+service.Name+" actions")
+			for _, action := range service.ActionNames() {
+				add(action, completionFunction, fmt.Sprintf("%s, %s", service.Name, service.Actions[strings.TrimPrefix(action, service.Prefix+":")]))
+			}
+		}
+	case !context.key && context.last(1) == "Effect":
+		add("Allow", completionValue, "")
+		add("Deny", completionValue, "")
+	case context.key && context.last(1) == "Condition":
+		for _, operator := range simulator.Operators() {
+			add(operator, completionOperator, "Condition operator")
+			if operator == "Null" {
+				continue
+			}
+			add(operator+"IfExists", completionOperator, "Condition operator, true when the key is missing")
+			add("ForAllValues:"+operator, completionOperator, "Condition operator, for every value of the key")
+			add("ForAnyValue:"+operator, completionOperator, "Condition operator, for at least one value of the key")
+		}
+	case context.key && context.last(2) == "Condition":
+		for _, key := range catalog.ConditionKeys() {
+			prefix, _, _ := strings.Cut(key, ":")
+			detail := "Global condition key"
+			if service, ok := catalog.Lookup(prefix); ok && prefix != "aws" {
+				detail = service.Name + " condition key"
+			}
+			add(key, completionField, detail)
+		}
+	}
+	return list
+}
+
+type jsonFrame struct {
+	object    bool
+	key       string
+	expectKey bool
+}
+
+// jsonContext scans the JSON text before the cursor. There is only something to complete in a string.
+func jsonContext(text string, cursor int) *completionContext {
+	var stack []jsonFrame
+	inString, stringStart, lastString := false, 0, ""
+	for i := 0; i < cursor && i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString, lastString = false, text[stringStart+1:i]
+			}
+			continue
+		}
+		top := len(stack) - 1
+		switch c {
+		case '"':
+			inString, stringStart = true, i
+		case '{':
+			stack = append(stack, jsonFrame{object: true, expectKey: true})
+		case '[':
+			stack = append(stack, jsonFrame{})
+		case '}', ']':
+			if top >= 0 {
+				stack = stack[:top]
+			}
+		case ':':
+			if top >= 0 && stack[top].object {
+				stack[top].key, stack[top].expectKey = lastString, false
+			}
+		case ',':
+			if top >= 0 && stack[top].object {
+				stack[top].expectKey = true
+			}
+		}
+	}
+	if !inString || len(stack) == 0 {
+		return nil
+	}
+
+	context := &completionContext{prefix: text[stringStart+1 : cursor], start: stringStart + 1}
+	innermost := stack[len(stack)-1]
+	context.key = innermost.object && innermost.expectKey
+	for i, frame := range stack {
+		if frame.object && !(context.key && i == len(stack)-1) {
+			context.keys = append(context.keys, frame.key)
+		}
+	}
+	return context
+}
+
+// yamlEntryPattern matches the key of a mapping entry, followed by a space or the end of the line.
+// On the line being typed, a colon at the end may be part of a value, e.g. s3: in s3:GetObject, and a space must follow.
+var (
+	yamlEntryPattern      = regexp.MustCompile(`^["']?([^"':#]+)["']?:(\s|$)`)
+	yamlTypedEntryPattern = regexp.MustCompile(`^["']?([^"':#]+)["']?:\s`)
+)
+
+// yamlEntry is a line of a YAML document: the indentation of its content, the key of its mapping entry if it has one,
+// and the indentation of its dash if it is a list item.
+type yamlEntry struct {
+	indent     int
+	key        string
+	value      string
+	valueStart int
+	item       bool
+	dashIndent int
+	blank      bool
+}
+
+func parseYAMLEntry(line string, pattern *regexp.Regexp) yamlEntry {
+	content := strings.TrimLeft(line, " ")
+	entry := yamlEntry{indent: len(line) - len(content)}
+	if content == "" || strings.HasPrefix(content, "#") {
+		entry.blank = true
+		return entry
+	}
+	if content == "-" || strings.HasPrefix(content, "- ") {
+		entry.item, entry.dashIndent = true, entry.indent
+		rest := strings.TrimLeft(content[1:], " ")
+		entry.indent += len(content) - len(rest)
+		content = rest
+	}
+	if match := pattern.FindStringSubmatchIndex(content); match != nil {
+		entry.key = strings.TrimSpace(content[match[2]:match[3]])
+		rest := content[match[1]:]
+		value := strings.TrimLeft(rest, " ")
+		entry.value, entry.valueStart = value, entry.indent+len(content)-len(value)
+	} else {
+		entry.value, entry.valueStart = content, entry.indent
+	}
+	return entry
+}
+
+// yamlParents returns the keys of the mapping entries enclosing a line whose content is indented by indent,
+// outermost first. A list may be indented like the key holding it, so after a list item the key of the list can be
+// as indented as the dash.
+func yamlParents(lines []string, index, indent int, inList bool) []string {
+	var keys []string
+	for i := index - 1; i >= 0; i-- {
+		entry := parseYAMLEntry(lines[i], yamlEntryPattern)
+		if entry.blank {
+			continue
+		}
+		// The key of an entry enclosing the line, which may be the first entry of a list item, e.g. - Condition:
+		if entry.key != "" && (entry.indent < indent || inList && !entry.item && entry.indent == indent) {
+			keys = append([]string{entry.key}, keys...)
+			indent, inList = entry.indent, false
+		}
+		// A list item enclosing the line, or one of the items before it in the same list.
+		if entry.item && (entry.dashIndent < indent || inList && entry.dashIndent == indent) {
+			indent, inList = entry.dashIndent, true
+		}
+	}
+	return keys
+}
+
+// yamlContext scans the YAML lines before the cursor.
+func yamlContext(text string, cursor int) *completionContext {
+	lineStart := strings.LastIndex(text[:cursor], "\n") + 1
+	lines := strings.Split(text[:lineStart], "\n")
+	index := len(lines) - 1
+	entry := parseYAMLEntry(text[lineStart:cursor], yamlTypedEntryPattern)
+	if entry.blank && !entry.item {
+		return nil
+	}
+
+	context := &completionContext{}
+	switch {
+	case entry.key != "" && entry.item:
+		// The value of the first key of a list item, e.g. - Effect: Al
+		context.keys = append(yamlParents(lines, index, entry.dashIndent, true), entry.key)
+	case entry.key != "":
+		// The value of a key on the same line, e.g. Effect: Al
+		context.keys = append(yamlParents(lines, index, entry.indent, false), entry.key)
+	case entry.item:
+		// An item of the list of the key above, e.g. - s3:Get
+		context.keys = yamlParents(lines, index, entry.dashIndent, true)
+	default:
+		context.key = true
+		context.keys = yamlParents(lines, index, entry.indent, false)
+	}
+	value, start := entry.value, entry.valueStart
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		value, start = value[1:], start+1
+	}
+	context.prefix, context.start = value, lineStart+start
+	return context
+}
+
+// actionToken matches the characters of an action name or pattern.
+var actionToken = regexp.MustCompile(`[A-Za-z0-9:*?_-]`)
+
+// hoverAt documents the action, or the action pattern, under the cursor.
+func hoverAt(text string, p Position) *Hover {
+	cursor := offset(text, p)
+	start, end := cursor, cursor
+	for start > 0 && actionToken.MatchString(text[start-1:start]) {
+		start--
+	}
+	for end < len(text) && actionToken.MatchString(text[end:end+1]) {
+		end++
+	}
+	token := text[start:end]
+	if !strings.Contains(token, ":") {
+		return nil
+	}
+
+	var contents string
+	if name, level, ok := catalog.Action(token); ok {
+		service, _ := catalog.Lookup(strings.SplitN(name, ":", 2)[0])
+		contents = fmt.Sprintf("**%s**\n\n%s action, access level: %s", name, service.Name, level)
+	} else if strings.ContainsAny(token, "*?") {
+		actions, complete := catalog.Expand(token)
+		if len(actions) == 0 {
+			return nil
+		}
+		contents = fmt.Sprintf("**%s** matches %d actions of the catalog", token, len(actions))
+		if !complete {
+			contents = fmt.Sprintf("**%s** matches at least %d actions, some services are not in the catalog", token, len(actions))
+		}
+		contents += ":\n"
+		for i, action := range actions {
+			if i == maxHoverActions {
+				contents += fmt.Sprintf("- and %d more\n", len(actions)-maxHoverActions)
+				break
+			}
+			contents += "- " + action + "\n"
+		}
+	} else {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: contents},
+		Range:    &Range{Start: position(text, start), End: position(text, end)},
+	}
+}
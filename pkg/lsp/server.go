@@ -0,0 +1,347 @@
+package lsp
+
+/*
+This file provides a language server for policy files, which editors run as iam-json-verifier lsp and talk to over
+stdin and stdout. Documents are synchronized in full on each change, and checked as validate checks files:
+each finding is published as a diagnostic, with the configuration file found from the directory of the document.
+Documents that do not mention a Statement are not policies, e.g. other JSON files of a project, and get no
+diagnostics.
+
+Requests are handled one at a time, in the order they arrive; checking a policy is fast enough for this to keep up
+with typing.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/baseline"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoShutdown is returned by Run when the client exits without asking the server to shut down first.
+var ErrNoShutdown = errors.New("exit without shutdown")
+
+type Server struct {
+	// Config is the configuration of every document, instead of the configuration file found for each of them.
+	Config *config.Config
+
+	in          *bufio.Reader
+	out         io.Writer
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+type document struct {
+	uri     string
+	path    string
+	version int
+	text    string
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, documents: map[string]*document{}}
+}
+
+// Run serves the client until it sends exit. It returns nil when the client shut the server down first.
+func (s *Server) Run() error {
+	for {
+		m, err := readMessage(s.in)
+		var parseErr *responseError
+		if errors.As(err, &parseErr) {
+			if err := s.respond(nil, nil, parseErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return ErrNoShutdown
+		}
+		if err != nil {
+			return err
+		}
+
+		if m.Method == "exit" {
+			if s.shutdown {
+				return nil
+			}
+			return ErrNoShutdown
+		}
+		result, err := s.handle(m)
+		// Notifications have no ID and get no response, even when they fail.
+		if m.ID != nil {
+			if err := s.respond(m.ID, result, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) handle(m *message) (interface{}, error) {
+	if !s.initialized && m.Method != "initialize" {
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	}
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch m.Method {
+	case "initialize":
+		s.initialized = true
+		return initializeResult(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument TextDocumentItem `json:"textDocument"`
+		}
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		doc := &document{uri: params.TextDocument.URI, path: uriPath(params.TextDocument.URI),
+			version: params.TextDocument.Version, text: params.TextDocument.Text}
+		s.documents[doc.uri] = doc
+		return nil, s.publishDiagnostics(doc)
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI     string `json:"uri"`
+				Version int    `json:"version"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Range *Range `json:"range"`
+				Text  string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		// Changes replace the whole text, as the server asks for, unless the client sends ranges anyway.
+		for _, change := range params.ContentChanges {
+			if change.Range == nil {
+				doc.text = change.Text
+				continue
+			}
+			start, end := offset(doc.text, change.Range.Start), offset(doc.text, change.Range.End)
+			doc.text = doc.text[:start] + change.Text + doc.text[end:]
+		}
+		doc.version = params.TextDocument.Version
+		return nil, s.publishDiagnostics(doc)
+	case "textDocument/didSave":
+		var params struct {
+			TextDocument TextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		// The configuration file may have been saved, so open documents are checked again.
+		if doc, ok := s.documents[params.TextDocument.URI]; ok {
+			return nil, s.publishDiagnostics(doc)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument TextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return CompletionList{Items: []CompletionItem{}}, nil
+		}
+		return complete(doc.text, params.Position), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		if hover := hoverAt(doc.text, params.Position); hover != nil {
+			return hover, nil
+		}
+		return nil, nil
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if err := decodeParams(m, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return []CodeAction{}, nil
+		}
+		return codeActions(doc, params, s.config(doc)), nil
+	}
+
+	if m.ID == nil {
+		// Notifications the server does not handle, such as $/cancelRequest, are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q is not supported", m.Method)}
+}
+
+func initializeResult() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			// Full synchronization: each change sends the whole document.
+			"textDocumentSync":   1,
+			"completionProvider": map[string]interface{}{"triggerCharacters": []string{`"`, ":", " "}},
+			"hoverProvider":      true,
+			"codeActionProvider": map[string]interface{}{"codeActionKinds": []string{kindQuickFix, kindFixAll}},
+		},
+		"serverInfo": map[string]string{"name": "iam-json-verifier"},
+	}
+}
+
+// config returns the configuration of a document, the defaults when its configuration file cannot be loaded.
+func (s *Server) config(doc *document) *config.Config {
+	if s.Config != nil {
+		return s.Config
+	}
+	path, err := config.Find(filepath.Dir(doc.path))
+	if err == nil && path != "" {
+		var cfg *config.Config
+		if cfg, err = config.Load(path); err == nil {
+			return cfg
+		}
+	}
+	if err != nil {
+		s.notify("window/logMessage", map[string]interface{}{"type": 1, "message": fmt.Sprintf("Error loading configuration: %s", err)})
+	}
+	return config.Default()
+}
+
+func (s *Server) publishDiagnostics(doc *document) error {
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: doc.uri, Version: doc.version, Diagnostics: s.diagnostics(doc)})
+}
+
+func (s *Server) diagnostics(doc *document) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	name := filepath.Base(doc.path)
+	if name == config.FileName || name == baseline.DefaultPath || !strings.Contains(doc.text, "Statement") {
+		return diagnostics
+	}
+
+	checker := report.Checker{Config: s.config(doc), Templates: true}
+	for _, finding := range checker.Check(doc.path, []byte(doc.text)).Findings {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    findingRange(doc.text, finding),
+			Severity: diagnosticSeverity(finding.Severity),
+			Code:     finding.Rule,
+			Source:   "iam-json-verifier",
+			Message:  finding.Message,
+		})
+	}
+	return diagnostics
+}
+
+// findingRange returns the range of the token a finding points to: a string with its quotes, or a plain value.
+func findingRange(text string, finding report.Finding) Range {
+	if finding.Line == 0 {
+		return Range{}
+	}
+	start := lineOffset(text, finding.Line, finding.Column)
+	end := start
+	if end < len(text) && text[end] == '"' {
+		for end++; end < len(text) && text[end] != '"' && text[end] != '\n'; end++ {
+			if text[end] == '\\' {
+				end++
+			}
+		}
+		if end < len(text) && text[end] == '"' {
+			end++
+		}
+	} else {
+		for end < len(text) && !strings.ContainsRune(",{}[]\n\r", rune(text[end])) {
+			end++
+		}
+		for end > start && (text[end-1] == ' ' || text[end-1] == '\t') {
+			end--
+		}
+	}
+	if end == start && end < len(text) && text[end] != '\n' {
+		end++
+	}
+	return Range{Start: position(text, start), End: position(text, end)}
+}
+
+func diagnosticSeverity(severity lint.Severity) int {
+	switch severity {
+	case lint.SeverityError:
+		return severityError
+	case lint.SeverityWarning:
+		return severityWarning
+	}
+	return severityInfo
+}
+
+func decodeParams(m *message, params interface{}) error {
+	if err := json.Unmarshal(m.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) respond(id *json.RawMessage, result interface{}, err error) error {
+	response := &message{ID: id}
+	if id == nil {
+		null := json.RawMessage("null")
+		response.ID = &null
+	}
+	if err != nil {
+		var rpcErr *responseError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+		}
+		response.Error = rpcErr
+		return writeMessage(s.out, response)
+	}
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	response.Result = data
+	return writeMessage(s.out, response)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: data})
+}
+
+// uriPath returns the file path of a file URI, or the URI itself for other schemes.
+func uriPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(parsed.Path)
+}
@@ -0,0 +1,243 @@
+package minimize
+
+/*
+This file shrinks a policy without changing what it allows, to stay under the IAM policy size limits.
+Minimize:
+ - removes Action and Resource entries that are duplicated or covered by another entry of the same statement,
+ - merges statements sharing Effect, Principal and Condition when they also share their actions or their resources,
+ - with Options.Wildcards, replaces lists of actions with wildcards, such as s3:Get*, where the catalog proves the
+   wildcard matches exactly the listed actions.
+
+Wildcards are opt-in because they are not equivalent to the actions they replace: the catalog is a partial snapshot,
+and a wildcard also matches the actions AWS adds later, so in an Allow statement it grants more than the list did.
+
+Statements using NotAction or NotResource are kept as they are. Verify uses the simulator to check that
+the minimized policy takes the same decision as the original one for every action and resource they mention;
+it only knows the actions of the catalog, so it cannot tell a wildcard from the list it replaced.
+*/
+
+import (
+	"encoding/json"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Options struct {
+	// Wildcards replaces lists of actions with the wildcards matching exactly them in the catalog, which also
+	// match the actions missing from the catalog or added to AWS later.
+	Wildcards bool
+}
+
+func Minimize(policy validator.IAMPolicy, options Options) validator.IAMPolicy {
+	statements := make([]validator.Statement, len(policy.PolicyDocument.Statement))
+	for i, statement := range policy.PolicyDocument.Statement {
+		if statement.Action != nil {
+			statement.Action = removeCovered(validator.StringValues(statement.Action), true)
+		}
+		if statement.Resource != nil {
+			statement.Resource = removeCovered(validator.StringValues(statement.Resource), false)
+		}
+		statements[i] = statement
+	}
+
+	statements = mergeStatements(statements)
+
+	for i := range statements {
+		if options.Wildcards && statements[i].Action != nil {
+			actions := collapseActions(validator.StringValues(statements[i].Action))
+			statements[i].Action = removeCovered(actions, true)
+		}
+	}
+
+	policy.PolicyDocument.Statement = statements
+	return policy
+}
+
+// Size returns the size of the policy as AWS counts it against the quotas, i.e. without whitespace.
+func Size(policy validator.IAMPolicy) int {
+	data, err := json.Marshal(policy.PolicyDocument)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// mergeStatements merges pairs of statements until no pair can be merged anymore.
+func mergeStatements(statements []validator.Statement) []validator.Statement {
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(statements) && !merged; i++ {
+			for j := i + 1; j < len(statements); j++ {
+				if statement, ok := merge(statements[i], statements[j]); ok {
+					statements[i] = statement
+					statements = append(statements[:j], statements[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+	return statements
+}
+
+// merge combines two statements into one applying to exactly the same requests.
+// This is only possible when they differ by their actions or by their resources, but not by both.
+func merge(a, b validator.Statement) (validator.Statement, bool) {
+	if a.Effect != b.Effect || a.NotAction != nil || b.NotAction != nil || a.NotResource != nil || b.NotResource != nil ||
+		(a.Action == nil) != (b.Action == nil) || (a.Resource == nil) != (b.Resource == nil) ||
+		!reflect.DeepEqual(a.Principal, b.Principal) || !reflect.DeepEqual(a.NotPrincipal, b.NotPrincipal) ||
+		!reflect.DeepEqual(a.Conditions, b.Conditions) {
+		return validator.Statement{}, false
+	}
+
+	aActions, bActions := validator.StringValues(a.Action), validator.StringValues(b.Action)
+	aResources, bResources := validator.StringValues(a.Resource), validator.StringValues(b.Resource)
+
+	switch {
+	case sameSet(aResources, bResources, false):
+		a.Action = removeCovered(append(append([]string{}, aActions...), bActions...), true)
+	case sameSet(aActions, bActions, true):
+		a.Resource = removeCovered(append(append([]string{}, aResources...), bResources...), false)
+	default:
+		return validator.Statement{}, false
+	}
+	if a.Sid == "" {
+		a.Sid = b.Sid
+	}
+	return a, true
+}
+
+// collapseActions replaces groups of listed actions with a wildcard when the catalog proves they are equivalent.
+// Wildcards always end at a word boundary of the action name (s3:Get*, s3:GetObject*), never in the middle of a word.
+func collapseActions(actions []string) []string {
+	granted := map[string]bool{}
+	candidates := map[string][]string{}
+	var result []string
+
+	for _, action := range actions {
+		if strings.ContainsAny(action, "*?") {
+			if expanded, ok := catalog.Expand(action); ok {
+				for _, name := range expanded {
+					granted[strings.ToLower(name)] = true
+				}
+			}
+			result = append(result, action)
+			continue
+		}
+		name, _, ok := catalog.Action(action)
+		if !ok {
+			result = append(result, action)
+			continue
+		}
+		granted[strings.ToLower(name)] = true
+		prefix := name[:strings.Index(name, ":")]
+		candidates[prefix] = append(candidates[prefix], name)
+	}
+
+	prefixes := make([]string, 0, len(candidates))
+	for prefix := range candidates {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		names := candidates[prefix]
+		sort.Strings(names)
+		covered := map[string]bool{}
+		for _, name := range names {
+			if covered[name] {
+				continue
+			}
+			pattern, matched := widestWildcard(name, granted)
+			for _, action := range matched {
+				covered[action] = true
+			}
+			result = append(result, pattern)
+		}
+	}
+	return result
+}
+
+// widestWildcard returns the widest wildcard matching the action whose catalog expansion is entirely granted,
+// together with the actions it matches. The action itself is returned when no wildcard matches at least two actions.
+func widestWildcard(action string, granted map[string]bool) (string, []string) {
+	colon := strings.Index(action, ":")
+	prefix, name := action[:colon+1], action[colon+1:]
+
+	for i := 0; i < len(name); i++ {
+		if i > 0 && !unicode.IsUpper(rune(name[i])) {
+			continue
+		}
+		pattern := prefix + name[:i] + "*"
+		expanded, ok := catalog.Expand(pattern)
+		if !ok || len(expanded) < 2 || !allGranted(expanded, granted) {
+			continue
+		}
+		return pattern, expanded
+	}
+	return action, []string{action}
+}
+
+func allGranted(actions []string, granted map[string]bool) bool {
+	for _, action := range actions {
+		if !granted[strings.ToLower(action)] {
+			return false
+		}
+	}
+	return true
+}
+
+// removeCovered removes the entries that are duplicated or matched by another entry of the same list.
+func removeCovered(values []string, ignoreCase bool) []interface{} {
+	normalize := func(value string) string {
+		if ignoreCase {
+			return strings.ToLower(value)
+		}
+		return value
+	}
+
+	var kept []string
+	for i, value := range values {
+		redundant := false
+		for j, other := range values {
+			if i == j {
+				continue
+			}
+			a, b := normalize(value), normalize(other)
+			// Entries matching exactly the same values, like duplicates, are only removed after their first occurrence.
+			equivalent := simulator.PatternCovers(a, b) && simulator.PatternCovers(b, a)
+			if (equivalent && j < i) || (!equivalent && simulator.PatternCovers(b, a)) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			kept = append(kept, value)
+		}
+	}
+
+	result := make([]interface{}, len(kept))
+	for i, value := range kept {
+		result[i] = value
+	}
+	return result
+}
+
+func sameSet(a, b []string, ignoreCase bool) bool {
+	set := func(values []string) map[string]bool {
+		m := make(map[string]bool, len(values))
+		for _, value := range values {
+			if ignoreCase {
+				value = strings.ToLower(value)
+			}
+			m[value] = true
+		}
+		return m
+	}
+	return reflect.DeepEqual(set(a), set(b))
+}
@@ -0,0 +1,103 @@
+package minimize
+
+/*
+This file checks that two policies are semantically identical by simulating the same requests against both.
+The requests combine every action and resource the policies mention: concrete actions as they are,
+wildcard actions expanded through the catalog (or instantiated, for services the catalog does not know),
+and resource patterns instantiated by replacing their wildcards. Each combination is evaluated without context
+and with a context satisfying the conditions of each statement.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+	"strings"
+)
+
+// unmatchedResource is a resource no reasonable policy names, to exercise NotResource and wildcard-only statements.
+const unmatchedResource = "arn:aws:verifier:::unmatched-resource"
+
+// Verify returns an error describing the first request for which the two policies take different decisions.
+func Verify(original, minimized validator.IAMPolicy) error {
+	actions, resources, contexts := map[string]bool{}, map[string]bool{unmatchedResource: true}, []map[string][]string{nil}
+
+	for _, policy := range []validator.IAMPolicy{original, minimized} {
+		for _, statement := range policy.PolicyDocument.Statement {
+			for _, element := range []interface{}{statement.Action, statement.NotAction} {
+				for _, pattern := range validator.StringValues(element) {
+					for _, action := range probeActions(pattern) {
+						actions[action] = true
+					}
+				}
+			}
+			for _, element := range []interface{}{statement.Resource, statement.NotResource} {
+				for _, pattern := range validator.StringValues(element) {
+					resources[instantiate(pattern)] = true
+				}
+			}
+			if context := conditionContext(statement.Conditions); context != nil {
+				contexts = append(contexts, context)
+			}
+		}
+	}
+
+	originalPolicies := []validator.IAMPolicy{original}
+	minimizedPolicies := []validator.IAMPolicy{minimized}
+	for _, action := range sortedKeys(actions) {
+		for _, resource := range sortedKeys(resources) {
+			for _, context := range contexts {
+				request := simulator.Request{Action: action, Resource: resource, Context: context}
+				want := simulator.Evaluate(originalPolicies, request).Decision
+				got := simulator.Evaluate(minimizedPolicies, request).Decision
+				if want != got {
+					return fmt.Errorf("%s on %s: original policy decides %s, minimized policy decides %s",
+						action, resource, want, got)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func probeActions(pattern string) []string {
+	if !strings.ContainsAny(pattern, "*?") {
+		return []string{pattern}
+	}
+	if expanded, ok := catalog.Expand(pattern); ok {
+		return expanded
+	}
+	return []string{instantiate(pattern)}
+}
+
+// instantiate turns a pattern into a value it matches.
+func instantiate(pattern string) string {
+	return strings.NewReplacer("*", "x", "?", "x").Replace(pattern)
+}
+
+// conditionContext builds a request context from the first value of each condition key.
+func conditionContext(conditions map[string]validator.ConditionMap) map[string][]string {
+	if len(conditions) == 0 {
+		return nil
+	}
+	context := map[string][]string{}
+	for _, keys := range conditions {
+		for key, values := range keys {
+			if len(values) > 0 {
+				context[key] = []string{instantiate(values[0])}
+			}
+		}
+	}
+	return context
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,171 @@
+package policytest
+
+/*
+This file provides declarative unit tests for IAM policies.
+A test suite is a YAML or JSON file listing the policies under test and the requests to evaluate against them:
+
+	policies:
+	  - policies/reader.json
+	cases:
+	  - name: can read bucket A
+	    action: s3:GetObject
+	    resource: arn:aws:s3:::bucket-a/report.csv
+	    expect: allow
+	  - name: cannot read bucket B
+	    action: s3:GetObject
+	    resource: arn:aws:s3:::bucket-b/report.csv
+	    expect: deny
+
+Policy paths are resolved relative to the suite file. Every policy must pass ValidateIAMPolicy before any case runs.
+Each case is evaluated with the local simulator and passes when the decision matches the expectation.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+type Suite struct {
+	Path     string   `yaml:"-"`
+	Policies []string `yaml:"policies"`
+	Cases    []Case   `yaml:"cases"`
+}
+
+type Case struct {
+	Name      string                `yaml:"name"`
+	Principal string                `yaml:"principal,omitempty"`
+	Action    string                `yaml:"action"`
+	Resource  string                `yaml:"resource"`
+	Context   map[string]stringList `yaml:"context,omitempty"`
+	Expect    string                `yaml:"expect"`
+}
+
+type CaseResult struct {
+	Case   Case
+	Result simulator.Result
+	Passed bool
+}
+
+type Report struct {
+	Suite   string
+	Results []CaseResult
+}
+
+// stringList accepts either a single string or a list of strings, like most policy elements.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+var expectations = map[string][]simulator.Decision{
+	"allow":        {simulator.Allowed},
+	"deny":         {simulator.ExplicitDeny, simulator.ImplicitDeny},
+	"explicitdeny": {simulator.ExplicitDeny},
+	"implicitdeny": {simulator.ImplicitDeny},
+}
+
+func LoadSuite(path string) (*Suite, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite Suite
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&suite); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	suite.Path = path
+
+	if len(suite.Policies) == 0 {
+		return nil, fmt.Errorf("%s: at least one policy is required", path)
+	}
+	for i, c := range suite.Cases {
+		if c.Action == "" {
+			return nil, fmt.Errorf("%s: case %d: action is required", path, i+1)
+		}
+		if !IsExpectation(c.Expect) {
+			return nil, fmt.Errorf("%s: case %d: expect must be one of allow, deny, explicitDeny or implicitDeny", path, i+1)
+		}
+	}
+	return &suite, nil
+}
+
+// Run loads and validates the suite's policies and evaluates every case against them.
+func (s *Suite) Run() (Report, error) {
+	var policies []validator.IAMPolicy
+	for _, policyPath := range s.Policies {
+		if !filepath.IsAbs(policyPath) {
+			policyPath = filepath.Join(filepath.Dir(s.Path), policyPath)
+		}
+		policy, err := validator.LoadPolicyFile(policyPath)
+		if err != nil {
+			return Report{}, fmt.Errorf("%s: %v", policyPath, err)
+		}
+		policies = append(policies, policy)
+	}
+
+	report := Report{Suite: s.Path}
+	for _, c := range s.Cases {
+		request := simulator.Request{
+			Principal: c.Principal,
+			Action:    c.Action,
+			Resource:  c.Resource,
+			Context:   make(map[string][]string, len(c.Context)),
+		}
+		for key, values := range c.Context {
+			request.Context[key] = values
+		}
+
+		result := simulator.Evaluate(policies, request)
+		report.Results = append(report.Results, CaseResult{
+			Case:   c,
+			Result: result,
+			Passed: len(result.Errors) == 0 && Expects(c.Expect, result.Decision),
+		})
+	}
+	return report, nil
+}
+
+func (r Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// IsExpectation reports whether expect is one of allow, deny, explicitDeny or implicitDeny, in any case.
+func IsExpectation(expect string) bool {
+	_, ok := expectations[strings.ToLower(expect)]
+	return ok
+}
+
+// Expects reports whether a decision satisfies an expectation, deny covering both explicit and implicit denies.
+func Expects(expect string, decision simulator.Decision) bool {
+	for _, allowed := range expectations[strings.ToLower(expect)] {
+		if allowed == decision {
+			return true
+		}
+	}
+	return false
+}
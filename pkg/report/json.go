@@ -0,0 +1,91 @@
+package report
+
+/*
+This file writes findings as JSON. The json format is a single document listing every file, with its findings and
+a summary of the run; the ndjson format writes one finding per line as it would be streamed to a log pipeline,
+each line naming its file, and one line per file that could not be read.
+*/
+
+import (
+	"encoding/json"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"io"
+)
+
+type jsonReport struct {
+	Files   []jsonFile `json:"files"`
+	Summary Summary    `json:"summary"`
+}
+
+type jsonFile struct {
+	File     string        `json:"file"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+	Findings []jsonFinding `json:"findings"`
+}
+
+type jsonFinding struct {
+	File     string        `json:"file,omitempty"`
+	Rule     string        `json:"rule"`
+	Severity lint.Severity `json:"severity"`
+	Message  string        `json:"message"`
+	// Element is the path of the policy element, e.g. PolicyDocument.Statement[1].Action[0].
+	Element     string `json:"element,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Column      int    `json:"column,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func WriteJSON(w io.Writer, files []File) error {
+	report := jsonReport{Files: []jsonFile{}, Summary: Summarize(files)}
+	for _, file := range files {
+		entry := jsonFile{File: file.Path, Passed: !file.Failed(), Findings: []jsonFinding{}}
+		if file.Err != nil {
+			entry.Error = file.Err.Error()
+		}
+		for _, finding := range file.Findings {
+			entry.Findings = append(entry.Findings, newJSONFinding(file.Path, finding))
+		}
+		report.Files = append(report.Files, entry)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func WriteNDJSON(w io.Writer, files []File) error {
+	encoder := json.NewEncoder(w)
+	for _, file := range files {
+		if file.Err != nil {
+			line := struct {
+				File  string `json:"file"`
+				Error string `json:"error"`
+			}{file.Path, file.Err.Error()}
+			if err := encoder.Encode(line); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, finding := range file.Findings {
+			line := newJSONFinding(file.Path, finding)
+			line.File = file.Path
+			if err := encoder.Encode(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newJSONFinding(path string, finding Finding) jsonFinding {
+	return jsonFinding{
+		Rule:        finding.Rule,
+		Severity:    finding.Severity,
+		Message:     finding.Message,
+		Element:     finding.Path,
+		Line:        finding.Line,
+		Column:      finding.Column,
+		Fingerprint: Fingerprint(path, finding),
+	}
+}
@@ -0,0 +1,85 @@
+package report
+
+/*
+This file writes findings as a JUnit XML report, the test report format most CI systems display.
+Each file is a test case, named after its path and classed by its directory, and each of its findings is a failure,
+whatever its severity. A file that cannot be read is a test case in error.
+
+More information about the format understood by CI systems can be found here:
+ - https://github.com/testmoapp/junitxml
+*/
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+const junitSuite = "aws-iam-policy-verifier"
+
+type junitTestSuites struct {
+	XMLName  xml.Name        `xml:"testsuites"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Suites   []junitSuiteXML `xml:"testsuite"`
+}
+
+type junitSuiteXML struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	File      string         `xml:"file,attr"`
+	Failures  []junitFailure `xml:"failure"`
+	Error     *junitFailure  `xml:"error"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func WriteJUnit(w io.Writer, files []File) error {
+	suite := junitSuiteXML{Name: junitSuite, Tests: len(files)}
+	for _, file := range files {
+		testCase := junitTestCase{Name: file.Path, ClassName: filepath.ToSlash(filepath.Dir(file.Path)), File: file.Path}
+		if file.Err != nil {
+			testCase.Error = &junitFailure{Message: file.Err.Error(), Type: "readError", Text: file.Err.Error()}
+			suite.Errors++
+		}
+		for _, finding := range file.Findings {
+			text := fmt.Sprintf("%s: %s %s", file.Path, finding.Severity, finding)
+			if finding.Path != "" {
+				text += " at " + finding.Path
+			}
+			testCase.Failures = append(testCase.Failures, junitFailure{Message: finding.Message, Type: finding.Rule, Text: text})
+		}
+		if len(testCase.Failures) > 0 {
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	report := junitTestSuites{Name: junitSuite, Tests: suite.Tests, Failures: suite.Failures, Errors: suite.Errors,
+		Suites: []junitSuiteXML{suite}}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,496 @@
+package report
+
+/*
+This file checks policy files and collects their findings in a form every output format can be written from.
+A file is checked in three steps, each finding being located in the original file, JSON or YAML:
+ - decoding, whose errors are located by the loader,
+ - validation, statement by statement so that every invalid statement is reported,
+ - the lint rules, for identity policies that could be decoded.
+Each finding carries the key of its message in the error catalog, which is its rule.
+
+A file holds an identity policy, with a PolicyName and a PolicyDocument, unless another policy type is given:
+resource-based policies, KMS key policies and role trust policies are written as the policy document alone.
+The lint rules are written for identity policies and only run on them.
+
+A Checker applies the settings of a configuration file: the policy type of each path, the rules that are enabled
+and their severity, and the accounts policies may refer to. The package functions use the defaults.
+
+A reader, such as stdin, may hold several JSON policies one after another: each is checked as a file of its own,
+named after the reader and its rank, and its findings keep their line and column in the whole stream.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cfn"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/jsonast"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleInvalidFormat is the rule of the decoding errors that are not in the catalog, such as JSON syntax errors.
+const RuleInvalidFormat = "invalidFormat"
+
+type PolicyType string
+
+const (
+	TypeIdentity PolicyType = "identity"
+	TypeResource PolicyType = "resource"
+	TypeKey      PolicyType = "key"
+	TypeTrust    PolicyType = "trust"
+)
+
+// PolicyTypes lists the supported policy types.
+var PolicyTypes = []PolicyType{TypeIdentity, TypeResource, TypeKey, TypeTrust}
+
+// statementValidators validate a single statement of the policy types written as a policy document alone.
+var statementValidators = map[PolicyType]func(validator.Statement) (bool, error){
+	TypeResource: validator.ValidateResourceStatement,
+	TypeKey:      validator.ValidateKeyStatement,
+	TypeTrust:    validator.ValidateTrustStatement,
+}
+
+type Finding struct {
+	Rule     string
+	Severity lint.Severity
+	Message  string
+	// Path locates the element in the policy, e.g. PolicyDocument.Statement[1].Action[0]. It is empty for decoding errors.
+	Path string
+	// Line and Column are 1-based positions in the file, 0 when unknown. Columns count characters, not bytes.
+	Line   int
+	Column int
+	// EndLine is the last line of an element spanning several lines, such as a template resource, 0 otherwise.
+	EndLine int
+	// Statement identifies the statement of the element by its Sid, or by a hash of its content without one, so that
+	// it does not change when statements are inserted or reordered. It is empty for elements outside statements.
+	Statement string
+}
+
+// Summary counts the files checked and their findings by severity.
+type Summary struct {
+	Files    int `json:"files"`
+	Failed   int `json:"failed"`
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+	Infos    int `json:"infos"`
+}
+
+type File struct {
+	Path     string
+	Findings []Finding
+	// Err is set when the file could not be read.
+	Err error
+}
+
+// Checker checks policies with the settings of a configuration: the policy type of their path, the enabled
+// rules and their severity, and the allowed accounts.
+type Checker struct {
+	// Config is the configuration applied, the defaults when nil.
+	Config *config.Config
+	// PolicyType is the type of every policy checked, instead of the type the configuration gives their path.
+	PolicyType PolicyType
+	// Templates checks the files that are CloudFormation templates for the policies of their resources.
+	Templates bool
+}
+
+// CheckFile reads and checks the identity policy at path.
+func CheckFile(path string) File {
+	return CheckFileAs(path, TypeIdentity)
+}
+
+// CheckFileAs reads and checks the policy of the given type at path.
+func CheckFileAs(path string, policyType PolicyType) File {
+	return Checker{PolicyType: policyType}.CheckFile(path)
+}
+
+// CheckReader reads and checks the policies of the given type of r, named name, one file per document.
+func CheckReader(name string, r io.Reader, policyType PolicyType) []File {
+	return Checker{PolicyType: policyType}.CheckReader(name, r)
+}
+
+// Check checks an identity policy read from path, and returns its findings ordered by position.
+func Check(path string, data []byte) File {
+	return CheckAs(path, data, TypeIdentity)
+}
+
+// CheckAs checks a policy of the given type read from path, and returns its findings ordered by position.
+func CheckAs(path string, data []byte, policyType PolicyType) File {
+	return Checker{PolicyType: policyType}.Check(path, data)
+}
+
+// CheckFile reads and checks the policy at path.
+func (c Checker) CheckFile(path string) File {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return File{Path: path, Err: err}
+	}
+	return c.Check(path, data)
+}
+
+// CheckReader reads and checks the policies of r, named name, one file per document.
+func (c Checker) CheckReader(name string, r io.Reader) []File {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return []File{{Path: name, Err: err}}
+	}
+
+	documents := validator.SplitDocuments(data)
+	if len(documents) <= 1 {
+		return []File{c.Check(name, data)}
+	}
+	var files []File
+	for i, document := range documents {
+		file := c.Check(fmt.Sprintf("%s#%d", name, i+1), document.Data)
+		line, column := jsonast.Position(data, document.Offset)
+		for j, finding := range file.Findings {
+			if finding.Line == 1 {
+				finding.Column += column - 1
+			}
+			if finding.Line > 0 {
+				finding.Line += line - 1
+			}
+			file.Findings[j] = finding
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// Check checks a policy read from path, and returns its findings ordered by position.
+func (c Checker) Check(path string, data []byte) File {
+	cfg := c.Config
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	policyType := c.PolicyType
+	if policyType == "" {
+		policyType = PolicyType(cfg.PolicyType(path))
+	}
+
+	var template *cfn.Template
+	if c.Templates {
+		template, _ = cfn.ParseTemplate(data)
+	}
+	var findings []Finding
+	if template != nil {
+		findings = checkTemplate(template)
+	} else {
+		findings = check(data, policyType, cfg)
+	}
+
+	return newFile(path, findings, cfg)
+}
+
+// Lint checks an identity policy read from path with the lint rules alone, as the lint command does, and returns
+// its findings ordered by position. A policy the rules cannot read gets a single invalidFormat finding.
+func (c Checker) Lint(path string, data []byte) File {
+	cfg := c.Config
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	source, err := validator.NewSource(data)
+	if err != nil {
+		return File{Path: path, Findings: []Finding{decodingFinding(err)}}
+	}
+	doc, err := lint.NewDocument(data)
+	if err != nil {
+		return File{Path: path, Findings: []Finding{decodingFinding(err)}}
+	}
+
+	var findings []Finding
+	for _, lintFinding := range lint.LintDocument(doc) {
+		finding := locate(source, Finding{
+			Rule:     lintFinding.Rule,
+			Severity: lintFinding.Severity,
+			Message:  lintFinding.Message,
+			Path:     lintFinding.Path,
+		})
+		if index, ok := statementIndex(finding.Path, "PolicyDocument."); ok && doc.Decoded && index < len(doc.Policy.PolicyDocument.Statement) {
+			finding.Statement = statementID(doc.Policy.PolicyDocument.Statement[index])
+		}
+		findings = append(findings, finding)
+	}
+	return newFile(path, findings, cfg)
+}
+
+// newFile returns the file of the findings of the rules cfg enables, with the severities of cfg, ordered by position.
+func newFile(path string, findings []Finding, cfg *config.Config) File {
+	file := File{Path: path}
+	for _, finding := range findings {
+		if cfg.RuleEnabled(finding.Rule) {
+			finding.Severity = cfg.Severity(finding.Rule, finding.Severity)
+			file.Findings = append(file.Findings, finding)
+		}
+	}
+	sort.SliceStable(file.Findings, func(i, j int) bool {
+		a, b := file.Findings[i], file.Findings[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return file
+}
+
+func check(data []byte, policyType PolicyType, cfg *config.Config) []Finding {
+	source, err := validator.NewSource(data)
+	if err != nil {
+		return []Finding{decodingFinding(err)}
+	}
+
+	var findings []Finding
+	var document validator.PolicyDocument
+	prefix := ""
+	if validateStatement, ok := statementValidators[policyType]; ok {
+		if document, err = source.DecodeDocument(); err != nil {
+			return []Finding{decodingFinding(err)}
+		}
+		findings = validateDocument(document, prefix, validateStatement)
+	} else {
+		policy, err := source.Decode()
+		if err != nil {
+			return []Finding{decodingFinding(err)}
+		}
+		document, prefix = policy.PolicyDocument, "PolicyDocument."
+		findings = validate(policy)
+		doc := &lint.Document{Source: source.JSON, Root: source.Root, Policy: policy, Decoded: true}
+		for _, finding := range lint.LintDocument(doc) {
+			findings = append(findings, Finding{
+				Rule:     finding.Rule,
+				Severity: finding.Severity,
+				Message:  finding.Message,
+				Path:     finding.Path,
+			})
+		}
+	}
+	findings = append(findings, accountFindings(document, prefix, cfg)...)
+
+	for i, finding := range findings {
+		finding = locate(source, finding)
+		if index, ok := statementIndex(finding.Path, prefix); ok && index < len(document.Statement) {
+			finding.Statement = statementID(document.Statement[index])
+		}
+		findings[i] = finding
+	}
+	return findings
+}
+
+// statementIndex returns the index of the statement an element under prefix is in.
+func statementIndex(path, prefix string) (int, bool) {
+	rest := strings.TrimPrefix(path, prefix+"Statement[")
+	end := strings.Index(rest, "]")
+	if rest == path || end == -1 {
+		return 0, false
+	}
+	index, err := strconv.Atoi(rest[:end])
+	return index, err == nil
+}
+
+// statementID identifies a statement by its Sid, or by a hash of its content.
+func statementID(statement validator.Statement) string {
+	if statement.Sid != "" {
+		return "Sid:" + statement.Sid
+	}
+	data, err := json.Marshal(statement)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// accountFindings reports the accounts the statements refer to that the configuration does not allow.
+func accountFindings(document validator.PolicyDocument, prefix string, cfg *config.Config) []Finding {
+	var findings []Finding
+	for i, statement := range document.Statement {
+		for _, reference := range validator.AccountReferences(statement) {
+			if cfg.AccountAllowed(reference.Account) {
+				continue
+			}
+			path := fmt.Sprintf("%sStatement[%d].%s", prefix, i, reference.Element)
+			if reference.Index >= 0 {
+				path += fmt.Sprintf("[%d]", reference.Index)
+			}
+			findings = append(findings, Finding{
+				Rule:     "unknownAccount",
+				Severity: lint.SeverityError,
+				Message:  fmt.Sprintf("%s: %s", reference.Account, validator.GetErrorMessage("unknownAccount")),
+				Path:     path,
+			})
+		}
+	}
+	return findings
+}
+
+// Failed reports whether the file could not be read or has a finding of error severity.
+func (f File) Failed() bool {
+	return f.FailsOn(lint.SeverityError)
+}
+
+// severityRanks orders the severities, from the least to the most severe.
+var severityRanks = map[lint.Severity]int{lint.SeverityInfo: 1, lint.SeverityWarning: 2, lint.SeverityError: 3}
+
+// FailsOn reports whether the file could not be read or has a finding of the given severity or a more severe one.
+func (f File) FailsOn(severity lint.Severity) bool {
+	if f.Err != nil {
+		return true
+	}
+	for _, finding := range f.Findings {
+		if severityRanks[finding.Severity] >= severityRanks[severity] {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize counts the files, those that failed and their findings by severity.
+func Summarize(files []File) Summary {
+	summary := Summary{Files: len(files)}
+	for _, file := range files {
+		if file.Failed() {
+			summary.Failed++
+		}
+		for _, finding := range file.Findings {
+			switch finding.Severity {
+			case lint.SeverityError:
+				summary.Errors++
+			case lint.SeverityWarning:
+				summary.Warnings++
+			default:
+				summary.Infos++
+			}
+		}
+	}
+	return summary
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d files, %d failed: %d errors, %d warnings, %d infos", s.Files, s.Failed, s.Errors, s.Warnings, s.Infos)
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s [%s]", f.Message, f.Rule)
+	}
+	return fmt.Sprintf("%d:%d: %s [%s]", f.Line, f.Column, f.Message, f.Rule)
+}
+
+func decodingFinding(err error) Finding {
+	finding := Finding{Rule: RuleInvalidFormat, Severity: lint.SeverityError, Message: err.Error()}
+	var positionErr *validator.PositionError
+	if errors.As(err, &positionErr) {
+		finding.Message = positionErr.Err.Error()
+		finding.Line, finding.Column = positionErr.Line, positionErr.Column
+	}
+	if key := validator.ErrorKey(err); key != "" {
+		finding.Rule = key
+	}
+	return finding
+}
+
+// validate runs the validator on each part of the policy, as ValidateIAMPolicy does but without stopping
+// at the first invalid statement.
+func validate(policy validator.IAMPolicy) []Finding {
+	var findings []Finding
+	if _, err := validator.ValidatePolicyName(policy.PolicyName); err != nil {
+		findings = append(findings, validationFinding("PolicyName", err))
+	}
+	return append(findings, validateDocument(policy.PolicyDocument, "PolicyDocument.", validator.ValidateStatement)...)
+}
+
+// validateDocument validates the version and each statement of a document whose elements are under prefix.
+func validateDocument(document validator.PolicyDocument, prefix string, validateStatement func(validator.Statement) (bool, error)) []Finding {
+	var findings []Finding
+	if _, err := validator.ValidatePolicyDocument(document); err != nil {
+		findings = append(findings, validationFinding(prefix+"Version", err))
+	}
+	if len(document.Statement) == 0 {
+		findings = append(findings, validationFinding(prefix+"Statement", fmt.Errorf(validator.GetErrorMessage("emptyStatement"))))
+	}
+	for i, statement := range document.Statement {
+		if _, err := validateStatement(statement); err != nil {
+			path := fmt.Sprintf("%sStatement[%d]", prefix, i)
+			findings = append(findings, validationFinding(statementElement(path, statement, validator.ErrorKey(err)), err))
+		}
+	}
+	return findings
+}
+
+func validationFinding(path string, err error) Finding {
+	rule := validator.ErrorKey(err)
+	if rule == "" {
+		rule = RuleInvalidFormat
+	}
+	return Finding{Rule: rule, Severity: lint.SeverityError, Message: err.Error(), Path: path}
+}
+
+// statementElement returns the path of the element of the statement at path a validation error is about.
+func statementElement(path string, statement validator.Statement, key string) string {
+	switch {
+	case key == "resourcePolicyPrincipal":
+		return path
+	case key == "trustPolicyNotPrincipal":
+		return path + ".NotPrincipal"
+	case key == "trustPolicyPrincipal":
+		if statement.Principal == nil {
+			return path
+		}
+		return path + ".Principal"
+	case strings.Contains(key, "Effect"):
+		return path + ".Effect"
+	case key == "bothActions":
+		return path + ".NotAction"
+	case key == "bothResource":
+		return path + ".NotResource"
+	case strings.Contains(key, "Action"):
+		if statement.Action == nil {
+			return path + ".NotAction"
+		}
+		return path + ".Action"
+	case key == "wildcardResource":
+		element, values := "Resource", statement.Resource
+		if values == nil {
+			element, values = "NotResource", statement.NotResource
+		}
+		for i, value := range validator.StringValues(values) {
+			if value == "*" {
+				return fmt.Sprintf("%s.%s[%d]", path, element, i)
+			}
+		}
+		return path + "." + element
+	case strings.Contains(key, "Resource"):
+		if statement.Resource == nil {
+			return path + ".NotResource"
+		}
+		return path + ".Resource"
+	}
+	return path
+}
+
+// locate sets the position of a finding from its path, or from its closest enclosing element found in the file,
+// the whole policy when the element is missing, e.g. an empty PolicyName.
+func locate(source *validator.Source, finding Finding) Finding {
+	path := finding.Path
+	node := source.Root.Lookup(path)
+	for node == nil && path != "" {
+		i := strings.LastIndexAny(path, ".[")
+		if i == -1 {
+			i = 0
+		}
+		path = path[:i]
+		node = source.Root.Lookup(path)
+	}
+	if node != nil {
+		finding.Line, finding.Column = source.Position(node.Start)
+	}
+	return finding
+}
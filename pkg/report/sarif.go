@@ -0,0 +1,227 @@
+package report
+
+/*
+This file writes findings as a SARIF 2.1.0 log, the format code scanning dashboards import.
+Every message of the error catalog is described as a rule, with its lint severity as default level, and each finding
+becomes a result pointing at its rule, file, line and column.
+
+Results carry a fingerprint computed from the rule, the file, the policy element and the message, but not from
+the line: the same finding is recognised across runs even when lines are added above it. Nor is it computed from
+the index of the statement, which is replaced with the Sid or content of the statement, and statement numbers are
+left out of the message, so that inserting or reordering statements does not change the fingerprints either.
+
+More information about SARIF can be found here:
+ - https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+ - https://docs.github.com/en/code-security/code-scanning/integrating-with-code-scanning/sarif-support-for-code-scanning
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "aws-iam-policy-verifier"
+	toolURI      = "https://github.com/kcbojanowski/aws-iam-policy-verifier"
+	// fingerprintKey names the fingerprint of the results, versioned in case its computation changes.
+	fingerprintKey = "policyFindingHash/v2"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	ColumnKind  string            `json:"columnKind"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifResult struct {
+	RuleID string `json:"ruleId"`
+	// RuleIndex is left out for the rules that are not described, such as invalidFormat.
+	RuleIndex           *int              `json:"ruleIndex,omitempty"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes the findings of the files as a SARIF log with a single run.
+func WriteSARIF(w io.Writer, files []File) error {
+	rules, ruleIndex := sarifRules()
+	run := sarifRun{
+		Tool:        sarifTool{Driver: sarifDriver{Name: toolName, InformationURI: toolURI, Rules: rules}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		ColumnKind:  "unicodeCodePoints",
+		Results:     []sarifResult{},
+	}
+
+	for _, file := range files {
+		uri := artifactURI(file.Path)
+		if file.Err != nil {
+			invocation := &run.Invocations[0]
+			invocation.ExecutionSuccessful = false
+			invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: file.Err.Error()},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}}},
+			})
+			continue
+		}
+
+		for _, finding := range file.Findings {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}}
+			if finding.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line, StartColumn: finding.Column}
+			}
+			if finding.Path != "" {
+				location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: finding.Path, Kind: "element"}}
+			}
+			result := sarifResult{
+				RuleID:              finding.Rule,
+				Level:               sarifLevel(finding.Severity),
+				Message:             sarifMessage{Text: finding.Message},
+				Locations:           []sarifLocation{location},
+				PartialFingerprints: map[string]string{fingerprintKey: Fingerprint(uri, finding)},
+			}
+			if index, ok := ruleIndex[finding.Rule]; ok {
+				result.RuleIndex = &index
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// statementNumber matches the numbers of statements in messages, e.g. "statement 2".
+var statementNumber = regexp.MustCompile(`(?i)\b(statement) \d+`)
+
+// Fingerprint identifies a finding independently of its line and of the position of its statement, from its rule,
+// file, element and message.
+func Fingerprint(path string, finding Finding) string {
+	element := finding.Path
+	if finding.Statement != "" {
+		if start := strings.Index(element, "Statement["); start != -1 {
+			if end := strings.Index(element[start:], "]"); end != -1 {
+				element = element[:start] + "Statement{" + finding.Statement + "}" + element[start+end+1:]
+			}
+		}
+	}
+	message := statementNumber.ReplaceAllString(finding.Message, "$1")
+	sum := sha256.Sum256([]byte(strings.Join([]string{finding.Rule, artifactURI(path), element, message}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// sarifRules describes every message of the error catalog as a rule, and indexes them by ID.
+func sarifRules() ([]sarifRule, map[string]int) {
+	severities := map[string]lint.Severity{}
+	for _, rule := range lint.Rules() {
+		severities[rule.ID] = rule.Severity
+	}
+
+	var rules []sarifRule
+	index := map[string]int{}
+	for _, key := range validator.ErrorKeys() {
+		severity, ok := severities[key]
+		if !ok {
+			severity = lint.SeverityError
+		}
+		index[key] = len(rules)
+		rules = append(rules, sarifRule{
+			ID:                   key,
+			ShortDescription:     sarifMessage{Text: validator.GetErrorMessage(key)},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(severity)},
+		})
+	}
+	return rules, index
+}
+
+func sarifLevel(severity lint.Severity) string {
+	switch severity {
+	case lint.SeverityError:
+		return "error"
+	case lint.SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// artifactURI converts a path into the relative URI form dashboards resolve against the repository root.
+func artifactURI(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+}
@@ -0,0 +1,37 @@
+package report
+
+/*
+This file checks the policies of CloudFormation templates, so that a template can be checked next to policy files.
+A JSON or YAML file with a Resources object is a template; the policies of its resources are validated by the cfn
+package and each invalid resource gives a finding at its logical ID, spanning the lines of its definition. The cfn package stops at the first
+error of a resource, so a resource has at most one finding, and the lint rules do not run on templates.
+*/
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cfn"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+)
+
+func checkTemplate(template *cfn.Template) []Finding {
+	var findings []Finding
+	for _, result := range template.Validate() {
+		if result.Err == nil {
+			continue
+		}
+		rule := validator.ErrorKey(result.Err)
+		if rule == "" {
+			rule = RuleInvalidFormat
+		}
+		findings = append(findings, Finding{
+			Rule:     rule,
+			Severity: lint.SeverityError,
+			Message:  result.Err.Error(),
+			Path:     "Resources." + result.LogicalID,
+			Line:     result.Line,
+			Column:   result.Column,
+			EndLine:  result.EndLine,
+		})
+	}
+	return findings
+}
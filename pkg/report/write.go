@@ -0,0 +1,64 @@
+package report
+
+/*
+This file writes the findings of checked files in the output format chosen on the command line:
+ - text, for people, with the ✅/❌ marks of the rest of the CLI,
+ - json, a single document with every file and finding, and ndjson, one finding per line, for scripts and dashboards,
+ - junit, where each file is a test case and each finding a failure, for CI systems,
+ - sarif, for code scanning dashboards (see sarif.go).
+*/
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatJUnit  = "junit"
+	FormatSARIF  = "sarif"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatText, FormatJSON, FormatNDJSON, FormatJUnit, FormatSARIF}
+
+// Write writes the findings of the files in the given format.
+func Write(w io.Writer, format string, files []File) error {
+	switch format {
+	case FormatText:
+		return WriteText(w, files)
+	case FormatJSON:
+		return WriteJSON(w, files)
+	case FormatNDJSON:
+		return WriteNDJSON(w, files)
+	case FormatJUnit:
+		return WriteJUnit(w, files)
+	case FormatSARIF:
+		return WriteSARIF(w, files)
+	}
+	return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+func WriteText(w io.Writer, files []File) error {
+	for _, file := range files {
+		if _, err := fmt.Fprintf(w, "\n--- Validating %s:\n", file.Path); err != nil {
+			return err
+		}
+		if file.Err != nil {
+			fmt.Fprintf(w, "Error reading %s: %s ❌\n", file.Path, file.Err)
+			continue
+		}
+		for _, finding := range file.Findings {
+			fmt.Fprintf(w, "%-7s %s\n", finding.Severity, finding)
+		}
+		if file.Failed() {
+			fmt.Fprintf(w, "Validation failed for %s ❌\n", file.Path)
+		} else {
+			fmt.Fprintln(w, "Policy validation passed ✅")
+		}
+	}
+	return nil
+}
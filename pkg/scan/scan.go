@@ -0,0 +1,144 @@
+package scan
+
+/*
+This file finds the policy files under a set of paths and checks them on a bounded pool of workers.
+Directories are walked recursively; their JSON and YAML files are checked unless excluded, or unless include globs
+are given and none matches. Files given explicitly are always checked. A path that cannot be read is reported
+as a file in error and the walk goes on.
+
+Results are returned in the order the files are found, paths in the given order and the files of a directory
+in lexical order, whatever the order in which the workers finish. Configuration and baseline files are not policies and are skipped,
+by their default names and by the paths of Options.Skip.
+
+Globs are matched against slash-separated paths relative to the walked directory (see the glob package).
+*/
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/baseline"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/glob"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	Include []string
+	Exclude []string
+	// Workers is the number of files checked at once, the number of CPUs when 0.
+	Workers int
+	// Checker checks each file, with the settings of its configuration.
+	Checker report.Checker
+	// Skip lists files found in directories that are not policies, such as a baseline file with another name.
+	Skip []string
+}
+
+type Result struct {
+	Files    []report.File
+	Summary  report.Summary
+	Duration time.Duration
+}
+
+// Scan checks the policy files under paths.
+func Scan(paths []string, options Options) Result {
+	start := time.Now()
+	files := Find(paths, options)
+	check(files, options)
+	return Result{Files: files, Summary: report.Summarize(files), Duration: time.Since(start)}
+}
+
+// check checks the files that are not in error on a pool of workers, in place.
+func check(files []report.File, options Options) {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				if files[index].Err == nil {
+					files[index] = options.Checker.CheckFile(files[index].Path)
+				}
+			}
+		}()
+	}
+	for index := range files {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Find lists the policy files under paths without checking them, with the paths that could not be read in error.
+func Find(paths []string, options Options) []report.File {
+	var files []report.File
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			files = append(files, report.File{Path: root, Err: err})
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, report.File{Path: root})
+			continue
+		}
+
+		filepath.WalkDir(root, func(current string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				files = append(files, report.File{Path: current, Err: err})
+				if entry != nil && entry.IsDir() && current != root {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			relative, _ := filepath.Rel(root, current)
+			relative = filepath.ToSlash(relative)
+			if current != root && glob.MatchAny(options.Exclude, relative) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() || !IsPolicyFile(entry.Name()) || entry.Name() == config.FileName || entry.Name() == baseline.DefaultPath || options.Skipped(current) {
+				return nil
+			}
+			if len(options.Include) == 0 || glob.MatchAny(options.Include, relative) {
+				files = append(files, report.File{Path: current})
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// Skipped reports whether path is one of the files of Skip.
+func (o Options) Skipped(path string) bool {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, skip := range o.Skip {
+		if absoluteSkip, err := filepath.Abs(skip); err == nil && absoluteSkip == absolutePath {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPolicyFile reports whether a file may hold a policy, written in JSON or YAML.
+func IsPolicyFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
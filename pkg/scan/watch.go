@@ -0,0 +1,89 @@
+package scan
+
+/*
+This file watches the policy files under a set of paths. Each poll finds the files again, so that new files are
+picked up, and re-checks only the files whose modification time or size changed since the previous poll.
+Polling needs no support from the operating system and is cheap next to checking a policy.
+*/
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"os"
+	"sort"
+	"time"
+)
+
+type Watcher struct {
+	paths   []string
+	options Options
+	states  map[string]fileState
+	results map[string]report.File
+	// order lists the files of the last poll in the order Find returns them.
+	order []string
+}
+
+type fileState struct {
+	modified time.Time
+	size     int64
+}
+
+func NewWatcher(paths []string, options Options) *Watcher {
+	return &Watcher{paths: paths, options: options, states: map[string]fileState{}, results: map[string]report.File{}}
+}
+
+// Poll re-checks the files that were added or changed since the previous poll, the first poll checking them all,
+// and returns the paths of the files that were added, changed or removed.
+func (w *Watcher) Poll() []string {
+	var changed []string
+	var stale []report.File
+	found := Find(w.paths, w.options)
+	seen := map[string]bool{}
+	w.order = w.order[:0]
+	for _, file := range found {
+		seen[file.Path] = true
+		w.order = append(w.order, file.Path)
+		if file.Err != nil {
+			if previous, ok := w.results[file.Path]; !ok || previous.Err == nil {
+				changed = append(changed, file.Path)
+			}
+			w.results[file.Path] = file
+			delete(w.states, file.Path)
+			continue
+		}
+
+		state := fileState{}
+		if info, err := os.Stat(file.Path); err == nil {
+			state = fileState{modified: info.ModTime(), size: info.Size()}
+		}
+		if previous, ok := w.states[file.Path]; ok && previous == state {
+			continue
+		}
+		w.states[file.Path] = state
+		stale = append(stale, file)
+		changed = append(changed, file.Path)
+	}
+
+	check(stale, w.options)
+	for _, file := range stale {
+		w.results[file.Path] = file
+	}
+	var removed []string
+	for path := range w.results {
+		if !seen[path] {
+			delete(w.results, path)
+			delete(w.states, path)
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	return append(changed, removed...)
+}
+
+// Files returns the results of the last poll, in a stable order.
+func (w *Watcher) Files() []report.File {
+	files := make([]report.File, 0, len(w.order))
+	for _, path := range w.order {
+		files = append(files, w.results[path])
+	}
+	return files
+}
@@ -0,0 +1,215 @@
+package simulator
+
+/*
+This file evaluates the Condition block of a statement against the request context.
+Every operator in the block and every key under an operator must evaluate to true for the statement to apply.
+
+Supported operators are the String, Numeric, Date, Bool, IpAddress, Arn and Null families,
+including the IfExists suffix and the ForAnyValue / ForAllValues set prefixes.
+More information about condition operators can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_elements_condition_operators.html
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type comparator struct {
+	match   func(contextValue, policyValue string) bool
+	negated bool
+}
+
+var comparators = map[string]comparator{
+	"StringEquals":              {match: stringEquals},
+	"StringNotEquals":           {match: stringEquals, negated: true},
+	"StringEqualsIgnoreCase":    {match: strings.EqualFold},
+	"StringNotEqualsIgnoreCase": {match: strings.EqualFold, negated: true},
+	"StringLike":                {match: stringLike},
+	"StringNotLike":             {match: stringLike, negated: true},
+
+	"NumericEquals":            {match: numericCompare(func(a, b float64) bool { return a == b })},
+	"NumericNotEquals":         {match: numericCompare(func(a, b float64) bool { return a == b }), negated: true},
+	"NumericLessThan":          {match: numericCompare(func(a, b float64) bool { return a < b })},
+	"NumericLessThanEquals":    {match: numericCompare(func(a, b float64) bool { return a <= b })},
+	"NumericGreaterThan":       {match: numericCompare(func(a, b float64) bool { return a > b })},
+	"NumericGreaterThanEquals": {match: numericCompare(func(a, b float64) bool { return a >= b })},
+
+	"DateEquals":            {match: dateCompare(func(a, b time.Time) bool { return a.Equal(b) })},
+	"DateNotEquals":         {match: dateCompare(func(a, b time.Time) bool { return a.Equal(b) }), negated: true},
+	"DateLessThan":          {match: dateCompare(func(a, b time.Time) bool { return a.Before(b) })},
+	"DateLessThanEquals":    {match: dateCompare(func(a, b time.Time) bool { return !a.After(b) })},
+	"DateGreaterThan":       {match: dateCompare(func(a, b time.Time) bool { return a.After(b) })},
+	"DateGreaterThanEquals": {match: dateCompare(func(a, b time.Time) bool { return !a.Before(b) })},
+
+	"Bool": {match: strings.EqualFold},
+
+	"IpAddress":    {match: ipAddress},
+	"NotIpAddress": {match: ipAddress, negated: true},
+
+	"ArnEquals":    {match: stringLike},
+	"ArnLike":      {match: stringLike},
+	"ArnNotEquals": {match: stringLike, negated: true},
+	"ArnNotLike":   {match: stringLike, negated: true},
+}
+
+// Operators returns the names of the supported condition operators, without their IfExists suffix and set prefixes,
+// in alphabetical order.
+func Operators() []string {
+	operators := []string{"Null"}
+	for name := range comparators {
+		operators = append(operators, name)
+	}
+	sort.Strings(operators)
+	return operators
+}
+
+// EvaluateConditions reports whether all conditions are satisfied by the request context.
+func EvaluateConditions(conditions map[string]validator.ConditionMap, context map[string][]string) (bool, error) {
+	for operator, keys := range conditions {
+		for key, values := range keys {
+			ok, err := evaluateCondition(operator, key, values, context)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func evaluateCondition(operator, key string, policyValues []string, context map[string][]string) (bool, error) {
+	setOperator, base, ifExists := splitOperator(operator)
+	contextValues, present := lookupContext(context, key)
+
+	if base == "Null" {
+		for _, value := range policyValues {
+			if strings.EqualFold(value, "true") != present {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	cmp, ok := comparators[base]
+	if !ok {
+		return false, fmt.Errorf("unsupported condition operator %q", operator)
+	}
+
+	if !present {
+		return ifExists || cmp.negated || setOperator == "ForAllValues", nil
+	}
+
+	matchesPolicy := func(contextValue string) bool {
+		for _, policyValue := range policyValues {
+			if cmp.match(contextValue, policyValue) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch setOperator {
+	case "ForAllValues":
+		for _, value := range contextValues {
+			if matchesPolicy(value) == cmp.negated {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "ForAnyValue":
+		for _, value := range contextValues {
+			if matchesPolicy(value) != cmp.negated {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	for _, value := range contextValues {
+		if matchesPolicy(value) {
+			return !cmp.negated, nil
+		}
+	}
+	return cmp.negated, nil
+}
+
+func splitOperator(operator string) (setOperator, base string, ifExists bool) {
+	base = operator
+	if i := strings.Index(base, ":"); i != -1 {
+		setOperator, base = base[:i], base[i+1:]
+	}
+	if strings.HasSuffix(base, "IfExists") {
+		base, ifExists = strings.TrimSuffix(base, "IfExists"), true
+	}
+	return setOperator, base, ifExists
+}
+
+// lookupContext finds a context key ignoring case, as AWS treats condition key names case-insensitively.
+func lookupContext(context map[string][]string, key string) ([]string, bool) {
+	if values, ok := context[key]; ok {
+		return values, true
+	}
+	for name, values := range context {
+		if strings.EqualFold(name, key) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+func stringEquals(contextValue, policyValue string) bool {
+	return contextValue == policyValue
+}
+
+func stringLike(contextValue, policyValue string) bool {
+	return MatchPattern(policyValue, contextValue)
+}
+
+func numericCompare(cmp func(a, b float64) bool) func(string, string) bool {
+	return func(contextValue, policyValue string) bool {
+		a, errA := strconv.ParseFloat(contextValue, 64)
+		b, errB := strconv.ParseFloat(policyValue, 64)
+		return errA == nil && errB == nil && cmp(a, b)
+	}
+}
+
+func dateCompare(cmp func(a, b time.Time) bool) func(string, string) bool {
+	return func(contextValue, policyValue string) bool {
+		a, okA := parseDate(contextValue)
+		b, okB := parseDate(policyValue)
+		return okA && okB && cmp(a, b)
+	}
+}
+
+func parseDate(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+func ipAddress(contextValue, policyValue string) bool {
+	ip := net.ParseIP(contextValue)
+	if ip == nil {
+		return false
+	}
+	if !strings.Contains(policyValue, "/") {
+		return ip.Equal(net.ParseIP(policyValue))
+	}
+	_, network, err := net.ParseCIDR(policyValue)
+	return err == nil && network.Contains(ip)
+}
@@ -0,0 +1,111 @@
+package simulator
+
+/*
+This file implements the wildcard patterns used by the Action, Resource and Principal elements
+and by the StringLike / ArnLike condition operators. A '*' matches any sequence of characters
+and a '?' matches exactly one character.
+
+Besides matching a concrete value, patterns can be compared with each other:
+PatternCovers tells whether one pattern matches everything another one does,
+and PatternsOverlap tells whether two patterns can match a common value.
+*/
+
+import (
+	"strings"
+)
+
+// MatchAction compares an action pattern with an action name. Action names are case-insensitive.
+func MatchAction(pattern, action string) bool {
+	return MatchPattern(strings.ToLower(pattern), strings.ToLower(action))
+}
+
+// MatchPattern matches value against a pattern in which '*' matches any sequence of characters
+// and '?' matches exactly one character.
+func MatchPattern(pattern, value string) bool {
+	p, v := 0, 0
+	star, mark := -1, 0
+
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, v
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case star != -1:
+			p = star + 1
+			mark++
+			v = mark
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// PatternCovers reports whether every value matched by inner is also matched by outer.
+func PatternCovers(outer, inner string) bool {
+	memo := make(map[[2]int]bool)
+	var covers func(i, j int) bool
+	covers = func(i, j int) bool {
+		key := [2]int{i, j}
+		if result, ok := memo[key]; ok {
+			return result
+		}
+
+		var result bool
+		switch {
+		case i == len(outer):
+			result = j == len(inner)
+		case outer[i] == '*':
+			result = covers(i+1, j) || (j < len(inner) && covers(i, j+1))
+		case j == len(inner) || inner[j] == '*':
+			result = false
+		case outer[i] == '?':
+			result = covers(i+1, j+1)
+		case inner[j] == '?':
+			result = false
+		default:
+			result = outer[i] == inner[j] && covers(i+1, j+1)
+		}
+
+		memo[key] = result
+		return result
+	}
+	return covers(0, 0)
+}
+
+// PatternsOverlap reports whether some value is matched by both patterns.
+func PatternsOverlap(a, b string) bool {
+	memo := make(map[[2]int]bool)
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		key := [2]int{i, j}
+		if result, ok := memo[key]; ok {
+			return result
+		}
+
+		var result bool
+		switch {
+		case i == len(a) && j == len(b):
+			result = true
+		case i < len(a) && a[i] == '*':
+			result = overlap(i+1, j) || (j < len(b) && overlap(i, j+1))
+		case j < len(b) && b[j] == '*':
+			result = overlap(i, j+1) || (i < len(a) && overlap(i+1, j))
+		case i == len(a) || j == len(b):
+			result = false
+		default:
+			result = (a[i] == '?' || b[j] == '?' || a[i] == b[j]) && overlap(i+1, j+1)
+		}
+
+		memo[key] = result
+		return result
+	}
+	return overlap(0, 0)
+}
@@ -0,0 +1,177 @@
+package simulator
+
+/*
+This file provides a local approximation of the AWS policy evaluation logic.
+Evaluate takes a set of IAM policies and a single request and decides whether the request is allowed.
+
+The evaluation follows the documented order:
+ - an explicit Deny in any statement always wins,
+ - otherwise an Allow in any statement allows the request,
+ - otherwise the request is implicitly denied.
+
+More information about the evaluation logic can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_evaluation-logic.html
+*/
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"strings"
+)
+
+type Decision string
+
+const (
+	Allowed      Decision = "Allowed"
+	ExplicitDeny Decision = "ExplicitDeny"
+	ImplicitDeny Decision = "ImplicitDeny"
+)
+
+type Request struct {
+	Principal string
+	Action    string
+	Resource  string
+	Context   map[string][]string
+}
+
+// StatementRef identifies a statement inside one of the evaluated policies.
+type StatementRef struct {
+	PolicyName string
+	Index      int
+	Sid        string
+}
+
+type Result struct {
+	Decision  Decision
+	Matched   []StatementRef
+	Errors    []error
+	Statement *StatementRef
+}
+
+func Evaluate(policies []validator.IAMPolicy, request Request) Result {
+	result := Result{Decision: ImplicitDeny}
+
+	for _, policy := range policies {
+		for i, statement := range policy.PolicyDocument.Statement {
+			matched, err := StatementMatches(statement, request)
+			if err != nil {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+			if !matched {
+				continue
+			}
+
+			ref := StatementRef{PolicyName: policy.PolicyName, Index: i, Sid: statement.Sid}
+			result.Matched = append(result.Matched, ref)
+
+			switch statement.Effect {
+			case "Deny":
+				if result.Decision != ExplicitDeny {
+					result.Decision = ExplicitDeny
+					result.Statement = &ref
+				}
+			case "Allow":
+				if result.Decision == ImplicitDeny {
+					result.Decision = Allowed
+					result.Statement = &ref
+				}
+			}
+		}
+	}
+	return result
+}
+
+// StatementMatches reports whether the statement applies to the request, regardless of its Effect.
+func StatementMatches(statement validator.Statement, request Request) (bool, error) {
+	if !matchesElement(statement.Action, statement.NotAction, request.Action, MatchAction) {
+		return false, nil
+	}
+
+	matchResource := func(pattern, value string) bool {
+		return MatchPattern(substituteVariables(pattern, request.Context), value)
+	}
+	if !matchesElement(statement.Resource, statement.NotResource, request.Resource, matchResource) {
+		return false, nil
+	}
+
+	if request.Principal != "" && !matchesPrincipal(statement, request.Principal) {
+		return false, nil
+	}
+
+	return EvaluateConditions(statement.Conditions, request.Context)
+}
+
+func matchesElement(element, notElement interface{}, value string, match func(pattern, value string) bool) bool {
+	switch {
+	case element != nil:
+		return matchesAny(validator.StringValues(element), value, match)
+	case notElement != nil:
+		return !matchesAny(validator.StringValues(notElement), value, match)
+	}
+	return true
+}
+
+func matchesAny(patterns []string, value string, match func(pattern, value string) bool) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPrincipal(statement validator.Statement, principal string) bool {
+	switch {
+	case statement.Principal != nil:
+		return principalBlockMatches(statement.Principal, principal)
+	case statement.NotPrincipal != nil:
+		return !principalBlockMatches(statement.NotPrincipal, principal)
+	}
+	return true
+}
+
+func principalBlockMatches(block *validator.PrincipalBlock, principal string) bool {
+	for _, element := range []interface{}{block.AWS, block.Federated, block.Service, block.CanonicalUser} {
+		if matchesAny(validator.StringValues(element), principal, MatchPattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteVariables replaces policy variables such as ${aws:username} with values from the request context.
+// Variables missing from the context are left untouched, so they never match a concrete resource.
+func substituteVariables(pattern string, context map[string][]string) string {
+	if !strings.Contains(pattern, "${") {
+		return pattern
+	}
+
+	var sb strings.Builder
+	for {
+		start := strings.Index(pattern, "${")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(pattern[start:], "}")
+		if end == -1 {
+			break
+		}
+		end += start
+
+		sb.WriteString(pattern[:start])
+		name := pattern[start+2 : end]
+		switch name {
+		case "*", "?", "$":
+			sb.WriteString(name)
+		default:
+			if values := context[name]; len(values) > 0 {
+				sb.WriteString(values[0])
+			} else {
+				sb.WriteString(pattern[start : end+1])
+			}
+		}
+		pattern = pattern[end+1:]
+	}
+	sb.WriteString(pattern)
+	return sb.String()
+}
@@ -0,0 +1,174 @@
+package terraform
+
+/*
+This file reads Terraform plans in their JSON form, as printed by `terraform show -json plan.out`,
+and validates the IAM policies of the resources they plan:
+ - aws_iam_policy, aws_iam_role_policy, aws_iam_user_policy and aws_iam_group_policy as identity policies,
+ - aws_iam_role: its assume_role_policy as a trust policy, its inline_policy blocks as identity policies,
+   and its name, path, permissions_boundary, managed_policy_arns and max_session_duration,
+ - aws_s3_bucket_policy, aws_sqs_queue_policy, aws_sns_topic_policy and aws_kms_key as resource-based policies,
+ - data.aws_iam_policy_document, whose rendered json is validated according to what it contains.
+
+Resources are read from the planned values, and data sources from the prior state where Terraform records the ones
+it read while planning, including those of child modules. Values only known after apply are absent from the plan:
+the resources they belong to are reported as skipped instead of invalid.
+
+More information about the JSON output format can be found here:
+ - https://developer.hashicorp.com/terraform/internals/json-format
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+type Plan struct {
+	Path string
+	// Resources are ordered by address.
+	Resources []Resource
+}
+
+type Resource struct {
+	Address string                 `json:"address"`
+	Mode    string                 `json:"mode"`
+	Type    string                 `json:"type"`
+	Name    string                 `json:"name"`
+	Values  map[string]interface{} `json:"values"`
+	// Unknown lists the attributes whose value is only known after apply.
+	Unknown map[string]bool `json:"-"`
+}
+
+type module struct {
+	Address      string     `json:"address"`
+	Resources    []Resource `json:"resources"`
+	ChildModules []module   `json:"child_modules"`
+}
+
+type planJSON struct {
+	FormatVersion string `json:"format_version"`
+	PlannedValues struct {
+		RootModule module `json:"root_module"`
+	} `json:"planned_values"`
+	PriorState struct {
+		Values struct {
+			RootModule module `json:"root_module"`
+		} `json:"values"`
+	} `json:"prior_state"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			AfterUnknown map[string]interface{} `json:"after_unknown"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+func LoadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := ParsePlan(data)
+	if err != nil {
+		return nil, err
+	}
+	plan.Path = path
+	return plan, nil
+}
+
+func ParsePlan(data []byte) (*Plan, error) {
+	var raw planJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw.FormatVersion == "" {
+		return nil, fmt.Errorf("not a Terraform JSON plan: missing format_version, use terraform show -json")
+	}
+
+	unknown := map[string]map[string]bool{}
+	for _, change := range raw.ResourceChanges {
+		for attribute, value := range change.Change.AfterUnknown {
+			if value == true {
+				if unknown[change.Address] == nil {
+					unknown[change.Address] = map[string]bool{}
+				}
+				unknown[change.Address][attribute] = true
+			}
+		}
+	}
+
+	plan := &Plan{}
+	seen := map[string]bool{}
+	add := func(resource Resource) {
+		if seen[resource.Address] {
+			return
+		}
+		seen[resource.Address] = true
+		resource.Unknown = unknown[resource.Address]
+		plan.Resources = append(plan.Resources, resource)
+	}
+	for _, resource := range moduleResources(raw.PlannedValues.RootModule) {
+		add(resource)
+	}
+	for _, resource := range moduleResources(raw.PriorState.Values.RootModule) {
+		if resource.Mode == "data" {
+			add(resource)
+		}
+	}
+	// Data sources read during apply only appear in the resource changes.
+	for _, change := range raw.ResourceChanges {
+		if !seen[change.Address] && unknown[change.Address] != nil {
+			resource := Resource{Address: change.Address}
+			resource.Mode, resource.Type, resource.Name = parseAddress(change.Address)
+			add(resource)
+		}
+	}
+
+	sort.Slice(plan.Resources, func(i, j int) bool { return plan.Resources[i].Address < plan.Resources[j].Address })
+	return plan, nil
+}
+
+func moduleResources(m module) []Resource {
+	resources := append([]Resource{}, m.Resources...)
+	for _, child := range m.ChildModules {
+		resources = append(resources, moduleResources(child)...)
+	}
+	return resources
+}
+
+// parseAddress splits a resource address such as module.ci.data.aws_iam_policy_document.build["x"]
+// into its mode, type and name.
+func parseAddress(address string) (mode, resourceType, name string) {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range address {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '.' && depth == 0:
+			parts = append(parts, address[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, address[start:])
+
+	for len(parts) >= 2 && parts[0] == "module" {
+		parts = parts[2:]
+	}
+	mode = "managed"
+	if len(parts) > 0 && parts[0] == "data" {
+		mode, parts = "data", parts[1:]
+	}
+	if len(parts) != 2 {
+		return mode, "", ""
+	}
+	name = parts[1]
+	if i := strings.Index(name, "["); i != -1 {
+		name = name[:i]
+	}
+	return mode, parts[0], name
+}
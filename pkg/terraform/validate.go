@@ -0,0 +1,215 @@
+package terraform
+
+/*
+This file validates the policies of the resources of a plan. Policy attributes hold JSON strings, as rendered by
+jsonencode or data.aws_iam_policy_document, which are decoded into the validator's models.
+Errors are prefixed with the attribute they were found in, e.g. "inline_policy[1].policy: Effect must be 'Allow' or 'Deny'".
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"strings"
+)
+
+// identityPolicies are the resource types whose policy attribute holds an identity policy.
+var identityPolicies = map[string]bool{
+	"aws_iam_policy":       true,
+	"aws_iam_role_policy":  true,
+	"aws_iam_user_policy":  true,
+	"aws_iam_group_policy": true,
+}
+
+// resourcePolicies are the resource types whose policy attribute holds a resource-based policy.
+var resourcePolicies = map[string]bool{
+	"aws_s3_bucket_policy": true,
+	"aws_sqs_queue_policy": true,
+	"aws_sns_topic_policy": true,
+	"aws_kms_key":          true,
+}
+
+type Result struct {
+	Address string
+	Type    string
+	// Skipped explains why the resource could not be validated, e.g. because its policy is only known after apply.
+	Skipped string
+	// Err is nil when every policy of the resource is valid.
+	Err error
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%s: %s", r.Address, r.Err)
+	case r.Skipped != "":
+		return fmt.Sprintf("%s skipped: %s", r.Address, r.Skipped)
+	}
+	return r.Address
+}
+
+// Supported reports whether the policies of a resource type are validated. Data sources are prefixed with "data.".
+func Supported(resourceType string) bool {
+	return identityPolicies[resourceType] || resourcePolicies[resourceType] ||
+		resourceType == "aws_iam_role" || resourceType == "data.aws_iam_policy_document"
+}
+
+// Validate validates the policies of every supported resource of the plan, ordered by address.
+func (p *Plan) Validate() []Result {
+	var results []Result
+	for _, resource := range p.Resources {
+		resourceType := resource.Type
+		if resource.Mode == "data" {
+			resourceType = "data." + resourceType
+		}
+		if !Supported(resourceType) {
+			continue
+		}
+		result := Result{Address: resource.Address, Type: resourceType}
+		result.Skipped, result.Err = validateResource(resource, resourceType)
+		results = append(results, result)
+	}
+	return results
+}
+
+func validateResource(resource Resource, resourceType string) (string, error) {
+	values := resource.Values
+	attribute := "policy"
+	switch resourceType {
+	case "aws_iam_role":
+		attribute = "assume_role_policy"
+	case "data.aws_iam_policy_document":
+		attribute = "json"
+	}
+	if resource.Unknown[attribute] || values == nil {
+		return attribute + " is only known after apply", nil
+	}
+	text, ok := values[attribute].(string)
+	if !ok || text == "" {
+		if resourceType == "aws_kms_key" {
+			// Keys without a policy get the default key policy.
+			return "", nil
+		}
+		return "", fmt.Errorf("%s is missing", attribute)
+	}
+
+	switch {
+	case resourceType == "aws_iam_role":
+		return "", validateRole(values)
+	case identityPolicies[resourceType]:
+		name := policyName(values, resource.Name)
+		if err := validateIdentityPolicy(name, text); err != nil {
+			return "", fmt.Errorf("policy: %w", err)
+		}
+	case resourcePolicies[resourceType]:
+		document, err := validator.DecodePolicyDocument([]byte(text))
+		if err == nil && resourceType == "aws_kms_key" {
+			_, err = validator.ValidateKeyPolicy(document)
+		} else if err == nil {
+			_, err = validator.ValidateResourcePolicy(document)
+		}
+		if err != nil {
+			return "", fmt.Errorf("policy: %w", err)
+		}
+	default:
+		if err := validateDocument(resource.Name, text); err != nil {
+			return "", fmt.Errorf("json: %w", err)
+		}
+	}
+	return "", nil
+}
+
+func validateRole(values map[string]interface{}) error {
+	document, err := validator.DecodePolicyDocument([]byte(values["assume_role_policy"].(string)))
+	if err == nil {
+		_, err = validator.ValidateTrustPolicy(document)
+	}
+	if err != nil {
+		return fmt.Errorf("assume_role_policy: %w", err)
+	}
+
+	if name, ok := values["name"].(string); ok && name != "" {
+		if _, err := validator.ValidateRoleName(name); err != nil {
+			return fmt.Errorf("name: %w", err)
+		}
+	}
+	if path, ok := values["path"].(string); ok && path != "" {
+		if _, err := validator.ValidateRolePath(path); err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+	}
+	if duration, ok := values["max_session_duration"].(float64); ok {
+		if _, err := validator.ValidateMaxSessionDuration(int(duration)); err != nil {
+			return fmt.Errorf("max_session_duration: %w", err)
+		}
+	}
+	if arns := validator.StringValues(values["managed_policy_arns"]); len(arns) > 0 {
+		if _, err := validator.ValidateManagedPolicyArns(arns); err != nil {
+			return fmt.Errorf("managed_policy_arns: %w", err)
+		}
+	}
+	if boundary, ok := values["permissions_boundary"].(string); ok && boundary != "" {
+		if _, err := validator.ValidatePermissionsBoundary(boundary); err != nil {
+			return fmt.Errorf("permissions_boundary: %w", err)
+		}
+	}
+
+	inline, _ := values["inline_policy"].([]interface{})
+	for i, block := range inline {
+		object, _ := block.(map[string]interface{})
+		policy, _ := object["policy"].(string)
+		if policy == "" {
+			// An empty inline_policy block removes the inline policies managed outside Terraform.
+			continue
+		}
+		if err := validateIdentityPolicy(policyName(object, "inline_policy"), policy); err != nil {
+			return fmt.Errorf("inline_policy[%d].policy: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateIdentityPolicy(name, text string) error {
+	document, err := validator.DecodePolicyDocument([]byte(text))
+	if err != nil {
+		return err
+	}
+	return validator.ValidateIAMPolicy(validator.IAMPolicy{PolicyName: name, PolicyDocument: document})
+}
+
+// validateDocument validates a policy document whose kind is not known from its resource type:
+// a trust policy when it only allows sts actions without resources, a resource-based policy when it names
+// principals, and an identity policy otherwise.
+func validateDocument(name, text string) error {
+	document, err := validator.DecodePolicyDocument([]byte(text))
+	if err != nil {
+		return err
+	}
+
+	trust, principals := len(document.Statement) > 0, false
+	for _, statement := range document.Statement {
+		principals = principals || statement.Principal != nil || statement.NotPrincipal != nil
+		if statement.Resource != nil || statement.NotResource != nil {
+			trust = false
+		}
+		for _, action := range validator.StringValues(statement.Action) {
+			trust = trust && strings.HasPrefix(strings.ToLower(action), "sts:")
+		}
+	}
+	switch {
+	case trust && principals:
+		_, err = validator.ValidateTrustPolicy(document)
+	case principals:
+		_, err = validator.ValidateResourcePolicy(document)
+	default:
+		err = validator.ValidateIAMPolicy(validator.IAMPolicy{PolicyName: name, PolicyDocument: document})
+	}
+	return err
+}
+
+// policyName returns the name of a policy, or the name of its resource when Terraform generates it.
+func policyName(values map[string]interface{}, resourceName string) string {
+	if name, ok := values["name"].(string); ok && name != "" {
+		return name
+	}
+	return resourceName
+}
@@ -0,0 +1,231 @@
+package usage
+
+/*
+This file compares the actions a policy allows with their usage, to find the permissions that are never used.
+Wildcard actions are expanded with the catalog, so that s3:Get* reports each s3:Get action on its own.
+The catalog does not list every action, so the used actions a pattern matches are added to its expansion.
+Patterns the catalog cannot expand, such as actions of unknown services, are reported as they are,
+and are used when any used action matches them.
+
+Trim suggests a policy keeping only the used actions of each Allow statement. Deny statements and
+statements using NotAction are kept as they are. A pattern whose usage is only known for its service, as with
+Access Advisor, is kept as it is: the actions it matches that the catalog does not list may have been used.
+*/
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Grant struct {
+	// Action is the full name of a catalog action, or the pattern of the policy when the catalog cannot expand it.
+	Action   string
+	Service  string
+	Used     bool
+	LastUsed time.Time
+	// ServiceLevel is true when only the usage of the service is known, not the usage of the action.
+	ServiceLevel bool
+}
+
+type ServiceReport struct {
+	Prefix   string
+	Used     bool
+	LastUsed time.Time
+	Granted  int
+	Unused   int
+}
+
+type Report struct {
+	// Since is the start of the window, usage before it does not count.
+	Since    time.Time
+	Grants   []Grant
+	Services []ServiceReport
+}
+
+// Analyze returns the usage since the given time of every action the policy allows. A zero time counts all usage.
+func Analyze(policy validator.IAMPolicy, usage Usage, since time.Time) Report {
+	report := Report{Since: since}
+	var usedActions []string
+	for _, name := range usage.Names {
+		usedActions = append(usedActions, name)
+	}
+	seen := map[string]bool{}
+	for _, statement := range policy.PolicyDocument.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		for _, pattern := range validator.StringValues(statement.Action) {
+			for _, action := range grantedActions(pattern, usedActions) {
+				if seen[strings.ToLower(action)] {
+					continue
+				}
+				seen[strings.ToLower(action)] = true
+				report.Grants = append(report.Grants, usage.grant(action, since))
+			}
+		}
+	}
+	sort.Slice(report.Grants, func(i, j int) bool { return report.Grants[i].Action < report.Grants[j].Action })
+
+	services := map[string]*ServiceReport{}
+	for _, grant := range report.Grants {
+		service, ok := services[grant.Service]
+		if !ok {
+			service = &ServiceReport{Prefix: grant.Service}
+			if lastUsed, found := usage.lastServiceUse(grant.Service); found && !lastUsed.Before(since) {
+				service.Used, service.LastUsed = true, lastUsed
+			}
+			services[grant.Service] = service
+		}
+		service.Granted++
+		if !grant.Used {
+			service.Unused++
+		}
+	}
+	for _, service := range services {
+		report.Services = append(report.Services, *service)
+	}
+	sort.Slice(report.Services, func(i, j int) bool { return report.Services[i].Prefix < report.Services[j].Prefix })
+	return report
+}
+
+// UnusedGrants returns the allowed actions that were not used in the window.
+func (r Report) UnusedGrants() []Grant {
+	var unused []Grant
+	for _, grant := range r.Grants {
+		if !grant.Used {
+			unused = append(unused, grant)
+		}
+	}
+	return unused
+}
+
+// UnusedServices returns the services none of whose allowed actions were used in the window.
+func (r Report) UnusedServices() []ServiceReport {
+	var unused []ServiceReport
+	for _, service := range r.Services {
+		if !service.Used {
+			unused = append(unused, service)
+		}
+	}
+	return unused
+}
+
+// Trim returns the policy without the actions the report found unused.
+// Statements left without any action are removed.
+func Trim(policy validator.IAMPolicy, report Report) (validator.IAMPolicy, error) {
+	used := map[string]bool{}
+	serviceLevel := map[string]bool{}
+	var usedActions []string
+	for _, grant := range report.Grants {
+		if grant.Used {
+			used[strings.ToLower(grant.Action)] = true
+			usedActions = append(usedActions, grant.Action)
+			serviceLevel[strings.ToLower(grant.Action)] = grant.ServiceLevel
+		}
+	}
+
+	var statements []validator.Statement
+	for _, statement := range policy.PolicyDocument.Statement {
+		if statement.Effect != "Allow" || statement.Action == nil {
+			statements = append(statements, statement)
+			continue
+		}
+		var kept []interface{}
+		for _, pattern := range validator.StringValues(statement.Action) {
+			actions := grantedActions(pattern, usedActions)
+			var usedGrants []interface{}
+			serviceLevelUse := false
+			for _, action := range actions {
+				if used[strings.ToLower(action)] {
+					usedGrants = append(usedGrants, action)
+					serviceLevelUse = serviceLevelUse || serviceLevel[strings.ToLower(action)]
+				}
+			}
+			if len(usedGrants) == len(actions) || (serviceLevelUse && strings.ContainsAny(pattern, "*?")) {
+				// Keep the pattern as it was written when everything it allows is, or may have been, used.
+				kept = append(kept, pattern)
+			} else {
+				kept = append(kept, usedGrants...)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		statement.Action = kept
+		statements = append(statements, statement)
+	}
+	if len(statements) == 0 {
+		return validator.IAMPolicy{}, fmt.Errorf("none of the allowed actions was used")
+	}
+
+	policy.PolicyDocument.Statement = statements
+	if err := validator.ValidateIAMPolicy(policy); err != nil {
+		return validator.IAMPolicy{}, fmt.Errorf("trimmed policy is not valid: %v", err)
+	}
+	return policy, nil
+}
+
+// grantedActions returns the catalog actions and the used actions matched by a pattern, or the pattern itself when
+// the catalog cannot expand it.
+func grantedActions(pattern string, used []string) []string {
+	actions, ok := catalog.Expand(pattern)
+	if !ok {
+		return []string{pattern}
+	}
+	listed := map[string]bool{}
+	for _, action := range actions {
+		listed[strings.ToLower(action)] = true
+	}
+	var unlisted []string
+	for _, action := range used {
+		if !listed[strings.ToLower(action)] && simulator.MatchAction(pattern, action) {
+			listed[strings.ToLower(action)] = true
+			unlisted = append(unlisted, action)
+		}
+	}
+	sort.Strings(unlisted)
+	return append(actions, unlisted...)
+}
+
+func (u Usage) grant(action string, since time.Time) Grant {
+	grant := Grant{Action: action, Service: action}
+	if i := strings.Index(action, ":"); i != -1 {
+		grant.Service = strings.ToLower(action[:i])
+	}
+
+	if strings.ContainsAny(action, "*?") {
+		// A pattern the catalog cannot expand is used when any action it matches was used.
+		for used, lastUsed := range u.Actions {
+			if simulator.MatchAction(action, used) && lastUsed.After(grant.LastUsed) {
+				grant.LastUsed = lastUsed
+			}
+		}
+		if grant.LastUsed.IsZero() && u.Tracked != nil {
+			grant.ServiceLevel = true
+			grant.LastUsed, _ = u.lastServiceUse(grant.Service)
+		}
+	} else if u.IsTracked(action) {
+		grant.LastUsed = u.Actions[strings.ToLower(action)]
+	} else {
+		grant.ServiceLevel = true
+		grant.LastUsed, _ = u.lastServiceUse(grant.Service)
+	}
+	grant.Used = !grant.LastUsed.IsZero() && !grant.LastUsed.Before(since)
+	return grant
+}
+
+// lastServiceUse returns the last use of the services matched by a service prefix, which may contain wildcards.
+func (u Usage) lastServiceUse(prefix string) (time.Time, bool) {
+	var last time.Time
+	for service, lastUsed := range u.Services {
+		if simulator.MatchAction(prefix, service) && lastUsed.After(last) {
+			last = lastUsed
+		}
+	}
+	return last, !last.IsZero()
+}
@@ -0,0 +1,107 @@
+package usage
+
+/*
+This file collects when the actions and services of an account were last used, from one of two sources:
+ - the activity of a role observed in CloudTrail logs, which is known for every action,
+ - an IAM Access Advisor "last accessed" export, the output of aws iam get-service-last-accessed-details.
+   Access Advisor tracks the last use of every service, but of individual actions only for some actions
+   (e.g. S3 management actions, not object reads), so other actions are considered used when their service was used.
+
+More information about Access Advisor can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/access_policies_last-accessed.html
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cloudtrail"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+type Usage struct {
+	// Actions maps lower-cased action names, e.g. s3:getobject, to the last time they were used.
+	Actions map[string]time.Time
+	// Names maps the lower-cased action names to their name as the source wrote it, e.g. s3:GetObject.
+	Names map[string]string
+	// Services maps service prefixes, e.g. s3, to the last time any of their actions was used.
+	Services map[string]time.Time
+	// Tracked lists the lower-cased actions whose own usage is known. When nil, every action is tracked.
+	Tracked map[string]bool
+}
+
+func newUsage() Usage {
+	return Usage{Actions: map[string]time.Time{}, Names: map[string]string{}, Services: map[string]time.Time{}}
+}
+
+// FromActivity returns the usage of the actions observed in CloudTrail.
+func FromActivity(activity cloudtrail.Activity) Usage {
+	usage := newUsage()
+	for _, observed := range activity.Actions {
+		usage.record(observed.Action, observed.LastUsed)
+	}
+	return usage
+}
+
+// ReadAccessAdvisor reads an Access Advisor export, with or without action-level details.
+func ReadAccessAdvisor(path string) (Usage, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	var export struct {
+		JobStatus            string `json:"JobStatus"`
+		ServicesLastAccessed []struct {
+			ServiceNamespace           string     `json:"ServiceNamespace"`
+			LastAuthenticated          *time.Time `json:"LastAuthenticated"`
+			TrackedActionsLastAccessed []struct {
+				ActionName       string     `json:"ActionName"`
+				LastAccessedTime *time.Time `json:"LastAccessedTime"`
+			} `json:"TrackedActionsLastAccessed"`
+		} `json:"ServicesLastAccessed"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return Usage{}, fmt.Errorf("invalid Access Advisor export: %v", err)
+	}
+	if export.JobStatus != "" && export.JobStatus != "COMPLETED" {
+		return Usage{}, fmt.Errorf("the Access Advisor job is %s, export it once it is COMPLETED", export.JobStatus)
+	}
+	if export.ServicesLastAccessed == nil {
+		return Usage{}, fmt.Errorf("invalid Access Advisor export: missing ServicesLastAccessed")
+	}
+
+	usage := newUsage()
+	usage.Tracked = map[string]bool{}
+	for _, service := range export.ServicesLastAccessed {
+		prefix := strings.ToLower(service.ServiceNamespace)
+		if service.LastAuthenticated != nil {
+			usage.Services[prefix] = *service.LastAuthenticated
+		}
+		for _, action := range service.TrackedActionsLastAccessed {
+			usage.Tracked[strings.ToLower(prefix+":"+action.ActionName)] = true
+			if action.LastAccessedTime != nil {
+				usage.record(prefix+":"+action.ActionName, *action.LastAccessedTime)
+			}
+		}
+	}
+	return usage, nil
+}
+
+// IsTracked reports whether the usage of the action itself is known, rather than only the usage of its service.
+func (u Usage) IsTracked(action string) bool {
+	return u.Tracked == nil || u.Tracked[strings.ToLower(action)]
+}
+
+func (u Usage) record(name string, lastUsed time.Time) {
+	action := strings.ToLower(name)
+	u.Names[action] = name
+	if lastUsed.After(u.Actions[action]) {
+		u.Actions[action] = lastUsed
+	}
+	prefix := action[:strings.Index(action, ":")]
+	if lastUsed.After(u.Services[prefix]) {
+		u.Services[prefix] = lastUsed
+	}
+}
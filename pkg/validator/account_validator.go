@@ -0,0 +1,70 @@
+package validator
+
+/*
+This file finds the accounts a statement refers to, in its resource ARNs and in the AWS principals it names,
+so that they can be checked against the allowed account IDs of the configuration. It catches policies granting
+access to, or trusting, an account outside of the organization, e.g. because of a typo in an account ID.
+Wildcards and AWS owned resources, such as AWS managed policies, have no account and are not checked.
+
+More information about the ARN format can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/reference-arns.html
+*/
+
+import (
+	"regexp"
+	"strings"
+)
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// AccountReference is a value of a statement that refers to an account.
+type AccountReference struct {
+	// Element is the element holding the value, Resource, NotResource, Principal.AWS or NotPrincipal.AWS.
+	Element string
+	// Index is the position of the value in the element, -1 when the element is a single string.
+	Index   int
+	Value   string
+	Account string
+}
+
+// AccountReferences lists the values of a statement that refer to an account, in element order.
+func AccountReferences(statement Statement) []AccountReference {
+	var references []AccountReference
+	add := func(element string, value interface{}) {
+		index := 0
+		if _, single := value.(string); single {
+			index = -1
+		}
+		for _, v := range StringValues(value) {
+			if account := ARNAccount(v); account != "" {
+				references = append(references, AccountReference{Element: element, Index: index, Value: v, Account: account})
+			}
+			if index >= 0 {
+				index++
+			}
+		}
+	}
+
+	if statement.Principal != nil {
+		add("Principal.AWS", statement.Principal.AWS)
+	}
+	if statement.NotPrincipal != nil {
+		add("NotPrincipal.AWS", statement.NotPrincipal.AWS)
+	}
+	add("Resource", statement.Resource)
+	add("NotResource", statement.NotResource)
+	return references
+}
+
+// ARNAccount returns the account ID of an ARN, or of a principal given as a bare account ID,
+// and "" when it has none.
+func ARNAccount(value string) string {
+	if accountIDPattern.MatchString(value) {
+		return value
+	}
+	parts := strings.SplitN(value, ":", 6)
+	if len(parts) == 6 && parts[0] == "arn" && accountIDPattern.MatchString(parts[4]) {
+		return parts[4]
+	}
+	return ""
+}
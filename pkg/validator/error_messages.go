@@ -1,5 +1,10 @@
 package validator
 
+import (
+	"sort"
+	"strings"
+)
+
 var errorMessages = map[string]string{
 	"emptyName":         "PolicyName is required and cannot be empty",
 	"invalidNameType":   "PolicyName must be String",
@@ -17,14 +22,68 @@ var errorMessages = map[string]string{
 	"invalidActionFormat": "Invalid action format: each action must include a colon, like 'service:action'",
 	"invalidActionType":   "Actions must be a string or a slice of strings",
 
-	"emptyResource":       "At least one Resource or NotResource is required",
-	"bothResource":        "There can be only one of Resource or NotResource",
-	"invalidResourceType": "Resource must be a string or a slice of strings",
-	"wildcardResource":    "Resource is a wildcard",
+	"emptyResource":         "At least one Resource or NotResource is required",
+	"bothResource":          "There can be only one of Resource or NotResource",
+	"invalidResourceType":   "Resource must be a string or a slice of strings",
+	"wildcardResource":      "Resource is a wildcard",
+	"invalidResourceFormat": "Resource entries must be strings, like 'arn:aws:s3:::bucket/*'",
 
 	"emptyStatement": "At least one Statement is required",
+
+	"invalidFormat": "Policy must be well-formed JSON or YAML and only use the documented elements with their types",
+	"duplicateKey":  "Keys must be defined only once in an object",
+
+	"resourcePolicyPrincipal": "Each statement of a resource-based policy must have a Principal or NotPrincipal",
+
+	"unknownAccount": "Account is not one of the allowed account IDs",
+
+	"emptyTrustPolicy":           "AssumeRolePolicyDocument is required",
+	"trustPolicyPrincipal":       "Each statement of a trust policy must have a Principal naming at least one principal",
+	"trustPolicyNotPrincipal":    "NotPrincipal is not supported in trust policies",
+	"trustPolicyResource":        "Trust policies cannot have a Resource or NotResource",
+	"trustPolicyAction":          "Trust policy actions must be sts actions, like 'sts:AssumeRole'",
+	"invalidRoleName":            "RoleName should match the pattern [\\w+=,.@-]+ and must be <= 64 characters",
+	"invalidRolePath":            "Path must begin and end with '/', contain only printable ASCII characters and be <= 512 characters",
+	"invalidRoleDescription":     "Description must be <= 1000 characters",
+	"invalidManagedPolicyArn":    "ManagedPolicyArns must be IAM policy ARNs, like 'arn:aws:iam::aws:policy/ReadOnlyAccess'",
+	"duplicateManagedPolicyArn":  "ManagedPolicyArns must not list the same policy more than once",
+	"tooManyManagedPolicies":     "A role can have at most 20 managed policies attached",
+	"invalidPermissionsBoundary": "PermissionsBoundary must be an IAM policy ARN, like 'arn:aws:iam::123456789012:policy/Boundary'",
+	"invalidMaxSessionDuration":  "MaxSessionDuration must be between 3600 and 43200 seconds",
+	"duplicatePolicyName":        "PolicyName of the inline policies of a role must be unique",
+	"roleInlinePolicySize":       "The inline policies of a role must not exceed 10240 characters in total, whitespace excluded",
+
+	"redundantStatement": "Statement is fully covered by another statement with the same Effect",
+	"shadowedStatement":  "Allow statement never takes effect because a Deny statement covers it",
+	"duplicateEntry":     "Action or Resource entry is listed more than once in the same statement",
+	"conditionsKey":      "The condition block must be named Condition, not Conditions",
+	"elementNameCase":    "Policy element names are case-sensitive and must be written as documented",
+	"effectCase":         "Effect must be written 'Allow' or 'Deny', with a capital letter",
+	"legacyVersion":      "Version 2008-10-17 does not support policy variables, use 2012-10-17",
 }
 
 func GetErrorMessage(key string) string {
 	return errorMessages[key]
 }
+
+// ErrorKey returns the key of the catalog message an error ends with, such as "wildcardResource" for
+// "Policies[0]: Resource is a wildcard", or an empty string when the error is not from the catalog.
+func ErrorKey(err error) string {
+	message, key := err.Error(), ""
+	for candidate, text := range errorMessages {
+		if strings.HasSuffix(message, text) && len(text) > len(errorMessages[key]) {
+			key = candidate
+		}
+	}
+	return key
+}
+
+// ErrorKeys returns the keys of the catalog in alphabetical order.
+func ErrorKeys() []string {
+	keys := make([]string, 0, len(errorMessages))
+	for key := range errorMessages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -49,7 +49,18 @@ func ValidatePolicyDocument(policyDocument PolicyDocument) (bool, error) {
 	return true, nil
 }
 
+// StatementOptions relaxes the checks of a statement for the policy types that need it.
+type StatementOptions struct {
+	// WildcardResource accepts "*" as the only Resource, which KMS key policies use to refer to their key.
+	WildcardResource bool
+}
+
 func ValidateStatement(statement Statement) (bool, error) {
+	return ValidateStatementWith(statement, StatementOptions{})
+}
+
+// ValidateStatementWith validates a single statement with the checks relaxed by options.
+func ValidateStatementWith(statement Statement, options StatementOptions) (bool, error) {
 	if result, err := ValidateEffect(statement.Effect); err != nil {
 		return result, err
 	}
@@ -59,7 +70,7 @@ func ValidateStatement(statement Statement) (bool, error) {
 	}
 
 	if (statement.Resource != nil) && (statement.NotResource != nil) {
-		return false, fmt.Errorf(errorMessages["bothResources"])
+		return false, fmt.Errorf(errorMessages["bothResource"])
 	}
 
 	if statement.Action != nil {
@@ -74,7 +85,7 @@ func ValidateStatement(statement Statement) (bool, error) {
 		}
 	}
 
-	if statement.Resource != nil {
+	if statement.Resource != nil && !(options.WildcardResource && onlyWildcard(statement.Resource)) {
 		if result, err := ValidateResources(statement.Resource); !result {
 			return result, err
 		}
@@ -206,6 +217,12 @@ func ValidateResources(resources interface{}) (bool, error) {
 	return true, nil
 }
 
+// onlyWildcard reports whether "*" is the only value of a Resource, as a string or a list.
+func onlyWildcard(resources interface{}) bool {
+	values := StringValues(resources)
+	return len(values) == 1 && values[0] == "*"
+}
+
 func ValidateEffect(effect string) (bool, error) {
 	if effect == "" {
 		return false, fmt.Errorf(errorMessages["emptyEffect"])
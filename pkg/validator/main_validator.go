@@ -2,28 +2,98 @@ package validator
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 )
 
+// ValidatePolicyJson reads, decodes and validates the policy at path. It does not print anything,
+// the callers report the result in their own output format.
 func ValidatePolicyJson(path string) (bool, error) {
 	fileContent, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Printf("Error reading %s: %s ❌\n", path, err)
 		return false, err
 	}
 
-	policy, err := loadPolicyFromJSON(fileContent)
+	policy, err := loadPolicy(fileContent)
 	if err != nil {
-		fmt.Println("Decoder: Invalid format of JSON ❌")
 		return false, err
 	}
 
-	err = ValidateIAMPolicy(policy)
+	if err := ValidateIAMPolicy(policy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidatePolicyReader reads policies from r, one or several JSON documents one after another or a single YAML
+// document, and validates them in order. It stops at the first invalid policy.
+func ValidatePolicyReader(r io.Reader) (bool, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+
+	documents := SplitDocuments(data)
+	if len(documents) == 0 {
+		return false, fmt.Errorf("policy is empty")
+	}
+	for i, document := range documents {
+		policy, err := loadPolicy(document.Data)
+		if err == nil {
+			err = ValidateIAMPolicy(policy)
+		}
+		if err != nil && len(documents) > 1 {
+			return false, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ValidateRoleJson reads, decodes and validates the role at path.
+func ValidateRoleJson(path string) (bool, error) {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	role, err := DecodeRole(fileContent)
 	if err != nil {
-		fmt.Println("Validator: Invalid IAM policy format ❌")
 		return false, err
 	}
 
-	fmt.Println("Policy JSON validation passed ✅")
+	if err := ValidateRole(role); err != nil {
+		return false, err
+	}
 	return true, nil
 }
+
+// LoadPolicyFile reads and decodes the policy at path, then validates it.
+func LoadPolicyFile(path string) (IAMPolicy, error) {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return IAMPolicy{}, err
+	}
+
+	policy, err := loadPolicy(fileContent)
+	if err != nil {
+		return IAMPolicy{}, err
+	}
+
+	if err := ValidateIAMPolicy(policy); err != nil {
+		return IAMPolicy{}, err
+	}
+	return policy, nil
+}
+
+// DecodePolicyFile reads and decodes the policy at path without validating it,
+// for policies such as SCPs and permission boundaries that legitimately use wildcard resources.
+func DecodePolicyFile(path string) (IAMPolicy, error) {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return IAMPolicy{}, err
+	}
+	return loadPolicy(fileContent)
+}
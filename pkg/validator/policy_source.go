@@ -0,0 +1,335 @@
+package validator
+
+/*
+This file loads policies written in JSON or in YAML. The format is detected from the content: a document starting
+with '{' is JSON, anything else is YAML. YAML is converted to JSON and decoded with the same strict decoder,
+so both formats accept exactly the same policies:
+ - unknown fields are rejected,
+ - duplicate keys are rejected, where encoding/json would silently keep the last value,
+ - values keep their YAML type, except dates such as an unquoted Version: 2012-10-17, which stay strings.
+
+Decoding errors are reported with their line and column in the original file, YAML lines for YAML files.
+
+A stream, such as the output of a script piped in, may hold several JSON policies one after another; SplitDocuments
+cuts it into documents, each loaded on its own.
+
+More information about YAML can be found here:
+ - https://yaml.org/spec/1.2.2/
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/jsonast"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// PositionError is an error located at a 1-based line and column of the original file.
+type PositionError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// Source is a policy file together with its JSON form, which is the file itself for JSON files.
+type Source struct {
+	Format Format
+	Data   []byte
+	JSON   []byte
+	// Root is the syntax tree of JSON.
+	Root *jsonast.Node
+	// marks map offsets of JSON to positions in Data, in increasing offset order. They are only set for YAML.
+	marks []mark
+}
+
+// yamlErrorLine matches the syntax errors of the YAML parser, which only give a line.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// StreamDocument is one of the documents of a stream, starting Offset bytes into it.
+type StreamDocument struct {
+	Offset int
+	Data   []byte
+}
+
+type mark struct {
+	offset, line, column int
+}
+
+// DetectFormat tells whether data is written in JSON or YAML.
+func DetectFormat(data []byte) Format {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// NewSource parses a JSON or YAML file, rejecting duplicate keys.
+func NewSource(data []byte) (*Source, error) {
+	source := &Source{Format: DetectFormat(data), Data: data}
+	if source.Format == FormatYAML {
+		if err := source.convertYAML(); err != nil {
+			return nil, err
+		}
+	} else {
+		source.JSON = data
+	}
+
+	root, err := jsonast.Parse(source.JSON)
+	if err != nil {
+		var syntaxErr *jsonast.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, source.errorAt(syntaxErr.Offset, err)
+		}
+		return nil, err
+	}
+	source.Root = root
+	if member := duplicateKey(root); member != nil {
+		return nil, source.errorAt(member.KeyStart, fmt.Errorf("%s: %s", member.Key, errorMessages["duplicateKey"]))
+	}
+	return source, nil
+}
+
+// Decode strictly decodes the source into a policy, without validating its content.
+func (s *Source) Decode() (IAMPolicy, error) {
+	var policy IAMPolicy
+	if err := s.decode(&policy); err != nil {
+		return IAMPolicy{}, err
+	}
+	return policy, nil
+}
+
+// DecodeDocument strictly decodes the source into a policy document on its own, as written for resource-based
+// policies and trust policies.
+func (s *Source) DecodeDocument() (PolicyDocument, error) {
+	var document PolicyDocument
+	if err := s.decode(&document); err != nil {
+		return PolicyDocument{}, err
+	}
+	return document, nil
+}
+
+func (s *Source) decode(v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(s.JSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return s.locate(err)
+	}
+	return nil
+}
+
+// Position converts an offset of the JSON form into a line and column of the original file.
+func (s *Source) Position(offset int) (line, column int) {
+	if s.Format != FormatYAML {
+		return jsonast.Position(s.Data, offset)
+	}
+	i := sort.Search(len(s.marks), func(i int) bool { return s.marks[i].offset > offset })
+	if i == 0 {
+		return 1, 1
+	}
+	return s.marks[i-1].line, s.marks[i-1].column
+}
+
+func (s *Source) errorAt(offset int, err error) error {
+	line, column := s.Position(offset)
+	return &PositionError{Line: line, Column: column, Err: err}
+}
+
+// locate finds where a decoding error comes from, for the errors of encoding/json that tell it.
+func (s *Source) locate(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		if node := s.Root.NodeAt(int(typeErr.Offset) - 1); node != nil {
+			return s.errorAt(node.Start, err)
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if member := findMember(s.Root, name); member != nil {
+			return s.errorAt(member.KeyStart, err)
+		}
+	}
+	return err
+}
+
+// SplitDocuments cuts a stream of concatenated JSON documents into documents. A YAML stream, and the rest of
+// a JSON stream from the first syntax error, are a single document, so that loading it reports the error.
+func SplitDocuments(data []byte) []StreamDocument {
+	if DetectFormat(data) != FormatJSON {
+		return []StreamDocument{{Offset: 0, Data: data}}
+	}
+
+	var documents []StreamDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		start := int(decoder.InputOffset())
+		start += len(data[start:]) - len(bytes.TrimLeft(data[start:], " \t\r\n"))
+		if start == len(data) {
+			return documents
+		}
+		var document json.RawMessage
+		if err := decoder.Decode(&document); err != nil {
+			return append(documents, StreamDocument{Offset: start, Data: data[start:]})
+		}
+		documents = append(documents, StreamDocument{Offset: start, Data: data[start:decoder.InputOffset()]})
+	}
+}
+
+func loadPolicy(data []byte) (IAMPolicy, error) {
+	source, err := NewSource(data)
+	if err != nil {
+		return IAMPolicy{}, err
+	}
+	return source.Decode()
+}
+
+// duplicateKey returns the first member whose key is already used in the same object.
+func duplicateKey(node *jsonast.Node) *jsonast.Member {
+	seen := map[string]bool{}
+	for _, member := range node.Members {
+		if seen[member.Key] {
+			return member
+		}
+		seen[member.Key] = true
+		if duplicate := duplicateKey(member.Value); duplicate != nil {
+			return duplicate
+		}
+	}
+	for _, element := range node.Elements {
+		if duplicate := duplicateKey(element); duplicate != nil {
+			return duplicate
+		}
+	}
+	return nil
+}
+
+// findMember returns the first member named name, outside of condition blocks whose keys are free-form.
+func findMember(node *jsonast.Node, name string) *jsonast.Member {
+	for _, member := range node.Members {
+		if member.Key == name {
+			return member
+		}
+		if strings.EqualFold(member.Key, "Condition") {
+			continue
+		}
+		if found := findMember(member.Value, name); found != nil {
+			return found
+		}
+	}
+	for _, element := range node.Elements {
+		if found := findMember(element, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (s *Source) convertYAML() error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(s.Data, &root); err != nil {
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return &PositionError{Line: line, Column: 1, Err: errors.New(match[2])}
+		}
+		return err
+	}
+	if len(root.Content) == 0 {
+		return fmt.Errorf("policy is empty")
+	}
+
+	var buffer bytes.Buffer
+	if err := s.writeJSON(&buffer, root.Content[0]); err != nil {
+		return err
+	}
+	s.JSON = buffer.Bytes()
+	return nil
+}
+
+// writeJSON writes a YAML node as JSON, marking where each key and value starts.
+func (s *Source) writeJSON(buffer *bytes.Buffer, node *yaml.Node) error {
+	s.marks = append(s.marks, mark{offset: buffer.Len(), line: node.Line, column: node.Column})
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return s.writeJSON(buffer, node.Alias)
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return &PositionError{Line: key.Line, Column: key.Column, Err: fmt.Errorf("keys must be strings")}
+			}
+			if seen[key.Value] {
+				return &PositionError{Line: key.Line, Column: key.Column, Err: fmt.Errorf("%s: %s", key.Value, errorMessages["duplicateKey"])}
+			}
+			seen[key.Value] = true
+
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			s.marks = append(s.marks, mark{offset: buffer.Len(), line: key.Line, column: key.Column})
+			writeString(buffer, key.Value)
+			buffer.WriteByte(':')
+			if err := s.writeJSON(buffer, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, element := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := s.writeJSON(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	default:
+		var value interface{}
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+			if err := node.Decode(&value); err != nil {
+				return &PositionError{Line: node.Line, Column: node.Column, Err: err}
+			}
+		default:
+			// Strings, but also dates such as the policy Version, which must stay strings.
+			value = node.Value
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			// Numbers JSON cannot represent, such as .inf, are kept as written.
+			encoded, _ = json.Marshal(node.Value)
+		}
+		buffer.Write(encoded)
+	}
+	return nil
+}
+
+func writeString(buffer *bytes.Buffer, value string) {
+	encoded, _ := json.Marshal(value)
+	buffer.Write(encoded)
+}
@@ -0,0 +1,66 @@
+package validator
+
+/*
+This file provides a function to validate resource-based policies, such as S3 bucket policies, SQS queue policies
+or KMS key policies. Unlike identity policies, they are attached to a resource, have no PolicyName,
+and each of their statements must name the principals it applies to.
+
+More information about resource-based policies can be found here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/access_policies_identity-vs-resource.html
+*/
+
+import (
+	"fmt"
+)
+
+func ValidateResourcePolicy(document PolicyDocument) (bool, error) {
+	if result, err := ValidatePolicyDocument(document); err != nil {
+		return result, err
+	}
+	if len(document.Statement) == 0 {
+		return false, fmt.Errorf(errorMessages["emptyStatement"])
+	}
+
+	for _, statement := range document.Statement {
+		if result, err := ValidateResourceStatement(statement); err != nil {
+			return result, err
+		}
+	}
+	return true, nil
+}
+
+// ValidateResourceStatement validates a single statement of a resource-based policy.
+func ValidateResourceStatement(statement Statement) (bool, error) {
+	return ValidateResourceStatementWith(statement, StatementOptions{})
+}
+
+// ValidateResourceStatementWith validates a single statement of a resource-based policy with the checks relaxed by options.
+func ValidateResourceStatementWith(statement Statement, options StatementOptions) (bool, error) {
+	if statement.Principal == nil && statement.NotPrincipal == nil {
+		return false, fmt.Errorf(errorMessages["resourcePolicyPrincipal"])
+	}
+	return ValidateStatementWith(statement, options)
+}
+
+// ValidateKeyPolicy validates a KMS key policy, a resource-based policy whose statements must use "*" as
+// Resource to refer to the key itself.
+func ValidateKeyPolicy(document PolicyDocument) (bool, error) {
+	if result, err := ValidatePolicyDocument(document); err != nil {
+		return result, err
+	}
+	if len(document.Statement) == 0 {
+		return false, fmt.Errorf(errorMessages["emptyStatement"])
+	}
+
+	for _, statement := range document.Statement {
+		if result, err := ValidateKeyStatement(statement); err != nil {
+			return result, err
+		}
+	}
+	return true, nil
+}
+
+// ValidateKeyStatement validates a single statement of a KMS key policy.
+func ValidateKeyStatement(statement Statement) (bool, error) {
+	return ValidateResourceStatementWith(statement, StatementOptions{WildcardResource: true})
+}
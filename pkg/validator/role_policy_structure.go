@@ -10,6 +10,7 @@ and more about the IAM Role Policy here:
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 )
 
 type IAMPolicy struct {
@@ -23,16 +24,17 @@ type PolicyDocument struct {
 	Statement []Statement `json:"Statement"`
 }
 
+// Fields are declared in the order AWS documents them, which is also the order they are marshalled in.
 type Statement struct {
 	Sid          string                  `json:"Sid,omitempty"`
+	Effect       string                  `json:"Effect"`
 	Principal    *PrincipalBlock         `json:"Principal,omitempty"`
 	NotPrincipal *PrincipalBlock         `json:"NotPrincipal,omitempty"`
-	Effect       string                  `json:"Effect"`
 	Action       interface{}             `json:"Action,omitempty"`
 	NotAction    interface{}             `json:"NotAction,omitempty"`
-	Resource     interface{}             `json:"Resource"`
+	Resource     interface{}             `json:"Resource,omitempty"`
 	NotResource  interface{}             `json:"NotResource,omitempty"`
-	Conditions   map[string]ConditionMap `json:"Conditions,omitempty"`
+	Conditions   map[string]ConditionMap `json:"Condition,omitempty"`
 }
 
 type PrincipalBlock struct {
@@ -42,16 +44,94 @@ type PrincipalBlock struct {
 	CanonicalUser interface{} `json:"CanonicalUser,omitempty" validate:"optional"`
 }
 
+// UnmarshalJSON accepts "Principal": "*", which AWS treats as {"AWS": "*"}, and otherwise strictly decodes the block.
+func (p *PrincipalBlock) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != "*" {
+			return fmt.Errorf("principal must be \"*\" or an object, got %q", wildcard)
+		}
+		*p = PrincipalBlock{AWS: "*"}
+		return nil
+	}
+
+	type principalBlock PrincipalBlock
+	var block principalBlock
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&block); err != nil {
+		return err
+	}
+	*p = PrincipalBlock(block)
+	return nil
+}
+
+// ConditionMap maps condition keys to their values. Like AWS, a single value may be written without a list,
+// and booleans and numbers are accepted in place of strings.
 type ConditionMap map[string][]string
 
-func loadPolicyFromJSON(data []byte) (IAMPolicy, error) {
-	var policy IAMPolicy
+func (c *ConditionMap) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	conditions := make(ConditionMap, len(raw))
+	for key, value := range raw {
+		items, isList := value.([]interface{})
+		if !isList {
+			items = []interface{}{value}
+		}
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			switch v := item.(type) {
+			case string:
+				values = append(values, v)
+			case bool, float64:
+				values = append(values, fmt.Sprint(v))
+			default:
+				return fmt.Errorf("condition values of %s must be strings, booleans or numbers", key)
+			}
+		}
+		conditions[key] = values
+	}
+	*c = conditions
+	return nil
+}
+
+// DecodePolicy strictly decodes a policy, written in JSON or YAML, without validating its content.
+func DecodePolicy(data []byte) (IAMPolicy, error) {
+	return loadPolicy(data)
+}
+
+// DecodePolicyDocument strictly decodes a policy document on its own, as found in resource-based policies,
+// trust policies and infrastructure-as-code templates, without validating its content.
+func DecodePolicyDocument(data []byte) (PolicyDocument, error) {
+	var document PolicyDocument
 	decoder := json.NewDecoder(bytes.NewReader(data))
 	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return PolicyDocument{}, err
+	}
+	return document, nil
+}
 
-	err := decoder.Decode(&policy)
-	if err != nil {
-		return IAMPolicy{}, err
+// StringValues flattens a policy element that may be a single string or a list of strings.
+// Values of any other type are skipped.
+func StringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
 	}
-	return policy, nil
+	return nil
 }
@@ -0,0 +1,55 @@
+package validator
+
+/*
+ More information about the AWS::IAM::Role resource can be found here:
+ - https://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/aws-resource-iam-role.html
+and more about the limits applying to roles here:
+ - https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_iam-quotas.html
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Role holds the properties of an AWS::IAM::Role resource.
+type Role struct {
+	AssumeRolePolicyDocument *PolicyDocument `json:"AssumeRolePolicyDocument"`
+	Description              string          `json:"Description,omitempty"`
+	ManagedPolicyArns        []string        `json:"ManagedPolicyArns,omitempty"`
+	MaxSessionDuration       *int            `json:"MaxSessionDuration,omitempty"`
+	Path                     string          `json:"Path,omitempty"`
+	PermissionsBoundary      string          `json:"PermissionsBoundary,omitempty"`
+	Policies                 []IAMPolicy     `json:"Policies,omitempty"`
+	RoleName                 string          `json:"RoleName,omitempty"`
+	Tags                     []Tag           `json:"Tags,omitempty"`
+}
+
+type Tag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+// DecodeRole strictly decodes a role, given either as a whole resource with Type and Properties,
+// or as its properties only, without validating its content.
+func DecodeRole(data []byte) (Role, error) {
+	var resource struct {
+		Type       string          `json:"Type"`
+		Properties json.RawMessage `json:"Properties"`
+	}
+	if err := json.Unmarshal(data, &resource); err == nil && resource.Properties != nil {
+		if resource.Type != "AWS::IAM::Role" {
+			return Role{}, fmt.Errorf("resource type is %q, expected AWS::IAM::Role", resource.Type)
+		}
+		data = resource.Properties
+	}
+
+	var role Role
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&role); err != nil {
+		return Role{}, err
+	}
+	return role, nil
+}
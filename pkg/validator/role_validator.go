@@ -0,0 +1,190 @@
+package validator
+
+/*
+This file provides a function to validate a whole AWS::IAM::Role resource.
+The function ValidateRole checks the trust policy (AssumeRolePolicyDocument), each inline policy of Policies
+with ValidateIAMPolicy, and the properties that identify and limit the role: RoleName, Path, Description,
+ManagedPolicyArns, PermissionsBoundary and MaxSessionDuration.
+Errors are prefixed with the property they were found in, e.g. "Policies[1]: Effect must be 'Allow' or 'Deny'".
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxManagedPolicies     = 20
+	maxInlinePoliciesSize  = 10240
+	minMaxSessionDuration  = 3600
+	maxMaxSessionDuration  = 43200
+	maxRoleNameLength      = 64
+	maxRolePathLength      = 512
+	maxRoleDescriptionSize = 1000
+)
+
+var (
+	roleNamePattern  = regexp.MustCompile(`^[\w+=,.@-]+$`)
+	rolePathPattern  = regexp.MustCompile(`^(/|/[\x21-\x7E]+/)$`)
+	policyArnPattern = regexp.MustCompile(`^arn:aws(-cn|-us-gov)?:iam::(aws|\d{12}):policy/([\x21-\x7E]+/)?[\w+=,.@-]+$`)
+)
+
+func ValidateRole(role Role) error {
+	if role.AssumeRolePolicyDocument == nil {
+		return fmt.Errorf(errorMessages["emptyTrustPolicy"])
+	}
+	if _, err := ValidateTrustPolicy(*role.AssumeRolePolicyDocument); err != nil {
+		return fmt.Errorf("AssumeRolePolicyDocument: %w", err)
+	}
+
+	if role.RoleName != "" {
+		if _, err := ValidateRoleName(role.RoleName); err != nil {
+			return err
+		}
+	}
+	if role.Path != "" {
+		if _, err := ValidateRolePath(role.Path); err != nil {
+			return err
+		}
+	}
+	if len(role.Description) > maxRoleDescriptionSize {
+		return fmt.Errorf(errorMessages["invalidRoleDescription"])
+	}
+
+	if _, err := ValidateManagedPolicyArns(role.ManagedPolicyArns); err != nil {
+		return err
+	}
+	if role.PermissionsBoundary != "" {
+		if _, err := ValidatePermissionsBoundary(role.PermissionsBoundary); err != nil {
+			return err
+		}
+	}
+	if role.MaxSessionDuration != nil {
+		if _, err := ValidateMaxSessionDuration(*role.MaxSessionDuration); err != nil {
+			return err
+		}
+	}
+
+	names := map[string]bool{}
+	size := 0
+	for i, policy := range role.Policies {
+		if err := ValidateIAMPolicy(policy); err != nil {
+			return fmt.Errorf("Policies[%d]: %w", i, err)
+		}
+		if names[policy.PolicyName] {
+			return fmt.Errorf("Policies[%d]: %s", i, errorMessages["duplicatePolicyName"])
+		}
+		names[policy.PolicyName] = true
+
+		document, err := json.Marshal(policy.PolicyDocument)
+		if err != nil {
+			return fmt.Errorf("Policies[%d]: %v", i, err)
+		}
+		size += len(document)
+	}
+	if size > maxInlinePoliciesSize {
+		return fmt.Errorf(errorMessages["roleInlinePolicySize"])
+	}
+
+	return nil
+}
+
+// ValidateTrustPolicy validates a role trust policy, whose statements name who can assume the role
+// instead of the resources they apply to.
+func ValidateTrustPolicy(document PolicyDocument) (bool, error) {
+	if result, err := ValidatePolicyDocument(document); err != nil {
+		return result, err
+	}
+	if len(document.Statement) == 0 {
+		return false, fmt.Errorf(errorMessages["emptyStatement"])
+	}
+
+	for _, statement := range document.Statement {
+		if result, err := ValidateTrustStatement(statement); err != nil {
+			return result, err
+		}
+	}
+	return true, nil
+}
+
+// ValidateTrustStatement validates a single statement of a role trust policy.
+func ValidateTrustStatement(statement Statement) (bool, error) {
+	if result, err := ValidateEffect(statement.Effect); err != nil {
+		return result, err
+	}
+	if statement.NotPrincipal != nil {
+		return false, fmt.Errorf(errorMessages["trustPolicyNotPrincipal"])
+	}
+	if statement.Principal == nil || (statement.Principal.AWS == nil && statement.Principal.Federated == nil &&
+		statement.Principal.Service == nil && statement.Principal.CanonicalUser == nil) {
+		return false, fmt.Errorf(errorMessages["trustPolicyPrincipal"])
+	}
+	if statement.Resource != nil || statement.NotResource != nil {
+		return false, fmt.Errorf(errorMessages["trustPolicyResource"])
+	}
+
+	if (statement.Action != nil) && (statement.NotAction != nil) {
+		return false, fmt.Errorf(errorMessages["bothActions"])
+	}
+	actions := statement.Action
+	if actions == nil {
+		actions = statement.NotAction
+	}
+	if result, err := ValidateActions(actions); !result {
+		return result, err
+	}
+	for _, action := range StringValues(actions) {
+		if !strings.HasPrefix(strings.ToLower(action), "sts:") {
+			return false, fmt.Errorf(errorMessages["trustPolicyAction"])
+		}
+	}
+	return true, nil
+}
+
+func ValidateRoleName(name string) (bool, error) {
+	if !roleNamePattern.MatchString(name) || len(name) > maxRoleNameLength {
+		return false, fmt.Errorf(errorMessages["invalidRoleName"])
+	}
+	return true, nil
+}
+
+func ValidateRolePath(path string) (bool, error) {
+	if !rolePathPattern.MatchString(path) || len(path) > maxRolePathLength {
+		return false, fmt.Errorf(errorMessages["invalidRolePath"])
+	}
+	return true, nil
+}
+
+func ValidateManagedPolicyArns(arns []string) (bool, error) {
+	if len(arns) > maxManagedPolicies {
+		return false, fmt.Errorf(errorMessages["tooManyManagedPolicies"])
+	}
+
+	seen := map[string]bool{}
+	for _, arn := range arns {
+		if !policyArnPattern.MatchString(arn) {
+			return false, fmt.Errorf(errorMessages["invalidManagedPolicyArn"])
+		}
+		if seen[arn] {
+			return false, fmt.Errorf(errorMessages["duplicateManagedPolicyArn"])
+		}
+		seen[arn] = true
+	}
+	return true, nil
+}
+
+func ValidatePermissionsBoundary(arn string) (bool, error) {
+	if !policyArnPattern.MatchString(arn) {
+		return false, fmt.Errorf(errorMessages["invalidPermissionsBoundary"])
+	}
+	return true, nil
+}
+
+func ValidateMaxSessionDuration(seconds int) (bool, error) {
+	if seconds < minMaxSessionDuration || seconds > maxMaxSessionDuration {
+		return false, fmt.Errorf(errorMessages["invalidMaxSessionDuration"])
+	}
+	return true, nil
+}
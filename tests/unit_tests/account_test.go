@@ -0,0 +1,113 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/account"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"testing"
+)
+
+const authorizationDetails = "../fixtures/account/authorization_details.json"
+
+func TestAccountExport(t *testing.T) {
+	export, err := account.LoadExport(authorizationDetails)
+	if err != nil {
+		t.Fatalf("Failed to load export: %v", err)
+	}
+	if len(export.Users) != 2 || len(export.Groups) != 1 || len(export.Roles) != 3 || len(export.Policies) != 3 {
+		t.Fatalf("Unexpected entity counts: %d users, %d groups, %d roles, %d policies",
+			len(export.Users), len(export.Groups), len(export.Roles), len(export.Policies))
+	}
+	if id := export.AccountID(); id != "123456789012" {
+		t.Errorf("Expected account 123456789012, got %q", id)
+	}
+
+	// The trust policy is URL-encoded, the default version of ReportsRead is an object.
+	documents := map[string]account.Document{
+		"AssumeRolePolicyDocument": export.Roles[0].AssumeRolePolicyDocument,
+		"ReportsRead v2":           export.Policies[0].PolicyVersionList[0].Document,
+	}
+	for name, document := range documents {
+		if _, err := validator.DecodePolicyDocument(document); err != nil {
+			t.Errorf("%s: failed to decode document: %v", name, err)
+		}
+	}
+
+	if _, err := account.ParseExport([]byte(`{"Roles": []}`)); err == nil {
+		t.Errorf("Expected an error for a document that is not an export")
+	}
+}
+
+func TestAccountAudit(t *testing.T) {
+	export, err := account.LoadExport(authorizationDetails)
+	if err != nil {
+		t.Fatalf("Failed to load export: %v", err)
+	}
+	report := account.Audit(export, nil)
+
+	expected := []struct {
+		entity   string
+		location string
+		rule     string
+	}{
+		{"arn:aws:iam::123456789012:policy/ReportsRead", "version v1", account.RuleValidation},
+		{"arn:aws:iam::123456789012:group/Developers", "GroupPolicyList[0] (DevelopersRead) PolicyDocument.Statement[0].Action[1]", "duplicateEntry"},
+		{"arn:aws:iam::123456789012:user/bob", "effective permissions", account.RuleSensitiveGrant},
+		{"arn:aws:iam::123456789012:role/BuildRole", "role", account.RuleValidation},
+		{"arn:aws:iam::123456789012:role/BuildRole", "effective permissions", account.RuleSensitiveGrant},
+	}
+	if len(report.Findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %v", len(expected), report.Findings)
+	}
+	for i, finding := range report.Findings {
+		if finding.Entity != expected[i].entity || finding.Location != expected[i].location || finding.Rule != expected[i].rule {
+			t.Errorf("Expected %v, got %v", expected[i], finding)
+		}
+	}
+	if report.Errors() != 2 {
+		t.Errorf("Expected 2 errors, got %d", report.Errors())
+	}
+
+	// The service-linked role is skipped, BuildRole misses an AWS managed policy.
+	if len(report.Principals) != 4 || len(report.Notes) != 2 {
+		t.Fatalf("Expected 4 principals and 2 notes, got %d and %v", len(report.Principals), report.Notes)
+	}
+	for _, principal := range report.Principals {
+		switch principal.Name {
+		case "alice":
+			// Through the Developers group and its AWS managed policy.
+			if !principal.Effective.Allows("sqs:ReceiveMessage") || !principal.Effective.Allows("iam:ChangePassword") {
+				t.Errorf("alice: expected the permissions of the Developers group, got %v", principal.Effective.Permissions)
+			}
+		case "ReportWorker":
+			// The boundary does not allow s3:PutObject, ReportsRead v1 is not the default version.
+			if principal.Effective.Allows("s3:PutObject") || !principal.Effective.Allows("s3:GetObject") {
+				t.Errorf("ReportWorker: unexpected permissions %v", principal.Effective.Permissions)
+			}
+		case "BuildRole":
+			if len(principal.Missing) != 1 {
+				t.Errorf("BuildRole: expected 1 missing policy, got %v", principal.Missing)
+			}
+		}
+	}
+}
+
+func TestAccountAuditSCPs(t *testing.T) {
+	export, err := account.LoadExport(authorizationDetails)
+	if err != nil {
+		t.Fatalf("Failed to load export: %v", err)
+	}
+	scp := validator.IAMPolicy{PolicyName: "DenyAccessKeys", PolicyDocument: validator.PolicyDocument{
+		Version: "2012-10-17",
+		Statement: []validator.Statement{
+			{Effect: "Allow", Action: "*", Resource: "*"},
+			{Effect: "Deny", Action: "iam:CreateAccessKey", Resource: "*"},
+		},
+	}}
+	report := account.Audit(export, [][]validator.IAMPolicy{{scp}})
+
+	for _, finding := range report.Findings {
+		if finding.Entity == "arn:aws:iam::123456789012:user/bob" {
+			t.Errorf("Expected the SCP to remove the sensitive grant of bob, got %v", finding)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package unit_tests
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/kcbojanowski/aws-iam-policy-verifier/api"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
 	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -120,3 +126,153 @@ func generateResponse(t *testing.T, server *httptest.Server, filePath, expectedE
 
 	return gotResponse, resp.StatusCode
 }
+
+// batchPolicies are the policies of the batch tests, by name: a valid policy, one with two invalid statements and
+// one that is not JSON.
+func batchPolicies(t *testing.T) map[string]string {
+	valid, err := ioutil.ReadFile("../test_data/valid_format/valid_policy_1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{"valid.json": string(valid), "invalid.json": twoInvalidStatements, "broken.json": "{"}
+}
+
+func postBatch(t *testing.T, server *httptest.Server, contentType string, body io.Reader) (api.BatchResponse, int) {
+	response, err := http.Post(server.URL+"/validate/batch", contentType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var batch api.BatchResponse
+	if response.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(response.Body).Decode(&batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return batch, response.StatusCode
+}
+
+func TestBatch(t *testing.T) {
+	server := httptest.NewServer(api.NewServer(config.Default()))
+	defer server.Close()
+	policies := batchPolicies(t)
+	names := []string{"valid.json", "invalid.json", "broken.json"}
+
+	// The array is written by hand, as encoding it would compact the policies and change their lines.
+	array := []byte(`[{"name": "valid.json", "policy": ` + policies["valid.json"] + `},
+{"name": "invalid.json", "policy": ` + policies["invalid.json"] + `},
+{"name": "broken.json", "policy": "{"}]`)
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for _, name := range names {
+		part, err := writer.CreateFormFile("policy", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(policies[name]))
+	}
+	writer.Close()
+
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	for _, name := range append(names, "README.md") {
+		file, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(policies[name]))
+	}
+	zipWriter.Close()
+
+	tests := []struct {
+		name, contentType string
+		body              []byte
+	}{
+		{"json", "application/json", array},
+		{"multipart", writer.FormDataContentType(), form.Bytes()},
+		{"zip", "application/zip", archive.Bytes()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			batch, status := postBatch(t, server, test.contentType, bytes.NewReader(test.body))
+			if status != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, status)
+			}
+			if len(batch.Results) != 3 {
+				t.Fatalf("Expected a result per policy, got %+v", batch.Results)
+			}
+			for i, result := range batch.Results {
+				if result.Name != names[i] || result.IsValid != (i == 0) {
+					t.Errorf("Expected %s to be valid: %t, got %+v", names[i], i == 0, result)
+				}
+			}
+			invalid := batch.Results[1]
+			if invalid.Error != validator.GetErrorMessage("invalidEffect") || len(invalid.Findings) != 2 || invalid.Findings[0].Line != 6 {
+				t.Errorf("Expected the findings of both invalid statements, got %+v", invalid)
+			}
+			expected := api.BatchSummary{Total: 3, Valid: 1, Invalid: 2, Errors: 3}
+			if batch.Summary != expected {
+				t.Errorf("Expected summary %+v, got %+v", expected, batch.Summary)
+			}
+		})
+	}
+
+	if _, status := postBatch(t, server, "application/json", strings.NewReader("[]")); status != http.StatusBadRequest {
+		t.Errorf("Expected an empty batch to be rejected, got status %d", status)
+	}
+	if _, status := postBatch(t, server, "text/plain", strings.NewReader("{}")); status != http.StatusBadRequest {
+		t.Errorf("Expected an unsupported Content-Type to be rejected, got status %d", status)
+	}
+}
+
+func TestBatchLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.MaxBatchItems = 2
+	cfg.Server.MaxBodyBytes = 64
+	cfg.Server.MaxBatchBytes = 4096
+	server := httptest.NewServer(api.NewServer(cfg))
+	defer server.Close()
+
+	small := `{"name": "small", "policy": {}}`
+	large := `{"name": "large", "policy": ` + strings.TrimSpace(twoInvalidStatements) + `}`
+	batch, status := postBatch(t, server, "application/json", strings.NewReader("["+small+","+large+"]"))
+	if status != http.StatusOK || len(batch.Results) != 2 || batch.Results[1].Error != "policy larger than 64 bytes" {
+		t.Errorf("Expected the policy over the size limit to be reported, got status %d and %+v", status, batch.Results)
+	}
+	if _, status := postBatch(t, server, "application/json", strings.NewReader("["+small+","+small+","+small+"]")); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a batch over the item limit to be rejected, got status %d", status)
+	}
+	padding := strings.Repeat(small+",", 200)
+	if _, status := postBatch(t, server, "application/json", strings.NewReader("["+padding+small+"]")); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a batch over the size limit to be rejected, got status %d", status)
+	}
+}
+
+func TestBatchDecompressedLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.MaxBatchBytes = 4096
+	server := httptest.NewServer(api.NewServer(cfg))
+	defer server.Close()
+
+	// Each policy compresses to a few bytes, but the two of them are larger than the batch once decompressed.
+	zipPolicies := func(count int) []byte {
+		var archive bytes.Buffer
+		zipWriter := zip.NewWriter(&archive)
+		for i := 0; i < count; i++ {
+			file, err := zipWriter.Create(fmt.Sprintf("policy-%d.json", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Write([]byte("{}" + strings.Repeat(" ", 3000)))
+		}
+		zipWriter.Close()
+		return archive.Bytes()
+	}
+	if _, status := postBatch(t, server, "application/zip", bytes.NewReader(zipPolicies(1))); status != http.StatusOK {
+		t.Errorf("Expected a batch within the limit once decompressed to be checked, got status %d", status)
+	}
+	if _, status := postBatch(t, server, "application/zip", bytes.NewReader(zipPolicies(2))); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a batch over the limit once decompressed to be rejected, got status %d", status)
+	}
+}
@@ -0,0 +1,75 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/baseline"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaselineFilter(t *testing.T) {
+	files := []report.File{report.Check("policy.json", []byte(twoInvalidStatements))}
+	known := baseline.Create(files)
+	if len(known.Findings) != 2 {
+		t.Fatalf("Expected 2 baselined findings, got %v", known.Findings)
+	}
+
+	result := known.Filter(files)
+	if result.Suppressed != 2 || len(result.Stale) != 0 || result.Files[0].Failed() {
+		t.Errorf("Expected every finding suppressed, got %+v", result)
+	}
+
+	// Fixing the effect and moving the wildcard statement down a line leaves one stale entry and no new finding.
+	fixed := strings.Replace(twoInvalidStatements, `"Permit"`, `"Allow"`, 1)
+	fixed = strings.Replace(fixed, `"Statement": [`, "\"Statement\": [\n", 1)
+	result = known.Filter([]report.File{report.Check("policy.json", []byte(fixed))})
+	if result.Suppressed != 1 || len(result.Files[0].Findings) != 0 {
+		t.Errorf("Expected the moved finding suppressed, got %+v", result)
+	}
+	if len(result.Stale) != 1 || result.Stale[0].Rule != "invalidEffect" {
+		t.Errorf("Expected the invalidEffect entry to be stale, got %v", result.Stale)
+	}
+
+	// The same finding in another file is new, and the entries of the file that was not checked are not stale.
+	result = known.Filter([]report.File{report.Check("other.json", []byte(twoInvalidStatements))})
+	if result.Suppressed != 0 || len(result.Files[0].Findings) != 2 || len(result.Stale) != 0 {
+		t.Errorf("Expected the findings of another file to be new, got %+v", result)
+	}
+}
+
+func TestBaselineDuplicates(t *testing.T) {
+	file := report.Check("policy.json", []byte(twoInvalidStatements))
+	known := baseline.Create([]report.File{file})
+
+	// A second identical finding is not covered by the single entry of the baseline.
+	file.Findings = append(file.Findings, file.Findings[0])
+	result := known.Filter([]report.File{file})
+	if result.Suppressed != 2 || len(result.Files[0].Findings) != 1 {
+		t.Errorf("Expected one new finding, got %+v", result)
+	}
+}
+
+func TestBaselineLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, baseline.DefaultPath)
+	created := baseline.Create([]report.File{report.Check("policy.json", []byte(twoInvalidStatements))})
+	if err := created.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := baseline.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Findings) != 2 || loaded.Findings[0] != created.Findings[0] {
+		t.Errorf("Expected the saved baseline, got %v", loaded.Findings)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 1, "findings": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := baseline.Load(path); err == nil || !strings.Contains(err.Error(), "unsupported baseline version") {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+}
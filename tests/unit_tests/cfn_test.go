@@ -0,0 +1,102 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cfn"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"reflect"
+	"testing"
+)
+
+func TestCloudFormationTemplates(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected map[string]string
+	}{
+		{
+			path: "../fixtures/cloudformation/reports_stack.yaml",
+			expected: map[string]string{
+				"ReportWorkerRole":   "",
+				"ReportReadPolicy":   "",
+				"ReportBucketPolicy": "",
+				"ReportKey":          "",
+			},
+		},
+		{
+			path: "../fixtures/cloudformation/invalid_stack.json",
+			expected: map[string]string{
+				"BuildRole":            validator.GetErrorMessage("invalidMaxSessionDuration"),
+				"BuildPolicy":          "PolicyDocument: " + validator.GetErrorMessage("wildcardResource"),
+				"ArtifactsTopicPolicy": "PolicyDocument: " + validator.GetErrorMessage("resourcePolicyPrincipal"),
+			},
+		},
+		{
+			path: "../fixtures/cloudformation/opaque_stack.yaml",
+			expected: map[string]string{
+				"ImagesRepository":  "",
+				"PublicApi":         "",
+				"DataKey":           "",
+				"SharedFileSystem":  "",
+				"ParameterizedRole": "",
+				"OverattachedRole":  validator.GetErrorMessage("tooManyManagedPolicies"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			template, err := cfn.LoadTemplate(test.path)
+			if err != nil {
+				t.Fatalf("Failed to load template: %v", err)
+			}
+			results := template.Validate()
+			if len(results) != len(test.expected) {
+				t.Fatalf("Expected %d results, got %v", len(test.expected), results)
+			}
+			for _, result := range results {
+				errMsg, ok := test.expected[result.LogicalID]
+				if !ok {
+					t.Errorf("Unexpected result for %s", result.LogicalID)
+				}
+				checkTestResult(t, result.LogicalID, errMsg == "", errMsg, result.Err == nil, result.Err)
+			}
+		})
+	}
+}
+
+func TestCloudFormationIntrinsics(t *testing.T) {
+	template, err := cfn.LoadTemplate("../fixtures/cloudformation/reports_stack.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+	var role cfn.Resource
+	for _, resource := range template.Resources {
+		if resource.LogicalID == "ReportWorkerRole" {
+			role = resource
+		}
+	}
+	if role.Line != 15 {
+		t.Errorf("Expected ReportWorkerRole on line 15, got %d", role.Line)
+	}
+
+	// Validating a template leaves its values as they were resolved.
+	template.Validate()
+	properties := role.Properties
+	expected := map[string]interface{}{
+		"RoleName":            cfn.Placeholder + "-worker",
+		"PermissionsBoundary": "arn:aws:iam::123456789012:policy/ProdBoundary",
+		"ManagedPolicyArns": []interface{}{
+			"arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole",
+			cfn.Placeholder,
+		},
+	}
+	for key, value := range expected {
+		if !reflect.DeepEqual(properties[key], value) {
+			t.Errorf("%s: expected %v, got %v", key, value, properties[key])
+		}
+	}
+
+	statement := properties["Policies"].([]interface{})[0].(map[string]interface{})["PolicyDocument"].(map[string]interface{})["Statement"].([]interface{})[0].(map[string]interface{})
+	if resource := statement["Resource"]; resource != cfn.Placeholder+"/reports/*" {
+		t.Errorf("Expected the bucket ARN to be a placeholder, got %v", resource)
+	}
+}
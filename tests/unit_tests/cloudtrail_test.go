@@ -0,0 +1,92 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cloudtrail"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"testing"
+	"time"
+)
+
+const reportReaderRole = "arn:aws:iam::123456789012:role/ReportReader"
+
+func TestActionForEvent(t *testing.T) {
+	tests := []struct {
+		eventSource string
+		eventName   string
+		expected    string
+		known       bool
+	}{
+		{"s3.amazonaws.com", "ListObjectsV2", "s3:ListBucket", true},
+		{"s3.amazonaws.com", "GetObject", "s3:GetObject", true},
+		{"lambda.amazonaws.com", "UpdateFunctionCode20150331v2", "lambda:UpdateFunctionCode", true},
+		{"ec2.amazonaws.com", "DescribeInstances", "ec2:DescribeInstances", false},
+	}
+
+	for _, test := range tests {
+		action, known := catalog.ActionForEvent(test.eventSource, test.eventName)
+		if action != test.expected || known != test.known {
+			t.Errorf("%s %s: expected %s (%v), got %s (%v)", test.eventSource, test.eventName, test.expected, test.known, action, known)
+		}
+	}
+}
+
+func TestGenerateFromCloudTrail(t *testing.T) {
+	events, err := cloudtrail.ReadEvents([]string{"../test_data/cloudtrail"})
+	if err != nil {
+		t.Fatalf("Failed to read CloudTrail logs: %v", err)
+	}
+
+	activity := cloudtrail.Observe(events, cloudtrail.Filter{RoleARN: reportReaderRole})
+	// The denied DeleteObject call and the call made by another role are not used.
+	if activity.Events != 3 {
+		t.Errorf("Expected 3 matching events, got %d", activity.Events)
+	}
+
+	policy, err := cloudtrail.Generate(activity, "ReportReader-least-privilege")
+	if err != nil {
+		t.Fatalf("Failed to generate policy: %v", err)
+	}
+	if err := validator.ValidateIAMPolicy(policy); err != nil {
+		t.Errorf("Generated policy is not valid: %v", err)
+	}
+
+	policies := []validator.IAMPolicy{policy}
+	tests := []struct {
+		action   string
+		resource string
+		expected simulator.Decision
+	}{
+		{"s3:GetObject", "arn:aws:s3:::examplebucket/reports/2026-08.csv", simulator.Allowed},
+		{"s3:ListBucket", "arn:aws:s3:::examplebucket", simulator.Allowed},
+		{"sqs:SendMessage", "arn:aws:sqs:us-east-1:123456789012:report-ready", simulator.Allowed},
+		{"s3:DeleteObject", "arn:aws:s3:::examplebucket/reports/2026-08.csv", simulator.ImplicitDeny},
+		{"s3:GetObject", "arn:aws:s3:::otherbucket/reports/2026-08.csv", simulator.ImplicitDeny},
+		{"dynamodb:PutItem", "arn:aws:dynamodb:us-east-1:123456789012:table/Reports", simulator.ImplicitDeny},
+	}
+	for _, test := range tests {
+		result := simulator.Evaluate(policies, simulator.Request{Action: test.action, Resource: test.resource})
+		if result.Decision != test.expected {
+			t.Errorf("%s on %s: expected %v, got %v", test.action, test.resource, test.expected, result.Decision)
+		}
+	}
+}
+
+func TestCloudTrailTimeWindow(t *testing.T) {
+	events, err := cloudtrail.ReadEvents([]string{"../test_data/cloudtrail"})
+	if err != nil {
+		t.Fatalf("Failed to read CloudTrail logs: %v", err)
+	}
+
+	since := time.Date(2026, 9, 2, 0, 0, 0, 0, time.UTC)
+	activity := cloudtrail.Observe(events, cloudtrail.Filter{RoleARN: reportReaderRole, Since: since})
+	if len(activity.Actions) != 1 || activity.Actions["sqs:SendMessage"] == nil {
+		t.Errorf("Expected only sqs:SendMessage after %s, got %v", since, activity.Actions)
+	}
+
+	activity = cloudtrail.Observe(events, cloudtrail.Filter{RoleARN: reportReaderRole, Until: since.AddDate(-1, 0, 0)})
+	if _, err := cloudtrail.Generate(activity, "Empty"); err == nil {
+		t.Errorf("Expected an error when no activity matches the filter")
+	}
+}
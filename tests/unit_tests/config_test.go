@@ -0,0 +1,143 @@
+package unit_tests
+
+import (
+	"bytes"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/api"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `rules:
+  enabled: [redundantStatement]
+  disabled: [wildcardResource]
+  severity:
+    invalidEffect: warning
+policyTypes:
+  - glob: buckets/**
+    type: resource
+allowedAccounts: ["123456789012"]
+server:
+  addr: ":9090"
+`
+
+func writeConfig(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, config.FileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFind(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "policies", "s3")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := config.Find(nested); err != nil || path != "" {
+		t.Fatalf("Expected no configuration, got %q (%v)", path, err)
+	}
+
+	expected := writeConfig(t, root, testConfig)
+	path, err := config.Find(nested)
+	if err != nil || path != expected {
+		t.Fatalf("Expected %s, got %q (%v)", expected, path, err)
+	}
+}
+
+func TestConfigLoad(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, t.TempDir(), testConfig))
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Server.Addr != ":9090" || cfg.Output.Format != "text" {
+		t.Errorf("Expected the settings of the file over the defaults, got %+v", cfg)
+	}
+	if cfg.RuleEnabled("wildcardResource") || cfg.RuleEnabled("shadowedStatement") || !cfg.RuleEnabled("redundantStatement") || !cfg.RuleEnabled("invalidEffect") {
+		t.Errorf("Unexpected enabled rules %+v", cfg.Rules)
+	}
+	if cfg.Severity("invalidEffect", lint.SeverityError) != lint.SeverityWarning || cfg.Severity("emptyAction", lint.SeverityError) != lint.SeverityError {
+		t.Errorf("Unexpected severities %+v", cfg.Rules.Severity)
+	}
+	if cfg.PolicyType(filepath.Join(cfg.Dir(), "buckets", "logs.json")) != "resource" || cfg.PolicyType(filepath.Join(cfg.Dir(), "roles.json")) != "" {
+		t.Errorf("Unexpected policy types")
+	}
+	if !cfg.AccountAllowed("123456789012") || cfg.AccountAllowed("210987654321") {
+		t.Errorf("Unexpected allowed accounts %v", cfg.AllowedAccounts)
+	}
+}
+
+func TestConfigLoadErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		errMsg  string
+	}{
+		{"rules:\n  disabled: [noSuchRule]\n", `unknown rule "noSuchRule"`},
+		{"rules:\n  enabled: [wildcardResource]\n", `unknown lint rule "wildcardResource"`},
+		{"rules:\n  severity:\n    wildcardResource: fatal\n", "severity must be error, warning or info"},
+		{"policyTypes:\n  - glob: '*.json'\n    type: bucket\n", "type must be one of"},
+		{"allowedAccounts: ['12345']\n", "not a 12-digit account ID"},
+		{"output:\n  format: xml\n", "output.format must be one of"},
+		{"servers:\n  addr: ':80'\n", "field servers not found"},
+	}
+
+	for _, test := range tests {
+		_, err := config.Load(writeConfig(t, t.TempDir(), test.content))
+		if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+			t.Errorf("Expected an error containing %q, got %v", test.errMsg, err)
+		}
+	}
+}
+
+func TestReportCheckerConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(writeConfig(t, dir, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := report.Checker{Config: cfg}
+
+	file := checker.Check(filepath.Join(dir, "reports.json"), []byte(twoInvalidStatements))
+	if len(file.Findings) != 1 || file.Findings[0].Rule != "invalidEffect" || file.Findings[0].Severity != lint.SeverityWarning || file.Failed() {
+		t.Errorf("Expected wildcardResource to be disabled and invalidEffect to be a warning, got %+v", file.Findings)
+	}
+
+	bucket, err := os.ReadFile("../fixtures/documents/bucket_policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket = bytes.Replace(bucket, []byte("123456789012"), []byte("210987654321"), 1)
+	file = checker.Check(filepath.Join(dir, "buckets", "examplebucket.json"), bucket)
+	rules := map[string]string{}
+	for _, finding := range file.Findings {
+		rules[finding.Rule] = finding.Path
+	}
+	if len(file.Findings) != 2 || rules["resourcePolicyPrincipal"] != "Statement[1]" || rules["unknownAccount"] != "Statement[0].Principal.AWS" {
+		t.Errorf("Expected the bucket policy to be checked as a resource policy, got %+v", file.Findings)
+	}
+}
+
+func TestAPIConfig(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, t.TempDir(), "server:\n  maxBodyBytes: 64\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(api.NewServer(cfg))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/validate", "application/json", strings.NewReader(twoInvalidStatements))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, response.StatusCode)
+	}
+}
@@ -0,0 +1,92 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/effective"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"testing"
+)
+
+func loadEffectivePolicy(t *testing.T, name string) validator.IAMPolicy {
+	policy, err := validator.DecodePolicyFile("../fixtures/effective/" + name)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", name, err)
+	}
+	return policy
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	boundary := loadEffectivePolicy(t, "permission_boundary.json")
+	set := effective.PolicySet{
+		Identity: []validator.IAMPolicy{loadEffectivePolicy(t, "inline_policy.json"), loadEffectivePolicy(t, "managed_policy.json")},
+		Boundary: &boundary,
+		SCPs: [][]validator.IAMPolicy{
+			{loadEffectivePolicy(t, "scp_root.json")},
+			{loadEffectivePolicy(t, "scp_workloads.json")},
+		},
+	}
+	result := effective.Compute(set)
+
+	expected := []string{
+		"iam:PassRole on arn:aws:iam::123456789012:role/report-worker (conditional)",
+		"s3:GetObject on arn:aws:s3:::examplebucket/*",
+		"s3:PutObject on arn:aws:s3:::examplebucket/* (partial)",
+		"sqs:SendMessage on arn:aws:sqs:us-east-1:123456789012:report-ready",
+	}
+	if len(result.Permissions) != len(expected) {
+		t.Fatalf("Expected %d permissions, got %v", len(expected), result.Permissions)
+	}
+	for i, permission := range result.Permissions {
+		if permission.String() != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], permission.String())
+		}
+	}
+
+	// kms:Decrypt is outside the boundary and s3:PutBucketPolicy is denied by an SCP.
+	for _, action := range []string{"kms:Decrypt", "s3:PutBucketPolicy"} {
+		if result.Allows(action) {
+			t.Errorf("Expected %s not to be allowed", action)
+		}
+	}
+	if len(result.Sensitive) != 1 || result.Sensitive[0].Action != "iam:PassRole" {
+		t.Errorf("Expected iam:PassRole to be the only sensitive grant, got %v", result.Sensitive)
+	}
+}
+
+func TestEffectivePermissionsWithoutGuardrails(t *testing.T) {
+	set := effective.PolicySet{
+		Identity: []validator.IAMPolicy{loadEffectivePolicy(t, "inline_policy.json"), loadEffectivePolicy(t, "managed_policy.json")},
+	}
+	result := effective.Compute(set)
+	for _, action := range []string{"kms:Decrypt", "s3:PutBucketPolicy", "iam:PassRole"} {
+		if !result.Allows(action) {
+			t.Errorf("Expected %s to be allowed", action)
+		}
+	}
+	if len(result.Sensitive) != 3 {
+		t.Errorf("Expected 3 sensitive grants, got %v", result.Sensitive)
+	}
+}
+
+func TestEffectivePermissionsSCPLevels(t *testing.T) {
+	identity := []validator.IAMPolicy{loadEffectivePolicy(t, "inline_policy.json")}
+	guardrails := loadEffectivePolicy(t, "scp_workloads.json")
+	// Without its Allow statement, the guardrails SCP allows nothing at its level, even with FullAWSAccess above it.
+	guardrails.PolicyDocument.Statement = guardrails.PolicyDocument.Statement[1:]
+
+	result := effective.Compute(effective.PolicySet{
+		Identity: identity,
+		SCPs:     [][]validator.IAMPolicy{{loadEffectivePolicy(t, "scp_root.json")}, {guardrails}},
+	})
+	if len(result.Permissions) != 0 {
+		t.Errorf("Expected no permissions, got %v", result.Permissions)
+	}
+
+	// Attached at the same level as FullAWSAccess, it only denies.
+	result = effective.Compute(effective.PolicySet{
+		Identity: identity,
+		SCPs:     [][]validator.IAMPolicy{{loadEffectivePolicy(t, "scp_root.json"), guardrails}},
+	})
+	if !result.Allows("s3:GetObject") || result.Allows("s3:PutBucketPolicy") {
+		t.Errorf("Expected s3:GetObject to be allowed and s3:PutBucketPolicy to be denied, got %v", result.Permissions)
+	}
+}
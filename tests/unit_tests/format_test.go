@@ -0,0 +1,126 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/format"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	input := []byte(`{"PolicyDocument": {"Statement": [{"Resource": ["arn:aws:s3:::bucket/*"],
+		"Action": ["s3:PutObject", "s3:GetObject", "S3:GETOBJECT"], "Effect": "Allow"}], "Version": "2012-10-17"},
+		"PolicyName": "Unformatted"}`)
+
+	tests := []struct {
+		name     string
+		options  format.Options
+		expected string
+	}{
+		{
+			name:    "Compact Lists",
+			options: format.DefaultOptions,
+			expected: `{
+  "PolicyName": "Unformatted",
+  "PolicyDocument": {
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Effect": "Allow",
+        "Action": [
+          "s3:GetObject",
+          "s3:PutObject"
+        ],
+        "Resource": "arn:aws:s3:::bucket/*"
+      }
+    ]
+  }
+}
+`,
+		},
+		{
+			name:    "Expanded Lists",
+			options: format.Options{ListStyle: format.Expanded, Indent: "    "},
+			expected: `{
+    "PolicyName": "Unformatted",
+    "PolicyDocument": {
+        "Version": "2012-10-17",
+        "Statement": [
+            {
+                "Effect": "Allow",
+                "Action": [
+                    "s3:GetObject",
+                    "s3:PutObject"
+                ],
+                "Resource": [
+                    "arn:aws:s3:::bucket/*"
+                ]
+            }
+        ]
+    }
+}
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted, err := format.Format(input, tc.options)
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tc.name, err)
+			}
+			if string(formatted) != tc.expected {
+				t.Errorf("%s: expected\n%s\ngot\n%s", tc.name, tc.expected, formatted)
+			}
+			if ok, err := format.IsFormatted(formatted, tc.options); err != nil || !ok {
+				t.Errorf("%s: formatted output is not stable (err: %v)", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../test_data/valid_format/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to list test files: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", file, err)
+			}
+			formatted, err := format.Format(data, format.DefaultOptions)
+			if err != nil {
+				t.Fatalf("Failed to format %s: %v", file, err)
+			}
+
+			original, _ := validator.DecodePolicy(data)
+			roundTripped, err := validator.DecodePolicy(formatted)
+			if err != nil {
+				t.Fatalf("Formatted %s does not decode: %v", file, err)
+			}
+			if err := validator.ValidateIAMPolicy(roundTripped); err != nil {
+				t.Errorf("Formatted %s is no longer valid: %v", file, err)
+			}
+			if original.PolicyName != roundTripped.PolicyName ||
+				len(original.PolicyDocument.Statement) != len(roundTripped.PolicyDocument.Statement) ||
+				!reflect.DeepEqual(original.PolicyDocument.Statement[0].Conditions, roundTripped.PolicyDocument.Statement[0].Conditions) {
+				t.Errorf("Formatted %s differs from the original", file)
+			}
+		})
+	}
+}
+
+func TestFormatRejectsYAML(t *testing.T) {
+	data, err := ioutil.ReadFile("../test_data/yaml/valid_policy.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	if _, err := format.Format(data, format.DefaultOptions); err != format.ErrYAML {
+		t.Errorf("Expected formatting a YAML policy to fail with %v, got %v", format.ErrYAML, err)
+	}
+}
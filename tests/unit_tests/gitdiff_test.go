@@ -0,0 +1,163 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/gitdiff"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gitRepository creates a repository with a commit on main, and changes its working directory into it.
+func gitRepository(t *testing.T, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	runGit(t, "init", "-q", "-b", "main")
+	writeFiles(t, files)
+	runGit(t, "add", "-A")
+	runGit(t, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, args ...string) {
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) {
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGitChanges(t *testing.T) {
+	lines := "1\n2\n3\n4\n5\n"
+	gitRepository(t, map[string]string{
+		"policies/changed.json":   lines,
+		"policies/unchanged.json": lines,
+		"policies/deleted.json":   lines,
+		"README.md":               lines,
+	})
+	runGit(t, "checkout", "-q", "-b", "feature")
+	writeFiles(t, map[string]string{
+		"policies/changed.json": "1\ntwo\n3\n4\nfive\n+++ six\n",
+		"policies/new.json":     "{}\n",
+	})
+	if err := os.Remove("policies/deleted.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := gitdiff.Since("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"policies/changed.json", "policies/new.json"}
+	if !reflect.DeepEqual(changes.Files, expected) {
+		t.Errorf("Expected changed files %v, got %v", expected, changes.Files)
+	}
+	for line, changed := range map[int]bool{1: false, 2: true, 3: false, 5: true, 6: true} {
+		if changes.Changed("policies/changed.json", line, line) != changed {
+			t.Errorf("Expected line %d changed to be %v", line, changed)
+		}
+	}
+	if changes.Changed("policies/changed.json", 3, 4) || !changes.Changed("policies/changed.json", 3, 5) {
+		t.Errorf("Expected ranges to be changed when one of their lines is")
+	}
+	if !changes.Changed("policies/new.json", 1, 1) || changes.Changed("policies/unchanged.json", 1, 5) {
+		t.Errorf("Expected new files to be changed and unchanged files not")
+	}
+
+	// Paths are relative to the working directory, and changes outside of it are left out.
+	if err := os.Chdir("policies"); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err = gitdiff.Since("main"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"changed.json", "new.json"}; !reflect.DeepEqual(changes.Files, expected) {
+		t.Errorf("Expected changed files %v, got %v", expected, changes.Files)
+	}
+
+	if _, err := gitdiff.Since("missing"); err == nil || !strings.Contains(err.Error(), "git merge-base") {
+		t.Errorf("Expected an error for an unknown ref, got %v", err)
+	}
+}
+
+func TestReportCheckTemplate(t *testing.T) {
+	path := "../fixtures/cloudformation/invalid_stack.json"
+	if file := report.CheckFile(path); len(file.Findings) != 1 || file.Findings[0].Rule != report.RuleInvalidFormat {
+		t.Errorf("Expected a template to be an invalid policy unless templates are checked, got %v", file.Findings)
+	}
+
+	file := report.Checker{Templates: true}.CheckFile(path)
+	expected := []report.Finding{
+		{Rule: "invalidMaxSessionDuration", Path: "Resources.BuildRole", Line: 4, Column: 5},
+		{Rule: "wildcardResource", Path: "Resources.BuildPolicy", Line: 20, Column: 5},
+		{Rule: "resourcePolicyPrincipal", Path: "Resources.ArtifactsTopicPolicy", Line: 37, Column: 5},
+	}
+	if len(file.Findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %v", len(expected), file.Findings)
+	}
+	for i, finding := range file.Findings {
+		if finding.Rule != expected[i].Rule || finding.Path != expected[i].Path || finding.Line != expected[i].Line ||
+			finding.Column != expected[i].Column || finding.EndLine <= finding.Line {
+			t.Errorf("Expected %+v, got %+v", expected[i], finding)
+		}
+	}
+}
+
+func TestGitStaged(t *testing.T) {
+	gitRepository(t, map[string]string{"policies/policy.json": twoInvalidStatements})
+	fixed := strings.Replace(twoInvalidStatements, `"Permit"`, `"Allow"`, 1)
+	writeFiles(t, map[string]string{"policies/policy.json": fixed, "policies/other.json": "{}"})
+	runGit(t, "add", "policies/policy.json")
+	// The working tree changes again after staging, the index keeps the fixed policy.
+	writeFiles(t, map[string]string{"policies/policy.json": twoInvalidStatements})
+
+	staged, err := gitdiff.Staged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"policies/policy.json"}; !reflect.DeepEqual(staged, expected) {
+		t.Errorf("Expected staged files %v, got %v", expected, staged)
+	}
+	data, err := gitdiff.StagedContent("policies/policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := report.Check("policies/policy.json", data)
+	if len(file.Findings) != 1 || file.Findings[0].Rule != "wildcardResource" {
+		t.Errorf("Expected the staged policy to only have a wildcard resource, got %v", file.Findings)
+	}
+	if _, err := gitdiff.StagedContent("policies/other.json"); err == nil {
+		t.Errorf("Expected an error for a file that is not in the index")
+	}
+
+	dir, err := gitdiff.HooksDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(".git", "hooks") {
+		t.Errorf("Expected the hooks in .git/hooks, got %s", dir)
+	}
+}
@@ -0,0 +1,194 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPatternCovers(t *testing.T) {
+	tests := []struct {
+		outer    string
+		inner    string
+		expected bool
+	}{
+		{"s3:*", "s3:GetObject", true},
+		{"s3:Get*", "s3:GetObject*", true},
+		{"s3:GetObject", "s3:Get*", false},
+		{"arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket/reports/*", true},
+		{"arn:aws:s3:::bucket/?", "arn:aws:s3:::bucket/*", false},
+		{"*", "anything", true},
+	}
+
+	for _, tc := range tests {
+		if got := simulator.PatternCovers(tc.outer, tc.inner); got != tc.expected {
+			t.Errorf("PatternCovers(%q, %q): expected %v, got %v", tc.outer, tc.inner, tc.expected, got)
+		}
+	}
+}
+
+func TestLintFindings(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/lint/overlapping_statements.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+
+	expected := []lint.Finding{
+		{Rule: "redundantStatement", Path: "PolicyDocument.Statement[1]", Related: "PolicyDocument.Statement[0]"},
+		{Rule: "duplicateEntry", Path: "PolicyDocument.Statement[1].Action[1]", Related: "PolicyDocument.Statement[1].Action[0]"},
+		{Rule: "shadowedStatement", Path: "PolicyDocument.Statement[2]", Related: "PolicyDocument.Statement[3]"},
+	}
+
+	findings := lint.Lint(policy)
+	if len(findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d: %v", len(expected), len(findings), findings)
+	}
+	for i, finding := range findings {
+		want := expected[i]
+		if finding.Rule != want.Rule || finding.Path != want.Path || finding.Related != want.Related {
+			t.Errorf("Finding %d: expected %s at %s (related %s), got %s at %s (related %s)",
+				i, want.Rule, want.Path, want.Related, finding.Rule, finding.Path, finding.Related)
+		}
+	}
+}
+
+func TestLintValidPolicyHasNoFindings(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/valid_format/valid_policy_2.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+	if findings := lint.Lint(policy); len(findings) != 0 {
+		t.Errorf("Expected no findings, got %v", findings)
+	}
+}
+
+// mixedActions lists an action twice around an item that is not a string.
+const mixedActions = `{
+  "PolicyName": "MixedActions",
+  "PolicyDocument": {
+    "Version": "2012-10-17",
+    "Statement": [
+      {"Effect": "Allow", "Action": ["s3:GetObject", 5, "s3:GetObject"], "Resource": "arn:aws:s3:::examplebucket/*"}
+    ]
+  }
+}`
+
+func TestLintDuplicatesNextToOtherItems(t *testing.T) {
+	policy, err := validator.DecodePolicy([]byte(mixedActions))
+	if err != nil {
+		t.Fatalf("Failed to decode policy: %v", err)
+	}
+	findings := lint.Lint(policy)
+	if len(findings) != 1 || findings[0].Path != "PolicyDocument.Statement[0].Action[2]" || findings[0].Related != "PolicyDocument.Statement[0].Action[0]" {
+		t.Errorf("Expected the duplicate at Action[2], got %v", findings)
+	}
+}
+
+func TestFixDuplicatesNextToOtherItems(t *testing.T) {
+	fixed, findings, err := lint.FixSource([]byte(mixedActions), nil)
+	if err != nil {
+		t.Fatalf("Failed to fix source: %v", err)
+	}
+	if len(findings) != 1 || !strings.Contains(string(fixed), `"Action": ["s3:GetObject", 5]`) {
+		t.Errorf("Expected the second s3:GetObject to be removed, got %v\n%s", findings, fixed)
+	}
+
+	// A finding whose path does not point at the duplicated value is not fixed.
+	doc, err := lint.NewDocument([]byte(mixedActions))
+	if err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	wrong := lint.Finding{Rule: "duplicateEntry", Path: "PolicyDocument.Statement[0].Action[1]", Related: "PolicyDocument.Statement[0].Action[0]"}
+	if edits := lint.Fixes(doc, wrong); edits != nil {
+		t.Errorf("Expected no fix for an element other than the duplicate, got %v", edits)
+	}
+}
+
+func TestFixSource(t *testing.T) {
+	data, err := ioutil.ReadFile("../test_data/fixable/mechanical_fixes.json")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	fixed, findings, err := lint.FixSource(data, nil)
+	if err != nil {
+		t.Fatalf("Failed to fix source: %v", err)
+	}
+
+	fixedRules := map[string]bool{}
+	for _, finding := range findings {
+		fixedRules[finding.Rule] = true
+	}
+	for _, rule := range []string{"conditionsKey", "elementNameCase", "effectCase", "legacyVersion", "duplicateEntry"} {
+		if !fixedRules[rule] {
+			t.Errorf("Expected a fix for %s", rule)
+		}
+	}
+
+	policy, err := validator.DecodePolicy(fixed)
+	if err != nil {
+		t.Fatalf("Fixed policy does not decode: %v\n%s", err, fixed)
+	}
+	if err := validator.ValidateIAMPolicy(policy); err != nil {
+		t.Errorf("Fixed policy is not valid: %v", err)
+	}
+	if !strings.Contains(string(fixed), "        \"Resource\": [\"arn:aws:s3:::examplebucket/*\"],\n") {
+		t.Errorf("Expected the original formatting to be preserved, got\n%s", fixed)
+	}
+
+	doc, err := lint.NewDocument(fixed)
+	if err != nil {
+		t.Fatalf("Failed to parse fixed policy: %v", err)
+	}
+	if remaining := lint.LintDocument(doc); len(remaining) != 0 {
+		t.Errorf("Expected no findings after fixing, got %v", remaining)
+	}
+}
+
+func TestFixSourceSkipsDisabledRules(t *testing.T) {
+	data, err := ioutil.ReadFile("../test_data/fixable/mechanical_fixes.json")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+
+	enabled := func(rule string) bool { return rule != "legacyVersion" }
+	fixed, findings, err := lint.FixSource(data, enabled)
+	if err != nil {
+		t.Fatalf("Failed to fix source: %v", err)
+	}
+	for _, finding := range findings {
+		if finding.Rule == "legacyVersion" {
+			t.Errorf("Expected no fix for the disabled rule, got %v", finding)
+		}
+	}
+	if !strings.Contains(string(fixed), `"Version": "2008-10-17"`) {
+		t.Errorf("Expected the version to be left as it was, got\n%s", fixed)
+	}
+}
+
+func TestLintYAML(t *testing.T) {
+	data, err := ioutil.ReadFile("../test_data/yaml/valid_policy.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read test file: %v", err)
+	}
+	// A duplicated action is fixable in JSON, but the fix would edit the JSON form of the YAML file.
+	data = []byte(strings.Replace(string(data), "- ec2:StopInstances", "- ec2:StopInstances\n        - ec2:StopInstances", 1))
+
+	doc, err := lint.NewDocument(data)
+	if err != nil {
+		t.Fatalf("Failed to parse YAML policy: %v", err)
+	}
+	findings := lint.LintDocument(doc)
+	if len(findings) != 1 || findings[0].Rule != "duplicateEntry" {
+		t.Fatalf("Expected the duplicated action to be reported, got %v", findings)
+	}
+	if lint.Fixable(doc, findings[0]) {
+		t.Errorf("Expected no fix to be offered for a YAML policy")
+	}
+	if _, _, err := lint.FixSource(data, nil); err != lint.ErrYAML {
+		t.Errorf("Expected fixing a YAML policy to fail with %v, got %v", lint.ErrYAML, err)
+	}
+}
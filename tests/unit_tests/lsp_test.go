@@ -0,0 +1,322 @@
+package unit_tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/config"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lsp"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lspClient talks to a language server running in the test, as an editor would.
+type lspClient struct {
+	t      *testing.T
+	in     io.Writer
+	out    chan lspMessage
+	nextID int
+	// notifications holds the notifications received while waiting for responses.
+	notifications []lspMessage
+	done          chan error
+}
+
+type lspMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+func startLSP(t *testing.T) *lspClient {
+	return startLSPWith(t, nil)
+}
+
+// startLSPWith starts a server using cfg for every document, or the configuration found from each one when nil.
+func startLSPWith(t *testing.T, cfg *config.Config) *lspClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	client := &lspClient{t: t, in: clientOut, out: make(chan lspMessage, 100), done: make(chan error, 1)}
+	go func() {
+		server := lsp.NewServer(serverIn, serverOut)
+		server.Config = cfg
+		err := server.Run()
+		serverOut.Close()
+		client.done <- err
+	}()
+	// Messages are read as they come, as the server blocks on writing until they are.
+	go func() {
+		defer close(client.out)
+		reader := bufio.NewReader(clientIn)
+		for {
+			header, err := textproto.NewReader(reader).ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			length, err := strconv.Atoi(header.Get("Content-Length"))
+			if err != nil {
+				return
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(reader, body); err != nil {
+				return
+			}
+			var message lspMessage
+			if err := json.Unmarshal(body, &message); err != nil {
+				return
+			}
+			client.out <- message
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return client
+}
+
+func (c *lspClient) send(id *int, method string, params interface{}) {
+	message := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != nil {
+		message["id"] = *id
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *lspClient) read() lspMessage {
+	select {
+	case message, ok := <-c.out:
+		if !ok {
+			c.t.Fatal("The server closed the connection")
+		}
+		return message
+	case <-time.After(10 * time.Second):
+		c.t.Fatal("Timed out waiting for the server")
+	}
+	return lspMessage{}
+}
+
+// request sends a request and decodes the result of its response into result.
+func (c *lspClient) request(method string, params, result interface{}) {
+	c.nextID++
+	id := c.nextID
+	c.send(&id, method, params)
+	for {
+		message := c.read()
+		if message.ID == nil {
+			c.notifications = append(c.notifications, message)
+			continue
+		}
+		if *message.ID != id {
+			c.t.Fatalf("Expected the response to request %d, got %d", id, *message.ID)
+		}
+		if message.Error != nil {
+			c.t.Fatalf("%s failed with code %d", method, message.Error.Code)
+		}
+		if result != nil {
+			if err := json.Unmarshal(message.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *lspClient) notify(method string, params interface{}) {
+	c.send(nil, method, params)
+}
+
+// diagnostics waits for the diagnostics of a document.
+func (c *lspClient) diagnostics(uri string) []lsp.Diagnostic {
+	for {
+		var message lspMessage
+		if len(c.notifications) > 0 {
+			message, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			message = c.read()
+		}
+		var params lsp.PublishDiagnosticsParams
+		if message.Method == "textDocument/publishDiagnostics" && json.Unmarshal(message.Params, &params) == nil && params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *lspClient) open(uri, text string) {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "json", "version": 1, "text": text},
+	})
+}
+
+func positionParams(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+func TestLSP(t *testing.T) {
+	client := startLSP(t)
+	dir := t.TempDir()
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "policy.json"))
+
+	var initialize struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	client.request("initialize", map[string]interface{}{"processId": nil, "rootUri": nil, "capabilities": map[string]interface{}{}}, &initialize)
+	if initialize.Capabilities["hoverProvider"] != true || initialize.Capabilities["completionProvider"] == nil {
+		t.Errorf("Expected hover and completion capabilities, got %v", initialize.Capabilities)
+	}
+	client.notify("initialized", map[string]interface{}{})
+
+	client.open(uri, twoInvalidStatements)
+	diagnostics := client.diagnostics(uri)
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %v", diagnostics)
+	}
+	expected := lsp.Range{Start: lsp.Position{Line: 5, Character: 17}, End: lsp.Position{Line: 5, Character: 25}}
+	if diagnostics[0].Code != "invalidEffect" || diagnostics[0].Severity != 1 || diagnostics[0].Range != expected {
+		t.Errorf("Expected an invalidEffect error on \"Permit\", got %+v", diagnostics[0])
+	}
+
+	// Fixing the effect removes its diagnostic.
+	client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": strings.Replace(twoInvalidStatements, "Permit", "Allow", 1)}},
+	})
+	if diagnostics := client.diagnostics(uri); len(diagnostics) != 1 || diagnostics[0].Code != "wildcardResource" {
+		t.Errorf("Expected only the wildcardResource diagnostic after the change, got %v", diagnostics)
+	}
+
+	var hover lsp.Hover
+	client.request("textDocument/hover", positionParams(uri, 5, 45), &hover)
+	if !strings.Contains(hover.Contents.Value, "s3:GetObject") || !strings.Contains(hover.Contents.Value, "Read") {
+		t.Errorf("Expected the documentation of s3:GetObject, got %q", hover.Contents.Value)
+	}
+
+	client.request("shutdown", nil, nil)
+	client.notify("exit", nil)
+	if err := <-client.done; err != nil {
+		t.Errorf("Expected the server to stop cleanly, got %v", err)
+	}
+}
+
+func TestLSPCompletion(t *testing.T) {
+	tests := []struct {
+		name, text string
+		expected   string
+		unexpected string
+	}{
+		{"JSON action", `{"PolicyDocument": {"Statement": [{"Effect": "Allow", "Action": ["s3:GetOb`, "s3:GetObject", "s3:PutObject"},
+		{"JSON effect", `{"PolicyDocument": {"Statement": [{"Effect": "D`, "Deny", "Allow"},
+		{"JSON operator", `{"PolicyDocument": {"Statement": [{"Condition": {"StringL`, "StringLikeIfExists", "s3:prefix"},
+		{"JSON condition key", `{"PolicyDocument": {"Statement": [{"Condition": {"StringEquals": {"aws:Source`, "aws:SourceIp", "StringEquals"},
+		{"YAML action item", "PolicyDocument:\n  Statement:\n    - Effect: Allow\n      Action:\n      - s3:", "s3:GetObject", "sqs:GetQueueUrl"},
+		{"YAML action value", "PolicyDocument:\n  Statement:\n  - Action: sqs:Get", "sqs:GetQueueUrl", "s3:GetObject"},
+		{"YAML condition key", "Statement:\n  - Effect: Allow\n    Condition:\n      StringEquals:\n        s3:pre", "s3:prefix", "StringEquals"},
+		{"YAML key", "Statement:\n  - Effect: Allow\n    Condition:\n      Bo", "Bool", "aws:SourceIp"},
+	}
+
+	client := startLSP(t)
+	client.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	for i, test := range tests {
+		uri := fmt.Sprintf("file:///completion/%d.json", i)
+		client.open(uri, test.text)
+		lines := strings.Split(test.text, "\n")
+		var list lsp.CompletionList
+		client.request("textDocument/completion", positionParams(uri, len(lines)-1, len(lines[len(lines)-1])), &list)
+
+		labels := map[string]bool{}
+		for _, item := range list.Items {
+			labels[item.Label] = true
+		}
+		if !labels[test.expected] || labels[test.unexpected] {
+			t.Errorf("%s: expected %s and not %s among %d items", test.name, test.expected, test.unexpected, len(list.Items))
+		}
+	}
+}
+
+func TestLSPCodeActions(t *testing.T) {
+	path := "../test_data/fixable/mechanical_fixes.json"
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, _, err := lint.FixSource(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := startLSP(t)
+	client.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	uri := "file:///fixable/mechanical_fixes.json"
+	client.open(uri, string(source))
+
+	var actions []lsp.CodeAction
+	client.request("textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"range":        lsp.Range{Start: lsp.Position{Line: 6}, End: lsp.Position{Line: 6, Character: 10}},
+		"context":      map[string]interface{}{"diagnostics": []lsp.Diagnostic{}},
+	}, &actions)
+
+	quickFixes, fixAll := 0, ""
+	for _, action := range actions {
+		switch action.Kind {
+		case "quickfix":
+			quickFixes++
+			if edits := action.Edit.Changes[uri]; len(edits) != 1 || edits[0].Range.Start.Line != 6 {
+				t.Errorf("Expected %q to edit line 7, got %v", action.Title, edits)
+			}
+		case "source.fixAll":
+			fixAll = action.Edit.Changes[uri][0].NewText
+		}
+	}
+	// Line 7 holds "effect": "allow", whose key and value are fixed separately.
+	if quickFixes != 2 {
+		t.Errorf("Expected 2 quick fixes on line 7, got %v", actions)
+	}
+	if fixAll != string(fixed) {
+		t.Errorf("Expected the fix-all action to apply every fix, got:\n%s", fixAll)
+	}
+}
+
+func TestLSPFixAllSkipsDisabledRules(t *testing.T) {
+	source, err := os.ReadFile("../test_data/fixable/mechanical_fixes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Rules.Disabled = []string{"legacyVersion"}
+
+	client := startLSPWith(t, cfg)
+	client.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	uri := "file:///fixable/mechanical_fixes.json"
+	client.open(uri, string(source))
+
+	var actions []lsp.CodeAction
+	client.request("textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"range":        lsp.Range{},
+		"context":      map[string]interface{}{"diagnostics": []lsp.Diagnostic{}, "only": []string{"source.fixAll"}},
+	}, &actions)
+
+	if len(actions) != 1 {
+		t.Fatalf("Expected a fix-all action, got %v", actions)
+	}
+	if fixAll := actions[0].Edit.Changes[uri][0].NewText; !strings.Contains(fixAll, `"Version": "2008-10-17"`) {
+		t.Errorf("Expected the fix-all action to leave the version of the disabled rule, got:\n%s", fixAll)
+	}
+}
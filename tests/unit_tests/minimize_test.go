@@ -0,0 +1,85 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/catalog"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/minimize"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"testing"
+)
+
+func TestCatalogExpand(t *testing.T) {
+	actions, ok := catalog.Expand("sqs:Get*")
+	if !ok {
+		t.Fatalf("Expected sqs to be in the catalog")
+	}
+	if len(actions) != 2 || actions[0] != "sqs:GetQueueAttributes" || actions[1] != "sqs:GetQueueUrl" {
+		t.Errorf("Expected sqs:GetQueueAttributes and sqs:GetQueueUrl, got %v", actions)
+	}
+
+	if _, ok := catalog.Expand("ec2:Describe*"); ok {
+		t.Errorf("Expected ec2 not to be in the catalog")
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/minimize/generated_policy.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+
+	minimized := minimize.Minimize(policy, minimize.Options{})
+	if err := validator.ValidateIAMPolicy(minimized); err != nil {
+		t.Errorf("Minimized policy is not valid: %v", err)
+	}
+	if err := minimize.Verify(policy, minimized); err != nil {
+		t.Errorf("Minimized policy is not equivalent: %v", err)
+	}
+	if got := len(minimized.PolicyDocument.Statement); got != 3 {
+		t.Errorf("Expected 3 statements, got %d", got)
+	}
+	if minimize.Size(minimized) >= minimize.Size(policy) {
+		t.Errorf("Expected minimized policy to be smaller, got %d >= %d", minimize.Size(minimized), minimize.Size(policy))
+	}
+
+	readBucket := minimized.PolicyDocument.Statement[2]
+	if resources := validator.StringValues(readBucket.Resource); len(resources) != 1 {
+		t.Errorf("Expected covered resources to be removed, got %v", resources)
+	}
+}
+
+func TestVerifyDetectsDifferences(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/minimize/generated_policy.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+
+	changed := minimize.Minimize(policy, minimize.Options{})
+	changed.PolicyDocument.Statement = changed.PolicyDocument.Statement[1:]
+	if err := minimize.Verify(policy, changed); err == nil {
+		t.Errorf("Expected Verify to report the removed statement")
+	}
+}
+
+func TestMinimizeWildcards(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/minimize/generated_policy.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+
+	hasWildcard := func(policy validator.IAMPolicy) bool {
+		for _, statement := range policy.PolicyDocument.Statement {
+			for _, action := range validator.StringValues(statement.Action) {
+				if action == "sqs:Get*" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if hasWildcard(minimize.Minimize(policy, minimize.Options{})) {
+		t.Errorf("Expected the actions not to be replaced with wildcards by default")
+	}
+	if !hasWildcard(minimize.Minimize(policy, minimize.Options{Wildcards: true})) {
+		t.Errorf("Expected sqs:GetQueueAttributes and sqs:GetQueueUrl to be replaced with sqs:Get*")
+	}
+}
@@ -0,0 +1,398 @@
+package unit_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/lint"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const twoInvalidStatements = `{
+  "PolicyName": "Reports",
+  "PolicyDocument": {
+    "Version": "2012-10-17",
+    "Statement": [
+      {"Effect": "Permit", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::examplebucket/*"},
+      {"Effect": "Allow", "Action": "s3:ListBucket", "Resource": ["arn:aws:s3:::examplebucket", "*"]}
+    ]
+  }
+}`
+
+func TestReportCheck(t *testing.T) {
+	file := report.Check("policy.json", []byte(twoInvalidStatements))
+
+	expected := []report.Finding{
+		{Rule: "invalidEffect", Path: "PolicyDocument.Statement[0].Effect", Line: 6, Column: 18},
+		{Rule: "wildcardResource", Path: "PolicyDocument.Statement[1].Resource[1]", Line: 7, Column: 97},
+	}
+	if len(file.Findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %v", len(expected), file.Findings)
+	}
+	for i, finding := range file.Findings {
+		want := expected[i]
+		if finding.Rule != want.Rule || finding.Path != want.Path || finding.Line != want.Line || finding.Column != want.Column {
+			t.Errorf("Expected %s at %s %d:%d, got %s at %s %d:%d", want.Rule, want.Path, want.Line, want.Column,
+				finding.Rule, finding.Path, finding.Line, finding.Column)
+		}
+	}
+	if !file.Failed() {
+		t.Errorf("Expected the file to fail")
+	}
+}
+
+func TestReportCheckDecodingErrors(t *testing.T) {
+	tests := []struct {
+		path string
+		rule string
+		line int
+	}{
+		{"../test_data/yaml/duplicate_key.yaml", "duplicateKey", 8},
+		{"../test_data/yaml/unknown_field.yaml", report.RuleInvalidFormat, 8},
+		{"../test_data/invalid_format/truncated_json.json", report.RuleInvalidFormat, 9},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			file := report.CheckFile(test.path)
+			if len(file.Findings) != 1 {
+				t.Fatalf("Expected 1 finding, got %v", file.Findings)
+			}
+			if finding := file.Findings[0]; finding.Rule != test.rule || finding.Line != test.line {
+				t.Errorf("Expected %s on line %d, got %v", test.rule, test.line, finding)
+			}
+		})
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	files := []report.File{
+		report.Check("policies/reports.json", []byte(twoInvalidStatements)),
+		report.CheckFile("../test_data/valid_format/valid_policy_2.json"),
+		// A rule missing from the catalog, which must not point at another rule.
+		{Path: "policies/other.json", Findings: []report.Finding{{Rule: "customRule", Severity: "warning", Message: "Custom"}}},
+	}
+	var buffer bytes.Buffer
+	if err := report.WriteSARIF(&buffer, files); err != nil {
+		t.Fatalf("Failed to write SARIF: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID              string            `json:"ruleId"`
+				RuleIndex           *int              `json:"ruleIndex"`
+				Level               string            `json:"level"`
+				PartialFingerprints map[string]string `json:"partialFingerprints"`
+				Locations           []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &log); err != nil {
+		t.Fatalf("Invalid SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a SARIF 2.1.0 log with one run, got version %q and %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(validator.ErrorKeys()) {
+		t.Errorf("Expected a rule per catalog message, got %d", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(run.Results))
+	}
+	if custom := run.Results[2]; custom.RuleID != "customRule" || custom.RuleIndex != nil {
+		t.Errorf("Expected no rule index for a rule missing from the catalog, got %+v", custom)
+	}
+	for _, result := range run.Results[:2] {
+		if result.RuleIndex == nil || run.Tool.Driver.Rules[*result.RuleIndex].ID != result.RuleID {
+			t.Errorf("Result %s does not point at its rule", result.RuleID)
+		}
+		if result.Level != "error" || result.Locations[0].PhysicalLocation.ArtifactLocation.URI != "policies/reports.json" {
+			t.Errorf("Unexpected result %+v", result)
+		}
+		if len(result.PartialFingerprints) != 1 {
+			t.Errorf("Expected a fingerprint, got %v", result.PartialFingerprints)
+		}
+	}
+}
+
+func TestFingerprintIgnoresLines(t *testing.T) {
+	before := report.Check("policy.json", []byte(twoInvalidStatements))
+	after := report.Check("./policy.json", []byte("\n\n"+twoInvalidStatements))
+	for i := range before.Findings {
+		if before.Findings[i].Line == after.Findings[i].Line {
+			t.Fatalf("Expected the findings to move")
+		}
+		if report.Fingerprint(before.Path, before.Findings[i]) != report.Fingerprint(after.Path, after.Findings[i]) {
+			t.Errorf("Expected the fingerprint of %s to stay the same", before.Findings[i].Rule)
+		}
+	}
+}
+
+func TestFingerprintIgnoresStatementOrder(t *testing.T) {
+	statements := []string{
+		`{"Effect": "Permit", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::examplebucket/*"}`,
+		`{"Sid": "List", "Effect": "Allow", "Action": ["s3:ListBucket", "s3:ListBucket"], "Resource": "*"}`,
+	}
+	policy := func(statements ...string) []byte {
+		return []byte(`{"PolicyName": "Reports", "PolicyDocument": {"Version": "2012-10-17", "Statement": [` +
+			strings.Join(statements, ",") + `]}}`)
+	}
+	fingerprints := func(file report.File) map[string]string {
+		result := map[string]string{}
+		for _, finding := range file.Findings {
+			result[report.Fingerprint(file.Path, finding)] = finding.Rule
+		}
+		return result
+	}
+
+	before := fingerprints(report.Check("policy.json", policy(statements...)))
+	inserted := `{"Effect": "Allow", "Action": "sqs:GetQueueUrl", "Resource": "arn:aws:sqs:us-east-1:123456789012:orders"}`
+	after := fingerprints(report.Check("policy.json", policy(inserted, statements[0], statements[1])))
+	if len(before) != 3 || !reflect.DeepEqual(before, after) {
+		t.Errorf("Expected the fingerprints to stay the same when statements move, got %v and %v", before, after)
+	}
+
+	// Statements without a Sid are identified by their content.
+	changed := fingerprints(report.Check("policy.json", policy(strings.Replace(statements[0], "GetObject", "PutObject", 1), statements[1])))
+	if reflect.DeepEqual(before, changed) {
+		t.Errorf("Expected the fingerprints of a changed statement to change")
+	}
+}
+
+func TestReportColumnsCountCharacters(t *testing.T) {
+	policy := strings.Replace(twoInvalidStatements, `{"Effect": "Permit"`, `{"Sid": "Zażółć", "Effect": "Permit"`, 1)
+	file := report.Check("policy.json", []byte(policy))
+	// SARIF columns count Unicode code points: the four non-ASCII letters of the Sid count once each.
+	if len(file.Findings) == 0 || file.Findings[0].Rule != "invalidEffect" || file.Findings[0].Column != 35 {
+		t.Errorf("Expected invalidEffect at column 35, got %v", file.Findings)
+	}
+}
+
+func TestFileFailsOn(t *testing.T) {
+	file := report.File{Findings: []report.Finding{{Rule: "redundantStatement", Severity: lint.SeverityWarning}}}
+	if !file.FailsOn(lint.SeverityInfo) || !file.FailsOn(lint.SeverityWarning) || file.FailsOn(lint.SeverityError) || file.Failed() {
+		t.Errorf("Expected a warning to fail on info and warning only")
+	}
+	unreadable := report.File{Err: errors.New("permission denied")}
+	if !unreadable.FailsOn(lint.SeverityError) {
+		t.Errorf("Expected an unreadable file to fail")
+	}
+}
+
+func TestReportLint(t *testing.T) {
+	data, err := ioutil.ReadFile("../test_data/lint/overlapping_statements.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := report.Check("overlapping_statements.json", data)
+	linted := report.Checker{}.Lint("overlapping_statements.json", data)
+	if len(linted.Findings) != 3 {
+		t.Fatalf("Expected the 3 lint findings, got %v", linted.Findings)
+	}
+	// The lint findings are located and fingerprinted as validate reports them.
+	for _, finding := range linted.Findings {
+		found := false
+		for _, other := range checked.Findings {
+			found = found || reflect.DeepEqual(finding, other)
+		}
+		if !found {
+			t.Errorf("Expected %v among the findings of validate %v", finding, checked.Findings)
+		}
+	}
+
+	if file := (report.Checker{}).Lint("broken.json", []byte("{")); len(file.Findings) != 1 || file.Findings[0].Rule != report.RuleInvalidFormat {
+		t.Errorf("Expected an invalidFormat finding, got %v", file.Findings)
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	files := []report.File{
+		report.Check("policies/reports.json", []byte(twoInvalidStatements)),
+		report.CheckFile("../test_data/valid_format/valid_policy_2.json"),
+		{Path: "policies/missing.json", Err: errors.New("no such file or directory")},
+	}
+	var buffer bytes.Buffer
+	if err := report.WriteJUnit(&buffer, files); err != nil {
+		t.Fatalf("Failed to write JUnit: %v", err)
+	}
+
+	var suites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Suites   []struct {
+			TestCases []struct {
+				Name     string `xml:"name,attr"`
+				Failures []struct {
+					Type string `xml:"type,attr"`
+				} `xml:"failure"`
+				Error *struct{} `xml:"error"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(buffer.Bytes(), &suites); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	if suites.Tests != 3 || suites.Failures != 1 || suites.Errors != 1 || len(suites.Suites) != 1 {
+		t.Fatalf("Expected 3 tests, 1 failure and 1 error in one suite, got %+v", suites)
+	}
+	cases := suites.Suites[0].TestCases
+	if cases[0].Name != "policies/reports.json" || len(cases[0].Failures) != 2 || cases[0].Failures[0].Type != "invalidEffect" {
+		t.Errorf("Expected a failure per finding of reports.json, got %+v", cases[0])
+	}
+	if len(cases[1].Failures) != 0 || cases[1].Error != nil {
+		t.Errorf("Expected the valid policy to pass, got %+v", cases[1])
+	}
+	if cases[2].Error == nil {
+		t.Errorf("Expected the missing file to be in error")
+	}
+}
+
+func TestJSONReports(t *testing.T) {
+	files := []report.File{
+		report.Check("policies/reports.json", []byte(twoInvalidStatements)),
+		report.CheckFile("../test_data/valid_format/valid_policy_2.json"),
+	}
+
+	var buffer bytes.Buffer
+	if err := report.Write(&buffer, report.FormatJSON, files); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	var document struct {
+		Files []struct {
+			File     string `json:"file"`
+			Passed   bool   `json:"passed"`
+			Findings []struct {
+				Rule    string `json:"rule"`
+				Element string `json:"element"`
+				Line    int    `json:"line"`
+			} `json:"findings"`
+		} `json:"files"`
+		Summary struct {
+			Files  int `json:"files"`
+			Failed int `json:"failed"`
+			Errors int `json:"errors"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("Invalid JSON report: %v", err)
+	}
+	if document.Summary.Files != 2 || document.Summary.Failed != 1 || document.Summary.Errors != 2 {
+		t.Errorf("Unexpected summary %+v", document.Summary)
+	}
+	if document.Files[0].Passed || !document.Files[1].Passed || len(document.Files[1].Findings) != 0 {
+		t.Errorf("Expected only reports.json to fail, got %+v", document.Files)
+	}
+	if finding := document.Files[0].Findings[1]; finding.Rule != "wildcardResource" ||
+		finding.Element != "PolicyDocument.Statement[1].Resource[1]" || finding.Line != 7 {
+		t.Errorf("Unexpected finding %+v", finding)
+	}
+
+	buffer.Reset()
+	if err := report.Write(&buffer, report.FormatNDJSON, files); err != nil {
+		t.Fatalf("Failed to write NDJSON: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per finding, got %q", lines)
+	}
+	for _, line := range lines {
+		var finding struct {
+			File        string `json:"file"`
+			Fingerprint string `json:"fingerprint"`
+		}
+		if err := json.Unmarshal([]byte(line), &finding); err != nil || finding.File != "policies/reports.json" || finding.Fingerprint == "" {
+			t.Errorf("Unexpected line %s (%v)", line, err)
+		}
+	}
+
+	if err := report.Write(&buffer, "csv", files); err == nil {
+		t.Errorf("Expected an unknown format to be rejected")
+	}
+}
+
+func TestReportCheckPolicyTypes(t *testing.T) {
+	tests := []struct {
+		path       string
+		policyType report.PolicyType
+		rule       string
+		element    string
+		line       int
+	}{
+		{"../fixtures/documents/bucket_policy.json", report.TypeResource, "resourcePolicyPrincipal", "Statement[1]", 11},
+		{"../fixtures/documents/trust_policy.yaml", report.TypeTrust, "trustPolicyAction", "Statement[1].Action", 10},
+		{"../fixtures/documents/bucket_policy.json", report.TypeIdentity, report.RuleInvalidFormat, "", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policyType), func(t *testing.T) {
+			file := report.CheckFileAs(test.path, test.policyType)
+			if len(file.Findings) != 1 {
+				t.Fatalf("Expected 1 finding, got %v", file.Findings)
+			}
+			finding := file.Findings[0]
+			if finding.Rule != test.rule || finding.Path != test.element || finding.Line != test.line {
+				t.Errorf("Expected %s at %s line %d, got %s at %s line %d", test.rule, test.element, test.line,
+					finding.Rule, finding.Path, finding.Line)
+			}
+		})
+	}
+}
+
+func TestReportCheckKeyPolicy(t *testing.T) {
+	policy := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::123456789012:root"}, "Action": "kms:*", "Resource": "*"},
+    {"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::123456789012:root"}, "Action": "kms:Decrypt", "Resource": ["*", "arn:aws:kms:us-east-1:123456789012:key/1"]}
+  ]
+}`
+	// "*" refers to the key itself only when it is the only resource.
+	file := report.CheckAs("key.json", []byte(policy), report.TypeKey)
+	if len(file.Findings) != 1 || file.Findings[0].Rule != "wildcardResource" || file.Findings[0].Path != "Statement[1].Resource[0]" {
+		t.Errorf("Expected only the wildcard of the second statement to be reported, got %v", file.Findings)
+	}
+	if file := report.CheckAs("bucket.json", []byte(policy), report.TypeResource); len(file.Findings) != 2 {
+		t.Errorf("Expected both wildcards to be reported in a resource policy, got %v", file.Findings)
+	}
+}
+
+func TestReportCheckReader(t *testing.T) {
+	stream := twoInvalidStatements + "\n" + twoInvalidStatements
+	files := report.CheckReader("<stdin>", strings.NewReader(stream), report.TypeIdentity)
+	if len(files) != 2 || files[0].Path != "<stdin>#1" || files[1].Path != "<stdin>#2" {
+		t.Fatalf("Expected a file per policy, got %+v", files)
+	}
+	// The second policy starts on line 11, so its findings are 10 lines below those of the first.
+	for i, finding := range files[1].Findings {
+		if first := files[0].Findings[i]; finding.Line != first.Line+10 || finding.Column != first.Column {
+			t.Errorf("Expected %s at %d:%d, got %d:%d", finding.Rule, first.Line+10, first.Column, finding.Line, finding.Column)
+		}
+	}
+
+	if files := report.CheckReader("<stdin>", strings.NewReader(twoInvalidStatements), report.TypeIdentity); len(files) != 1 || files[0].Path != "<stdin>" {
+		t.Errorf("Expected a single file named after the reader, got %+v", files)
+	}
+}
@@ -0,0 +1,184 @@
+package unit_tests
+
+import (
+	"fmt"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"io/ioutil"
+	"testing"
+)
+
+func loadRole(t *testing.T, path string) validator.Role {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	role, err := validator.DecodeRole(data)
+	if err != nil {
+		t.Fatalf("Failed to decode %s: %v", path, err)
+	}
+	return role
+}
+
+func TestValidateRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(role *validator.Role)
+		errMsg string
+	}{
+		{
+			name:   "Valid Role",
+			modify: func(role *validator.Role) {},
+			errMsg: "",
+		},
+		{
+			name:   "Missing Trust Policy",
+			modify: func(role *validator.Role) { role.AssumeRolePolicyDocument = nil },
+			errMsg: validator.GetErrorMessage("emptyTrustPolicy"),
+		},
+		{
+			name: "Trust Policy With Resource",
+			modify: func(role *validator.Role) {
+				role.AssumeRolePolicyDocument.Statement[0].Resource = "arn:aws:iam::123456789012:role/report-worker"
+			},
+			errMsg: "AssumeRolePolicyDocument: " + validator.GetErrorMessage("trustPolicyResource"),
+		},
+		{
+			name:   "Invalid Inline Policy",
+			modify: func(role *validator.Role) { role.Policies[1].PolicyDocument.Statement[0].Effect = "allow" },
+			errMsg: "Policies[1]: " + validator.GetErrorMessage("invalidEffect"),
+		},
+		{
+			name:   "Duplicated Inline Policy Name",
+			modify: func(role *validator.Role) { role.Policies[1].PolicyName = role.Policies[0].PolicyName },
+			errMsg: "Policies[1]: " + validator.GetErrorMessage("duplicatePolicyName"),
+		},
+		{
+			name:   "Invalid Permissions Boundary",
+			modify: func(role *validator.Role) { role.PermissionsBoundary = "DeveloperBoundary" },
+			errMsg: validator.GetErrorMessage("invalidPermissionsBoundary"),
+		},
+		{
+			name: "Inline Policies Too Large",
+			modify: func(role *validator.Role) {
+				var actions []interface{}
+				for i := 0; i < 500; i++ {
+					actions = append(actions, fmt.Sprintf("s3:GetObjectVersion%d", i))
+				}
+				role.Policies[0].PolicyDocument.Statement[0].Action = actions
+			},
+			errMsg: validator.GetErrorMessage("roleInlinePolicySize"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			role := loadRole(t, "../fixtures/roles/valid_role.json")
+			test.modify(&role)
+			err := validator.ValidateRole(role)
+
+			checkTestResult(t, test.name, test.errMsg == "", test.errMsg, err == nil, err)
+		})
+	}
+}
+
+func TestValidateTrustPolicy(t *testing.T) {
+	trust := func(statement validator.Statement) validator.PolicyDocument {
+		return validator.PolicyDocument{Version: "2012-10-17", Statement: []validator.Statement{statement}}
+	}
+	service := &validator.PrincipalBlock{Service: "ec2.amazonaws.com"}
+
+	tests := []struct {
+		name     string
+		input    validator.PolicyDocument
+		expected bool
+		errMsg   string
+	}{
+		{
+			name:     "Valid Trust Policy",
+			input:    trust(validator.Statement{Effect: "Allow", Principal: service, Action: "sts:AssumeRole"}),
+			expected: true,
+			errMsg:   "",
+		},
+		{
+			name:     "Missing Principal",
+			input:    trust(validator.Statement{Effect: "Allow", Action: "sts:AssumeRole"}),
+			expected: false,
+			errMsg:   validator.GetErrorMessage("trustPolicyPrincipal"),
+		},
+		{
+			name:     "Empty Principal",
+			input:    trust(validator.Statement{Effect: "Allow", Principal: &validator.PrincipalBlock{}, Action: "sts:AssumeRole"}),
+			expected: false,
+			errMsg:   validator.GetErrorMessage("trustPolicyPrincipal"),
+		},
+		{
+			name:     "NotPrincipal",
+			input:    trust(validator.Statement{Effect: "Deny", NotPrincipal: service, Action: "sts:AssumeRole"}),
+			expected: false,
+			errMsg:   validator.GetErrorMessage("trustPolicyNotPrincipal"),
+		},
+		{
+			name:     "Non STS Action",
+			input:    trust(validator.Statement{Effect: "Allow", Principal: service, Action: []interface{}{"sts:AssumeRole", "s3:GetObject"}}),
+			expected: false,
+			errMsg:   validator.GetErrorMessage("trustPolicyAction"),
+		},
+		{
+			name:     "Missing Action",
+			input:    trust(validator.Statement{Effect: "Allow", Principal: service}),
+			expected: false,
+			errMsg:   validator.GetErrorMessage("emptyAction"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := validator.ValidateTrustPolicy(test.input)
+
+			checkTestResult(t, test.name, test.expected, test.errMsg, result, err)
+		})
+	}
+}
+
+func TestValidateRoleProperties(t *testing.T) {
+	tooMany := make([]string, 21)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("arn:aws:iam::123456789012:policy/Policy%d", i)
+	}
+
+	tests := []struct {
+		name     string
+		validate func() (bool, error)
+		expected bool
+		errMsg   string
+	}{
+		{"Valid Role Name", func() (bool, error) { return validator.ValidateRoleName("report-worker@prod") }, true, ""},
+		{"Role Name Too Long", func() (bool, error) { return validator.ValidateRoleName(repeatString("a", 65)) }, false, validator.GetErrorMessage("invalidRoleName")},
+		{"Role Name With Slash", func() (bool, error) { return validator.ValidateRoleName("team/worker") }, false, validator.GetErrorMessage("invalidRoleName")},
+		{"Root Path", func() (bool, error) { return validator.ValidateRolePath("/") }, true, ""},
+		{"Nested Path", func() (bool, error) { return validator.ValidateRolePath("/workloads/reports/") }, true, ""},
+		{"Path Without Trailing Slash", func() (bool, error) { return validator.ValidateRolePath("/workloads") }, false, validator.GetErrorMessage("invalidRolePath")},
+		{"Path With Space", func() (bool, error) { return validator.ValidateRolePath("/my workloads/") }, false, validator.GetErrorMessage("invalidRolePath")},
+		{"AWS Managed Policy", func() (bool, error) {
+			return validator.ValidateManagedPolicyArns([]string{"arn:aws:iam::aws:policy/ReadOnlyAccess"})
+		}, true, ""},
+		{"Invalid Managed Policy ARN", func() (bool, error) {
+			return validator.ValidateManagedPolicyArns([]string{"arn:aws:iam::123456789012:role/ReadOnly"})
+		}, false, validator.GetErrorMessage("invalidManagedPolicyArn")},
+		{"Duplicated Managed Policy", func() (bool, error) {
+			return validator.ValidateManagedPolicyArns([]string{tooMany[0], tooMany[0]})
+		}, false, validator.GetErrorMessage("duplicateManagedPolicyArn")},
+		{"Too Many Managed Policies", func() (bool, error) { return validator.ValidateManagedPolicyArns(tooMany) }, false, validator.GetErrorMessage("tooManyManagedPolicies")},
+		{"Minimum Session Duration", func() (bool, error) { return validator.ValidateMaxSessionDuration(3600) }, true, ""},
+		{"Session Duration Too Short", func() (bool, error) { return validator.ValidateMaxSessionDuration(900) }, false, validator.GetErrorMessage("invalidMaxSessionDuration")},
+		{"Session Duration Too Long", func() (bool, error) { return validator.ValidateMaxSessionDuration(43201) }, false, validator.GetErrorMessage("invalidMaxSessionDuration")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := test.validate()
+
+			checkTestResult(t, test.name, test.expected, test.errMsg, result, err)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/glob"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/report"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/scan"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		glob    string
+		name    string
+		matches bool
+	}{
+		{"*.json", "policies/s3/read.json", true},
+		{"*.json", "policies/s3/read.yaml", false},
+		{"node_modules", "web/node_modules", true},
+		{"policies/*.json", "policies/read.json", true},
+		{"policies/*.json", "policies/s3/read.json", false},
+		{"policies/**/*.json", "policies/read.json", true},
+		{"policies/**/*.json", "policies/s3/prod/read.json", true},
+		{"**/prod/**", "policies/s3/prod/read.json", true},
+		{"./policies/**", "policies/read.json", true},
+		{"**/prod/**", "policies/s3/staging/read.json", false},
+	}
+
+	for _, test := range tests {
+		if matches := glob.Match(test.glob, test.name); matches != test.matches {
+			t.Errorf("Match(%q, %q) = %v, expected %v", test.glob, test.name, matches, test.matches)
+		}
+	}
+}
+
+func TestScanFind(t *testing.T) {
+	files := scan.Find([]string{"../test_data/yaml", "../test_data/missing", "../test_data/valid_format"}, scan.Options{
+		Include: []string{"valid_*"},
+		Exclude: []string{"valid_policy_[3-6].json"},
+	})
+
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	expected := []string{
+		"../test_data/yaml/valid_policy.yaml",
+		"../test_data/missing",
+		"../test_data/valid_format/valid_policy_1.json",
+		"../test_data/valid_format/valid_policy_2.json",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected %v, got %v", expected, paths)
+	}
+	if files[1].Err == nil {
+		t.Errorf("Expected the missing path to be in error")
+	}
+}
+
+func TestScanFindSkip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"policy.json", "known-findings.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options := scan.Options{Skip: []string{filepath.Join(dir, "known-findings.json")}}
+	files := scan.Find([]string{dir}, options)
+	if len(files) != 1 || filepath.Base(files[0].Path) != "policy.json" {
+		t.Errorf("Expected the skipped file to be left out, got %v", files)
+	}
+	// Files given explicitly are always checked.
+	if files := scan.Find([]string{filepath.Join(dir, "known-findings.json")}, options); len(files) != 1 {
+		t.Errorf("Expected the explicit file to be found, got %v", files)
+	}
+}
+func TestScanIsDeterministic(t *testing.T) {
+	serial := scan.Scan([]string{"../test_data"}, scan.Options{Workers: 1})
+	parallel := scan.Scan([]string{"../test_data"}, scan.Options{Workers: 8})
+	if !reflect.DeepEqual(serial.Files, parallel.Files) {
+		t.Fatalf("Expected the same files in the same order whatever the number of workers")
+	}
+	if serial.Summary != report.Summarize(serial.Files) || serial.Summary.Files < 30 || serial.Summary.Failed == 0 {
+		t.Errorf("Unexpected summary %+v", serial.Summary)
+	}
+}
+
+func TestScanWatcher(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := os.ReadFile("../test_data/valid_format/valid_policy_1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := filepath.Join(dir, "first.json"), filepath.Join(dir, "second.json")
+	for _, path := range []string{first, second} {
+		if err := os.WriteFile(path, policy, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watcher := scan.NewWatcher([]string{dir}, scan.Options{})
+	if changed := watcher.Poll(); !reflect.DeepEqual(changed, []string{first, second}) {
+		t.Fatalf("Expected the first poll to check every file, got %v", changed)
+	}
+	if changed := watcher.Poll(); len(changed) != 0 {
+		t.Fatalf("Expected no change, got %v", changed)
+	}
+
+	invalid := strings.Replace(string(policy), `"Allow"`, `"Permit"`, 1)
+	if err := os.WriteFile(second, []byte(invalid), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the change is seen on file systems with a coarse modification time.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(second, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed := watcher.Poll(); !reflect.DeepEqual(changed, []string{second}) {
+		t.Fatalf("Expected only the modified file to be checked again, got %v", changed)
+	}
+	if files := watcher.Files(); len(files) != 2 || files[0].Failed() || !files[1].Failed() {
+		t.Errorf("Expected only the modified file to fail, got %+v", files)
+	}
+
+	if err := os.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	if changed := watcher.Poll(); !reflect.DeepEqual(changed, []string{first}) || len(watcher.Files()) != 1 {
+		t.Errorf("Expected the removed file to be dropped, got %v", changed)
+	}
+}
@@ -0,0 +1,125 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/policytest"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/simulator"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	policy := validator.IAMPolicy{
+		PolicyName: "SimulatorPolicy",
+		PolicyDocument: validator.PolicyDocument{
+			Version: "2012-10-17",
+			Statement: []validator.Statement{
+				{
+					Effect:   "Allow",
+					Action:   []interface{}{"s3:Get*"},
+					Resource: "arn:aws:s3:::bucket-a/*",
+				},
+				{
+					Effect:   "Deny",
+					Action:   "s3:GetObject",
+					Resource: "arn:aws:s3:::bucket-a/secret/*",
+				},
+				{
+					Effect:   "Allow",
+					Action:   "s3:PutObject",
+					Resource: "arn:aws:s3:::bucket-a/*",
+					Conditions: map[string]validator.ConditionMap{
+						"IpAddress": {"aws:SourceIp": {"10.0.0.0/8"}},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		request  simulator.Request
+		expected simulator.Decision
+	}{
+		{
+			name:     "Allowed By Wildcard Action",
+			request:  simulator.Request{Action: "S3:GetObject", Resource: "arn:aws:s3:::bucket-a/report.csv"},
+			expected: simulator.Allowed,
+		},
+		{
+			name:     "Explicit Deny Wins",
+			request:  simulator.Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::bucket-a/secret/key"},
+			expected: simulator.ExplicitDeny,
+		},
+		{
+			name:     "Other Bucket",
+			request:  simulator.Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::bucket-b/report.csv"},
+			expected: simulator.ImplicitDeny,
+		},
+		{
+			name: "Condition Satisfied",
+			request: simulator.Request{
+				Action:   "s3:PutObject",
+				Resource: "arn:aws:s3:::bucket-a/report.csv",
+				Context:  map[string][]string{"aws:SourceIp": {"10.1.2.3"}},
+			},
+			expected: simulator.Allowed,
+		},
+		{
+			name: "Condition Not Satisfied",
+			request: simulator.Request{
+				Action:   "s3:PutObject",
+				Resource: "arn:aws:s3:::bucket-a/report.csv",
+				Context:  map[string][]string{"aws:SourceIp": {"192.168.0.1"}},
+			},
+			expected: simulator.ImplicitDeny,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := simulator.Evaluate([]validator.IAMPolicy{policy}, tc.request)
+			if result.Decision != tc.expected {
+				t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, result.Decision)
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"s3:Get*", "s3:GetObject", true},
+		{"arn:aws:s3:::bucket/?", "arn:aws:s3:::bucket/a", true},
+		{"arn:aws:s3:::bucket/?", "arn:aws:s3:::bucket/ab", false},
+		// A '*' in the pattern is a wildcard even where the value holds a '*' too.
+		{"*/reports/*", "*/archive/reports/2026", true},
+		{"*x", "*ax", true},
+	}
+
+	for _, tc := range tests {
+		if got := simulator.MatchPattern(tc.pattern, tc.value); got != tc.expected {
+			t.Errorf("MatchPattern(%q, %q): expected %v, got %v", tc.pattern, tc.value, tc.expected, got)
+		}
+	}
+}
+
+func TestPolicyTestSuite(t *testing.T) {
+	suite, err := policytest.LoadSuite("../policy_tests/example_test.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load suite: %v", err)
+	}
+
+	report, err := suite.Run()
+	if err != nil {
+		t.Fatalf("Failed to run suite: %v", err)
+	}
+
+	for _, result := range report.Results {
+		if !result.Passed {
+			t.Errorf("%s: expected %s, got %s", result.Case.Name, result.Case.Expect, result.Result.Decision)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package unit_tests
+
+import (
+	"errors"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLPolicy(t *testing.T) {
+	fromYAML, err := validator.LoadPolicyFile("../test_data/yaml/valid_policy.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load YAML policy: %v", err)
+	}
+	fromJSON, err := validator.LoadPolicyFile("../test_data/valid_format/valid_policy_2.json")
+	if err != nil {
+		t.Fatalf("Failed to load JSON policy: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("Expected the YAML policy to decode like its JSON version, got %+v and %+v", fromYAML, fromJSON)
+	}
+}
+
+func TestPolicySourceErrors(t *testing.T) {
+	tests := []struct {
+		path   string
+		line   int
+		column int
+		errMsg string
+	}{
+		{"../test_data/yaml/duplicate_key.yaml", 8, 7, "Effect: " + validator.GetErrorMessage("duplicateKey")},
+		{"../test_data/yaml/unknown_field.yaml", 8, 7, `unknown field "Conditions"`},
+		{"../test_data/yaml/invalid_type.yaml", 5, 5, "cannot unmarshal object"},
+		{"../test_data/invalid_format/dupicated_fields.json", 18, 7, "Effect: " + validator.GetErrorMessage("duplicateKey")},
+		{"../test_data/invalid_format/unwanted_field.json", 3, 3, `unknown field "UnwantedField"`},
+		{"../test_data/invalid_type/invalid_version_type.json", 4, 16, "cannot unmarshal bool"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			_, err := validator.DecodePolicyFile(test.path)
+			var positionErr *validator.PositionError
+			if !errors.As(err, &positionErr) {
+				t.Fatalf("Expected a position error, got %v", err)
+			}
+			if positionErr.Line != test.line || positionErr.Column != test.column {
+				t.Errorf("Expected line %d, column %d, got %d, %d", test.line, test.column, positionErr.Line, positionErr.Column)
+			}
+			if !strings.Contains(err.Error(), test.errMsg) {
+				t.Errorf("Expected error containing %q, got %q", test.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected validator.Format
+	}{
+		{"{\"PolicyName\": \"x\"}", validator.FormatJSON},
+		{"\n  {}", validator.FormatJSON},
+		{"PolicyName: x\n", validator.FormatYAML},
+		{"---\nPolicyName: x\n", validator.FormatYAML},
+		{"# comment\n{}", validator.FormatYAML},
+	}
+
+	for _, test := range tests {
+		if format := validator.DetectFormat([]byte(test.data)); format != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.data, test.expected, format)
+		}
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	first := `{"PolicyName": "First", "PolicyDocument": {"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::a/*"}]}}`
+	second := `{"PolicyName": "Second", "PolicyDocument": {"Version": "2012-10-17", "Statement": [{"Effect": "Permit", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::b/*"}]}}`
+	stream := "\n" + first + "\n\n" + second + "\n"
+
+	documents := validator.SplitDocuments([]byte(stream))
+	if len(documents) != 2 || string(documents[0].Data) != first || string(documents[1].Data) != second {
+		t.Fatalf("Expected the two policies, got %q", documents)
+	}
+	if documents[1].Offset != strings.Index(stream, second) {
+		t.Errorf("Expected the second policy at offset %d, got %d", strings.Index(stream, second), documents[1].Offset)
+	}
+
+	valid, err := validator.ValidatePolicyReader(strings.NewReader(stream))
+	checkTestResult(t, "stream with an invalid second policy", false, "document 2: "+validator.GetErrorMessage("invalidEffect"), valid, err)
+	valid, err = validator.ValidatePolicyReader(strings.NewReader(first + first))
+	checkTestResult(t, "stream of valid policies", true, "", valid, err)
+	valid, err = validator.ValidatePolicyReader(strings.NewReader(" \n"))
+	checkTestResult(t, "empty stream", false, "policy is empty", valid, err)
+
+	if documents := validator.SplitDocuments([]byte(first + `{"PolicyName": `)); len(documents) != 2 {
+		t.Errorf("Expected the truncated policy as a document of its own, got %q", documents)
+	}
+}
@@ -0,0 +1,75 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/terraform"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"testing"
+)
+
+func TestTerraformPlan(t *testing.T) {
+	plan, err := terraform.LoadPlan("../fixtures/terraform/plan.json")
+	if err != nil {
+		t.Fatalf("Failed to load plan: %v", err)
+	}
+
+	expected := map[string]string{
+		"aws_iam_role.report_worker":            "",
+		"aws_iam_role_policy.notify":            "",
+		"aws_s3_bucket_policy.reports":          "",
+		"data.aws_iam_policy_document.notify":   "",
+		"module.ci.aws_iam_policy.build":        "policy: " + validator.GetErrorMessage("wildcardResource"),
+		"module.ci.aws_sqs_queue_policy.builds": "policy: " + validator.GetErrorMessage("invalidEffect"),
+		"aws_iam_policy.generated":              "",
+	}
+	results := plan.Validate()
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %v", len(expected), results)
+	}
+	for i, result := range results {
+		if i > 0 && results[i-1].Address > result.Address {
+			t.Errorf("Results are not ordered by address: %s before %s", results[i-1].Address, result.Address)
+		}
+		errMsg, ok := expected[result.Address]
+		if !ok {
+			t.Errorf("Unexpected result for %s", result.Address)
+		}
+		checkTestResult(t, result.Address, errMsg == "", errMsg, result.Err == nil, result.Err)
+	}
+
+	for _, result := range results {
+		skipped := result.Address == "aws_iam_policy.generated"
+		if (result.Skipped != "") != skipped {
+			t.Errorf("%s: unexpected skip reason %q", result.Address, result.Skipped)
+		}
+	}
+}
+
+func TestTerraformPlanErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not JSON", data: `resource "aws_iam_policy" "build" {}`},
+		{name: "state instead of plan", data: `{"version": 4, "resources": []}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := terraform.ParsePlan([]byte(test.data)); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestTerraformPlanNoPolicies(t *testing.T) {
+	plan, err := terraform.ParsePlan([]byte(`{"format_version": "1.2", "planned_values": {"root_module": {"resources": [
+		{"address": "aws_s3_bucket.reports", "mode": "managed", "type": "aws_s3_bucket", "name": "reports", "values": {"bucket": "reports"}}
+	]}}}`))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+	if results := plan.Validate(); len(results) != 0 {
+		t.Errorf("Expected no results, got %v", results)
+	}
+}
@@ -0,0 +1,112 @@
+package unit_tests
+
+import (
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/cloudtrail"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/usage"
+	"github.com/kcbojanowski/aws-iam-policy-verifier/pkg/validator"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func unusedActions(report usage.Report) []string {
+	var actions []string
+	for _, grant := range report.UnusedGrants() {
+		actions = append(actions, grant.Action)
+	}
+	return actions
+}
+
+func TestUnusedFromAccessAdvisor(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/usage/report_reader_policy.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+	used, err := usage.ReadAccessAdvisor("../fixtures/usage/access_advisor.json")
+	if err != nil {
+		t.Fatalf("Failed to read Access Advisor export: %v", err)
+	}
+
+	report := usage.Analyze(policy, used, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC))
+	// s3:GetObject is not tracked by Access Advisor, it is used because S3 was used.
+	expected := []string{
+		"dynamodb:GetItem", "dynamodb:GetRecords", "dynamodb:GetResourcePolicy", "dynamodb:GetShardIterator",
+		"s3:PutObject", "sns:Publish",
+	}
+	if got := unusedActions(report); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected unused actions %v, got %v", expected, got)
+	}
+	if services := report.UnusedServices(); len(services) != 2 || services[0].Prefix != "dynamodb" || services[1].Prefix != "sns" {
+		t.Errorf("Expected dynamodb and sns to be unused, got %v", services)
+	}
+
+	// Without a window, DynamoDB was used in June.
+	report = usage.Analyze(policy, used, time.Time{})
+	if got := unusedActions(report); !reflect.DeepEqual(got, []string{"s3:PutObject", "sns:Publish"}) {
+		t.Errorf("Expected s3:PutObject and sns:Publish to be unused, got %v", got)
+	}
+}
+
+func TestTrimUnusedFromCloudTrail(t *testing.T) {
+	policy, err := validator.LoadPolicyFile("../test_data/usage/report_reader_policy.json")
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+	events, err := cloudtrail.ReadEvents([]string{"../test_data/cloudtrail"})
+	if err != nil {
+		t.Fatalf("Failed to read CloudTrail logs: %v", err)
+	}
+
+	used := usage.FromActivity(cloudtrail.Observe(events, cloudtrail.Filter{RoleARN: reportReaderRole}))
+	report := usage.Analyze(policy, used, time.Time{})
+	trimmed, err := usage.Trim(policy, report)
+	if err != nil {
+		t.Fatalf("Failed to trim policy: %v", err)
+	}
+
+	statements := trimmed.PolicyDocument.Statement
+	if len(statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(statements))
+	}
+	if got := validator.StringValues(statements[0].Action); !reflect.DeepEqual(got, []string{"s3:GetObject", "s3:ListBucket"}) {
+		t.Errorf("Expected s3:GetObject and s3:ListBucket, got %v", got)
+	}
+	if got := validator.StringValues(statements[1].Action); !reflect.DeepEqual(got, []string{"sqs:SendMessage"}) {
+		t.Errorf("Expected sqs:SendMessage, got %v", got)
+	}
+}
+
+func TestTrimKeepsActionsMissingFromTheCatalog(t *testing.T) {
+	policy := validator.IAMPolicy{PolicyName: "Reader", PolicyDocument: validator.PolicyDocument{
+		Version: "2012-10-17",
+		Statement: []validator.Statement{
+			{Effect: "Allow", Action: "s3:Get*", Resource: "arn:aws:s3:::examplebucket/*"},
+		},
+	}}
+	lastUsed := time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC)
+	// s3:GetAccessGrant is not in the catalog, which cannot expand s3:Get* to it.
+	activity := cloudtrail.Activity{Actions: map[string]*cloudtrail.ObservedAction{
+		"s3:GetObject":      {Action: "s3:GetObject", Known: true, LastUsed: lastUsed},
+		"s3:GetAccessGrant": {Action: "s3:GetAccessGrant", LastUsed: lastUsed},
+	}}
+
+	report := usage.Analyze(policy, usage.FromActivity(activity), time.Time{})
+	trimmed, err := usage.Trim(policy, report)
+	if err != nil {
+		t.Fatalf("Failed to trim policy: %v", err)
+	}
+	if got := validator.StringValues(trimmed.PolicyDocument.Statement[0].Action); !reflect.DeepEqual(got, []string{"s3:GetObject", "s3:GetAccessGrant"}) {
+		t.Errorf("Expected s3:GetObject and s3:GetAccessGrant, got %v", got)
+	}
+
+	// Access Advisor only tells that S3 was used, which may be through actions the catalog does not list,
+	// even though one of its tracked actions was not used.
+	serviceLevel := usage.Usage{Services: map[string]time.Time{"s3": lastUsed}, Tracked: map[string]bool{"s3:getobjectlegalhold": true}}
+	report = usage.Analyze(policy, serviceLevel, time.Time{})
+	if trimmed, err = usage.Trim(policy, report); err != nil {
+		t.Fatalf("Failed to trim policy: %v", err)
+	}
+	if got := validator.StringValues(trimmed.PolicyDocument.Statement[0].Action); !reflect.DeepEqual(got, []string{"s3:Get*"}) {
+		t.Errorf("Expected s3:Get* to be kept, got %v", got)
+	}
+}